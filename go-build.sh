@@ -1,4 +1,4 @@
 #!/bin/bash
  
 GOOS=linux GOARCH=amd64 go build -o build/webStorageServer -C server/main 
-
+GOOS=linux GOARCH=amd64 go build -o build/webstorage -C server/cli
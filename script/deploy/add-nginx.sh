@@ -38,6 +38,15 @@ golangPort=\"$golangPort\"
 reactPort=\"$reactPort\"
 " >~/$serverpath/config.conf
 
+# go 服务的配置，其余配置项见 webStorageServer config print
+cat <<EOF >~/$serverpath/golangserver/config.yaml
+port: "$golangPort"
+mongodb:
+  name: $DBpath
+cors:
+  origins: [https://$serverName.dytx2tyxt.com, http://$serverName.dytx2tyxt.com]
+EOF
+
 echo "setting nginx"
 
 # set Nginx
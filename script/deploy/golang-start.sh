@@ -11,5 +11,22 @@ echo "serverpath: $serverpath"
 echo "DBpath: $DBpath"
 echo "golangPort: $golangPort"
 
+# 首次启动时创建管理员用的一次性 token，未配置时见日志
+if [ -n "$setupToken" ]; then
+    export WEBSTORAGE_USER_SETUP_TOKEN="$setupToken"
+fi
+
 cd ~/$serverpath/golangserver
-./webStorageServer --mongodb.name=$DBpath --port=$golangPort
+
+# 签名 token 和 cookie 的密钥，首次启动时生成
+if [ ! -f "token.secret" ]; then
+    (umask 077 && head -c 32 /dev/urandom | base64 > token.secret)
+fi
+export WEBSTORAGE_TOKEN_SECRET_FILE="token.secret"
+
+if [ -f "config.yaml" ]; then
+    ./webStorageServer -config config.yaml
+else
+    # 旧的部署没有 config.yaml
+    ./webStorageServer --mongodb.name=$DBpath --port=$golangPort
+fi
@@ -0,0 +1,32 @@
+// Package api holds the json payloads of the /v1 http api. It imports the
+// standard library only, so clients like the webstorage command use the
+// payloads of the server without linking it.
+//
+// The server decodes requests into these types, so fields a client may not
+// set are unknown fields. Responses the server builds from its stored data,
+// like mongodb.WebData, encode to the same json as their types here.
+package api
+
+// ErrorResponse is the json body of every error answer.
+type ErrorResponse struct {
+	// Code is the name of the gRPC code of the error, like NotFound.
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+	// Fields lists what is wrong with an invalid payload.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is why one field of a payload is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PageRequest is the query of a page of web data.
+type PageRequest struct {
+	Sort   string // id, name, created or updated
+	Desc   bool
+	Limit  int
+	Cursor string // NextCursor of the previous page
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"server/api"
+	"server/datasys"
+	"server/mongodb"
+	"server/tagquery"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestServerPayloads checks the payloads the server encodes from its own
+// types decode into the api types without unknown fields, and encode back
+// to the same json.
+func TestServerPayloads(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	webData := mongodb.WebData{
+		ID: 7, Name: "Go", Url: "https://go.dev/", Canonical: "https://go.dev", Tags: []string{"go"},
+		Description: "lang", Owner: "user1", Visibility: mongodb.VisibilityTeam, Created: now, Updated: now,
+		Fetch:  &mongodb.FetchInfo{Status: mongodb.FetchFailed, Error: "timeout", FetchedAt: now, Size: 3},
+		Enrich: mongodb.FetchDone, Icon: "https://go.dev/favicon.ico",
+		Link: &mongodb.LinkStatus{
+			LinkCheck: mongodb.LinkCheck{CheckedAt: now, Status: 301, RedirectURL: "https://go.dev/doc", LatencyMs: 12, Error: "moved"},
+			Failures:  1, Broken: true,
+			History: []mongodb.LinkCheck{{CheckedAt: now, Status: 200}},
+		},
+	}
+	category := primitive.NewObjectID()
+	tags := []mongodb.Tag{{Name: "go", Ref: 2, Order: 1, Category: category}, {Name: "news"}}
+
+	for _, tt := range []struct {
+		name   string
+		server any
+		api    any
+	}{
+		{"web data", webData, &api.WebData{}},
+		{"page", mongodb.WebDataPage{Items: []mongodb.WebData{webData}, Total: 1, NextCursor: "c"}, &api.WebDataPage{}},
+		{"tags", tags, &[]api.Tag{}},
+		{"categories", []mongodb.Category{{Id: category, Name: "dev", Tags: tags[:1]}}, &[]api.Category{}},
+		{"search", datasys.SearchResult{Total: 1, Items: []datasys.SearchHit{{
+			ScoredWebData: mongodb.ScoredWebData{WebData: webData, Score: 1.5, Content: "hidden"},
+			Highlights:    map[string]string{"Name": "<mark>Go</mark>"},
+		}}}, &api.SearchResult{}},
+	} {
+		data, err := json.Marshal(tt.server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(tt.api); err != nil {
+			t.Errorf("%s: decode %s: %v", tt.name, data, err)
+			continue
+		}
+		again, err := json.Marshal(tt.api)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want, got any
+		json.Unmarshal(data, &want)
+		json.Unmarshal(again, &got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: api types encode\n%s\nwant\n%s", tt.name, again, data)
+		}
+	}
+}
+
+// TestMoveTag checks the category of a tag update reads as the server
+// expects.
+func TestMoveTag(t *testing.T) {
+	for id, want := range map[string]string{"": `{"Category":null}`, "65f0c0ffee": `{"Category":"65f0c0ffee"}`} {
+		data, _ := json.Marshal(api.TagUpdate{Category: api.MoveTag(id)})
+		if string(data) != want {
+			t.Errorf("MoveTag(%q) = %s, want %s", id, data, want)
+		}
+	}
+	if data, _ := json.Marshal(api.TagUpdate{}); string(data) != `{}` {
+		t.Errorf("empty update = %s, want {}", data)
+	}
+}
+
+// TestTagQuery checks the quoted names of a tag query stay tag names for
+// the parser of the server.
+func TestTagQuery(t *testing.T) {
+	names := []string{"Dev Tools", "C++/C#", "-draft", "and", "NOT", "category:go", "中文 标签"}
+	expr, err := tagquery.Parse(api.TagQuery(names...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tagquery.Filter(expr, func(category string) ([]string, error) {
+		t.Errorf("resolved category %s", category)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"$and": bson.A{}}
+	for _, name := range names {
+		want["$and"] = append(want["$and"].(bson.A), bson.M{"tags": name})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filter of %s = %v, want %v", api.TagQuery(names...), got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type Tag struct {
+	Name string
+	// Ref counts the web data with the tag.
+	Ref   int
+	Order int
+	// Category is the hex id of the category of the tag, all zeros for
+	// none.
+	Category string `json:",omitempty"`
+}
+
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Tags []Tag  `json:"tags,omitempty"`
+}
+
+// TagRequest is the body of POST /tag. Ref is counted by the server.
+type TagRequest struct {
+	Name string
+	// Order is ignored, new tags are appended. The web ui sends it.
+	Order    int    `json:",omitempty"`
+	Category string `json:",omitempty"`
+}
+
+// TagUpdate is the body of PATCH /tag/{name}. Missing fields are kept,
+// Category null or "" moves the tag out of its category. Ref is counted by
+// the server.
+type TagUpdate struct {
+	Order *int `json:",omitempty"`
+	// Category is the raw json to tell null from a missing field, see
+	// MoveTag.
+	Category json.RawMessage `json:",omitempty"`
+}
+
+// MoveTag returns the Category of a TagUpdate moving the tag to category
+// id, out of its category for "".
+func MoveTag(id string) json.RawMessage {
+	if id == "" {
+		return json.RawMessage("null")
+	}
+	data, _ := json.Marshal(id)
+	return data
+}
+
+// CategoryUpdate is the body of PATCH /categories/{id}, the tags of a
+// category are moved with PATCH /tag/{name}.
+type CategoryUpdate struct {
+	Name string `json:"name"`
+}
+
+// TagQuery returns the tag query of the web data with all tags. The names
+// are quoted, so spaces, a leading "-" or words like AND stay tag names.
+func TagQuery(tags ...string) string {
+	quoted := make([]string, len(tags))
+	for i, tag := range tags {
+		quoted[i] = `"` + tag + `"`
+	}
+	return strings.Join(quoted, ",")
+}
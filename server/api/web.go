@@ -0,0 +1,113 @@
+package api
+
+import "time"
+
+// WebData is a saved bookmark.
+type WebData struct {
+	ID          int
+	Name        string
+	Url         string
+	Canonical   string
+	Tags        []string
+	Description string
+	Owner       string
+	Visibility  string
+	Created     time.Time
+	Updated     time.Time
+	Fetch       *FetchInfo
+	// Enrich is the status of filling Name, Description and Icon from the
+	// page.
+	Enrich string
+	Icon   string
+	Link   *LinkStatus
+}
+
+// FetchInfo is the state of the page content fetch.
+type FetchInfo struct {
+	Status    string
+	Error     string
+	FetchedAt time.Time
+	Size      int
+}
+
+// LinkCheck is one check of the dead link checker.
+type LinkCheck struct {
+	CheckedAt   time.Time
+	Status      int
+	RedirectURL string
+	LatencyMs   int64
+	Error       string
+}
+
+// LinkStatus is the latest link check of web data and its history.
+type LinkStatus struct {
+	LinkCheck
+	Failures int
+	Broken   bool
+	History  []LinkCheck
+}
+
+type WebDataPage struct {
+	Items      []WebData `json:"items"`
+	Total      int64     `json:"total"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// WebRequest is the body of POST /web and PATCH /web/{id}, the fields of
+// web data a client may set. The owner, fetch state, icon and link status
+// belong to the server, sending them is an unknown field.
+type WebRequest struct {
+	// ID is sent back by the web ui on PATCH, it must be the id of the path.
+	ID          int `json:",omitempty"`
+	Name        string
+	Url         string
+	Description string
+	Tags        []string
+	Visibility  string `json:",omitempty"`
+}
+
+// SearchHit is a full text search result with highlighted fields.
+type SearchHit struct {
+	WebData
+	Score float64 `json:"score"`
+	// Highlights holds html escaped snippets of the matching fields, with
+	// matched terms wrapped in <mark>.
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchResult is one page of search hits and the total number of matches.
+type SearchResult struct {
+	Items []SearchHit `json:"items"`
+	Total int64       `json:"total"`
+}
+
+// ImportEntry is one bookmark of an import. ID is the web data created or
+// already saving the url.
+type ImportEntry struct {
+	Url    string   `json:"url"`
+	Name   string   `json:"name,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	ID     int      `json:"id,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// ImportReport tells what an import did with each bookmark. Skipped ones
+// are saved already or invalid, conflicting ones are saved already but
+// differ, they are left unchanged. A dry run reports what an import would
+// do, without IDs of created web data.
+type ImportReport struct {
+	DryRun      bool          `json:"dryRun,omitempty"`
+	Created     []ImportEntry `json:"created"`
+	Skipped     []ImportEntry `json:"skipped"`
+	Conflicting []ImportEntry `json:"conflicting"`
+	// NewTags are the tags created for the created web data.
+	NewTags []string `json:"newTags"`
+}
+
+// ImportOptions are the query of POST /web/import.
+type ImportOptions struct {
+	// Visibility of the created web data, team by default.
+	Visibility string
+	// DryRun only reports what the import would do.
+	DryRun bool
+}
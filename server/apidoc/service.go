@@ -0,0 +1,51 @@
+package apidoc
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const pathPerfix = "/swagger"
+
+// Spec is the OpenAPI document of every /v1 route.
+//
+//go:embed openapi.json
+var Spec []byte
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8" />
+  <title>webStorage API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "%s/openapi.json", dom_id: "#swagger-ui", withCredentials: true });
+  </script>
+</body>
+</html>
+`
+
+func NewService(router *mux.Router) {
+	router.HandleFunc(pathPerfix+"/openapi.json", HandleSpec).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/", HandleUI).Methods(http.MethodGet)
+	router.Handle(pathPerfix, http.RedirectHandler(pathPerfix+"/", http.StatusMovedPermanently))
+}
+
+func HandleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(Spec)
+}
+
+func HandleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, uiPage, pathPerfix)
+}
@@ -0,0 +1,77 @@
+package apidoc
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"server/gateway"
+)
+
+var pathParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// operationKey ignores path parameter names, since OpenAPI can not tell
+// /web/{tags} from /web/{id}.
+func operationKey(method, path string) string {
+	return strings.ToLower(method) + " " + pathParam.ReplaceAllString(path, "{}")
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(Spec, &spec); err != nil {
+		t.Fatalf("decode openapi.json: %v", err)
+	}
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[operationKey(method, path)] = true
+		}
+	}
+
+	router := mux.NewRouter()
+	gateway.NewService(router)
+	routed := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routed[operationKey(method, path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var missing, stale []string
+	for op := range routed {
+		if !documented[op] {
+			missing = append(missing, op)
+		}
+	}
+	for op := range documented {
+		if !routed[op] {
+			stale = append(stale, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	for _, op := range missing {
+		t.Errorf("route %s is not documented in openapi.json", op)
+	}
+	for _, op := range stale {
+		t.Errorf("openapi.json documents %s but no route serves it", op)
+	}
+}
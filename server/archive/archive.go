@@ -0,0 +1,226 @@
+// Package archive keeps offline snapshots of the pages of web data, as
+// single html files with their assets inlined.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"server/config"
+	"server/mongodb"
+	"server/outbound"
+	"server/util"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	storeName       = &config.Current.Archive.Store
+	archiveDir      = &config.Current.Archive.Dir
+	maxSnapshotSize = &config.Current.Archive.MaxSize
+	timeout         = &config.Current.Archive.Timeout
+	workers         = &config.Current.Archive.Workers
+	maxPending      = &config.Current.Archive.MaxPending
+	maxPerUser      = &config.Current.Archive.MaxPerUser
+	maxBytesPerUser = &config.Current.Archive.MaxBytesPerUser
+	userAgent       = &config.Current.Archive.UserAgent
+)
+
+var (
+	store  BlobStore
+	client *http.Client
+	// slots limits the snapshots taken at once
+	slots chan struct{}
+	// retentionMu keeps two snapshots of one owner from deleting the same
+	// old snapshots
+	retentionMu sync.Mutex
+	// takeMu keeps two requests from passing maxPending at once
+	takeMu sync.Mutex
+)
+
+// Init opens the blob store. Snapshots can not be taken before.
+func Init() error {
+	var err error
+	if store, err = newStore(*storeName); err != nil {
+		return err
+	}
+	policy, err := outbound.DefaultPolicy()
+	if err != nil {
+		return err
+	}
+	client = outbound.NewClient(*timeout, policy)
+	n := *workers
+	if n < 1 {
+		n = 1
+	}
+	slots = make(chan struct{}, n)
+	// nothing is taken yet, every pending snapshot was lost by a stop
+	failStaleSnapshots(time.Now())
+	return nil
+}
+
+// failStaleSnapshots marks the snapshots pending since before as failed.
+// They were lost when the server stopped while taking them.
+func failStaleSnapshots(before time.Time) {
+	stale, err := mongodb.GetStaleSnapshots(before)
+	if err != nil {
+		util.Errorf("get stale snapshots failed").WithCause(err).Log()
+		return
+	}
+	failed := 0
+	for _, s := range stale {
+		ok, err := mongodb.FailPendingSnapshot(s.ID, "interrupted by a server stop")
+		if err != nil {
+			util.Errorf("fail stale snapshot of WebData %d failed", s.WebDataID).WithCause(err).Log()
+			return
+		}
+		if !ok {
+			continue
+		}
+		failed++
+		// the page may have been stored before the stop
+		if err := store.Delete(context.Background(), s.Key); err != nil {
+			logrus.Errorf("delete stale snapshot %s err: %v", s.Key, err)
+		}
+	}
+	if failed > 0 {
+		logrus.Infof("mark %d stale snapshots failed", failed)
+	}
+}
+
+// Take starts a snapshot of data in the background and returns it pending.
+// It fails with codes.ResourceExhausted while the owner of data has
+// -archive.maxPending snapshots pending.
+func Take(data mongodb.WebData) (mongodb.Snapshot, error) {
+	if store == nil {
+		return mongodb.Snapshot{}, util.Errorf("archive not initialized").WithCode(codes.Unavailable)
+	}
+	takeMu.Lock()
+	defer takeMu.Unlock()
+	pending, err := mongodb.CountPendingSnapshots(data.Owner)
+	if err != nil {
+		return mongodb.Snapshot{}, err
+	}
+	if pending >= int64(*maxPending) {
+		return mongodb.Snapshot{}, util.Errorf("%d snapshots of %s are pending, try again when they are done", pending, data.Owner).WithCode(codes.ResourceExhausted)
+	}
+	id := primitive.NewObjectID()
+	snapshot, err := mongodb.AddSnapshot(mongodb.Snapshot{
+		ID:        id,
+		Key:       fmt.Sprintf("%d/%s.html", data.ID, id.Hex()),
+		WebDataID: data.ID,
+		Owner:     data.Owner,
+		URL:       data.Url,
+		Created:   time.Now(),
+		Status:    mongodb.FetchPending,
+	})
+	if err != nil {
+		return snapshot, err
+	}
+	go take(snapshot)
+	return snapshot, nil
+}
+
+func take(snapshot mongodb.Snapshot) {
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	status, errMsg := mongodb.FetchDone, ""
+	page, err := takeSnapshot(ctx, client, snapshot.URL, *maxSnapshotSize)
+	if err == nil {
+		err = store.Put(ctx, snapshot.Key, bytes.NewReader(page))
+	}
+	size := int64(len(page))
+	if err != nil {
+		status, errMsg, size = mongodb.FetchFailed, util.Message(err), 0
+		logrus.Debugf("snapshot of WebData %d err: %v", snapshot.WebDataID, err)
+	}
+	err = mongodb.FinishSnapshot(snapshot.ID, status, errMsg, size)
+	if util.HaveErrorCode(err, codes.NotFound) {
+		// deleted while taken
+		store.Delete(context.Background(), snapshot.Key)
+		return
+	}
+	if err != nil {
+		util.Errorf("finish snapshot of WebData %d failed", snapshot.WebDataID).WithCause(err).Log()
+		return
+	}
+	if status == mongodb.FetchDone {
+		enforceRetention(snapshot.Owner)
+	}
+}
+
+// enforceRetention deletes the oldest snapshots of owner over maxPerUser or
+// maxBytesPerUser.
+func enforceRetention(owner string) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	snapshots, err := mongodb.GetOwnerSnapshots(owner)
+	if err != nil {
+		util.Errorf("get snapshots of %s failed", owner).WithCause(err).Log()
+		return
+	}
+	count, size := len(snapshots), int64(0)
+	for _, s := range snapshots {
+		size += s.Size
+	}
+	for _, s := range snapshots {
+		if count <= *maxPerUser && size <= *maxBytesPerUser {
+			break
+		}
+		if s.Status == mongodb.FetchPending {
+			continue
+		}
+		if err := remove(context.Background(), s); err != nil {
+			util.Errorf("delete old snapshot of %s failed", owner).WithCause(err).Log()
+			return
+		}
+		count--
+		size -= s.Size
+	}
+}
+
+// Open returns the html of a finished snapshot.
+func Open(ctx context.Context, snapshot mongodb.Snapshot) (io.ReadCloser, error) {
+	if store == nil {
+		return nil, util.Errorf("archive not initialized").WithCode(codes.Unavailable)
+	}
+	if snapshot.Status != mongodb.FetchDone {
+		return nil, util.Errorf("snapshot %s is %s", snapshot.ID.Hex(), snapshot.Status).WithCode(codes.FailedPrecondition)
+	}
+	return store.Get(ctx, snapshot.Key)
+}
+
+// DeleteAll deletes the snapshots of web data id.
+func DeleteAll(ctx context.Context, webDataID int) error {
+	if store == nil {
+		return nil
+	}
+	snapshots, err := mongodb.GetSnapshots(webDataID)
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if err := remove(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remove(ctx context.Context, snapshot mongodb.Snapshot) error {
+	if snapshot.Key != "" {
+		if err := store.Delete(ctx, snapshot.Key); err != nil {
+			return err
+		}
+	}
+	return mongodb.DeleteSnapshot(snapshot.ID)
+}
@@ -0,0 +1,243 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/mongodb"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc/codes"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("0", 2000))
+
+func newSite(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Page</title>
+<link rel="stylesheet" href="/css/site.css">
+<link rel="preload" href="/big.js">
+<script src="/app.js"></script>
+<meta http-equiv="refresh" content="0; url=https://example.com/">
+</head><body onload="track()">
+<a href="/other" onclick="steal()">other</a>
+<a href="javascript:alert(1)">bad</a>
+<img src="img/logo.png" srcset="img/logo@2x.png 2x">
+<div style="background: url('/img/logo.png')">styled</div>
+<iframe src="/ad"></iframe>
+<noscript><p>no scripts</p></noscript>
+</body></html>`)
+	})
+	mux.HandleFunc("/css/site.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprint(w, `@import "print.css"; body { background: url(../img/logo.png) }</style><script>x()</script>`)
+	})
+	mux.HandleFunc("/css/print.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprint(w, `.imported { color: red }`)
+	})
+	mux.HandleFunc("/img/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	site := newSite(t)
+	page, err := takeSnapshot(context.Background(), site.Client(), site.URL+"/page", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(page)
+	for _, want := range []string{
+		`<meta charset="utf-8"/>`,
+		"archived from " + site.URL + "/page",
+		`.imported { color: red }`,
+		`url("data:image/png;base64,`,
+		`<img src="data:image/png;base64,`,
+		`<a href="` + site.URL + `/other">`,
+		`<p>no scripts</p>`,
+		`<\/style><script>x()`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("snapshot lacks %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"<script src", "<iframe", "onload", "onclick", "javascript:", "srcset", "preload", "refresh", `href="/css`} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("snapshot contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestTakeSnapshotLimits(t *testing.T) {
+	site := newSite(t)
+	// room for the page but not its assets
+	page, err := takeSnapshot(context.Background(), site.Client(), site.URL+"/page", 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "data:image/png") {
+		t.Error("inlined assets over the size limit")
+	}
+
+	_, err = takeSnapshot(context.Background(), site.Client(), site.URL+"/page", 100)
+	if !util.HaveErrorCode(err, codes.FailedPrecondition) {
+		t.Errorf("page over the limit: got %v, want FailedPrecondition", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "12/abc.html", strings.NewReader("<p>hi</p>")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Get(ctx, "12/abc.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "<p>hi</p>" {
+		t.Errorf("Get = %q", data)
+	}
+
+	if err := store.Delete(ctx, "12/abc.html"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "12/abc.html"); !util.HaveErrorCode(err, codes.NotFound) {
+		t.Errorf("Get deleted blob: got %v, want NotFound", err)
+	}
+	for _, key := range []string{"../escape.html", "/abs.html", "a/../../b.html", ""} {
+		if err := store.Put(ctx, key, strings.NewReader("x")); !util.HaveErrorCode(err, codes.InvalidArgument) {
+			t.Errorf("Put(%q): got %v, want InvalidArgument", key, err)
+		}
+	}
+}
+
+// useMock points the snapshot collection at mt and the blob store at a
+// temporary directory.
+func useMock(t *testing.T, mt *mtest.T) *FileStore {
+	oldColl, oldStore := mongodb.Snapshotdb, store
+	t.Cleanup(func() { mongodb.Snapshotdb, store = oldColl, oldStore })
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mongodb.Snapshotdb, store = mt.Coll, fileStore
+	return fileStore
+}
+
+func TestTakeMaxPending(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("full", func(mt *mtest.T) {
+		useMock(t, mt)
+		old := *maxPending
+		*maxPending = 2
+		defer func() { *maxPending = old }()
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{"_id", 1}, {"n", 2}}))
+		_, err := Take(mongodb.WebData{ID: 3, Owner: "user1", Url: "https://go.dev"})
+		if !util.HaveErrorCode(err, codes.ResourceExhausted) {
+			t.Errorf("Take with 2 pending: got %v, want ResourceExhausted", err)
+		}
+	})
+}
+
+// TestFailStaleSnapshots checks stale snapshots are failed and their blobs
+// deleted, unless they finished meanwhile.
+func TestFailStaleSnapshots(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stale", func(mt *mtest.T) {
+		fileStore := useMock(t, mt)
+		ctx := context.Background()
+		stale, finished := primitive.NewObjectID(), primitive.NewObjectID()
+		for _, key := range []string{"3/stale.html", "4/finished.html"} {
+			if err := fileStore.Put(ctx, key, strings.NewReader("<p>page</p>")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		created := time.Now().Add(-time.Hour)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"_id", stale}, {"webDataId", 3}, {"status", mongodb.FetchPending}, {"created", created}, {"key", "3/stale.html"}},
+				bson.D{{"_id", finished}, {"webDataId", 4}, {"status", mongodb.FetchPending}, {"created", created}, {"key", "4/finished.html"}},
+			),
+			mtest.CreateSuccessResponse(bson.E{"n", 1}, bson.E{"nModified", 1}),
+			mtest.CreateSuccessResponse(bson.E{"n", 0}, bson.E{"nModified", 0}),
+		)
+		failStaleSnapshots(time.Now().Add(-time.Minute))
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName != "update" {
+				continue
+			}
+			filter := e.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+			if status, _ := filter.Lookup("status").StringValueOK(); status != mongodb.FetchPending {
+				t.Errorf("update filter %s would fail finished snapshots", filter)
+			}
+		}
+
+		if _, err := fileStore.Get(ctx, "3/stale.html"); !util.HaveErrorCode(err, codes.NotFound) {
+			t.Errorf("blob of the stale snapshot: got %v, want NotFound", err)
+		}
+		r, err := fileStore.Get(ctx, "4/finished.html")
+		if err != nil {
+			t.Fatalf("blob of the finished snapshot: %v", err)
+		}
+		r.Close()
+	})
+}
+
+// TestInitFailsPending checks a snapshot that went pending right before a
+// stop is failed on start, not left to block the quota of its owner.
+func TestInitFailsPending(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("fresh pending", func(mt *mtest.T) {
+		useMock(t, mt)
+		oldDir, oldClient, oldSlots := *archiveDir, client, slots
+		defer func() { *archiveDir, client, slots = oldDir, oldClient, oldSlots }()
+		*archiveDir = t.TempDir()
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		created := time.Now()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"_id", primitive.NewObjectID()}, {"webDataId", 3}, {"status", mongodb.FetchPending}, {"created", created}, {"key", "3/fresh.html"}},
+			),
+			mtest.CreateSuccessResponse(bson.E{"n", 1}, bson.E{"nModified", 1}),
+		)
+		if err := Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		events := mt.GetAllStartedEvents()
+		if len(events) != 2 || events[0].CommandName != "find" || events[1].CommandName != "update" {
+			t.Fatalf("Init sent %d commands, want a find and an update", len(events))
+		}
+		before := events[0].Command.Lookup("filter", "created", "$lt").Time()
+		if before.Before(created.Truncate(time.Millisecond)) {
+			t.Errorf("Init fails snapshots pending before %s, the one of %s stays pending", before, created)
+		}
+	})
+}
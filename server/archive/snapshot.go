@@ -0,0 +1,318 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"server/outbound"
+	"server/util"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	maxAssets   = 200
+	maxCSSDepth = 3 // of nested @import
+)
+
+// removed are elements that run code or load content we can not inline.
+var removed = map[atom.Atom]bool{
+	atom.Script: true,
+	atom.Iframe: true,
+	atom.Frame:  true,
+	atom.Object: true,
+	atom.Embed:  true,
+	atom.Applet: true,
+	atom.Base:   true,
+	atom.Source: true, // <picture> falls back to its inlined <img>
+}
+
+var (
+	cssURL    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)`)
+	cssImport = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)["']?\s*\)?[^;]*;`)
+)
+
+// snapshotter inlines the assets of one page into it, within a byte budget.
+type snapshotter struct {
+	ctx    context.Context
+	client *http.Client
+	budget int64
+	assets int
+}
+
+// takeSnapshot fetches pageURL and returns it as one html document with
+// stylesheets, images and fonts inlined as data urls. Scripts, frames and
+// event handlers are dropped, so the snapshot is static.
+func takeSnapshot(ctx context.Context, client *http.Client, pageURL string, maxSize int64) ([]byte, error) {
+	resp, err := outbound.Fetch(ctx, client, outbound.Request{
+		URL:          pageURL,
+		UserAgent:    *userAgent,
+		Accept:       "text/html",
+		MaxSize:      maxSize,
+		ContentTypes: []string{"text/html", "application/xhtml+xml"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, util.Errorf("fetch %s failed with status %d", pageURL, resp.StatusCode).WithCode(codes.Unavailable)
+	}
+	if resp.Truncated {
+		return nil, util.Errorf("page %s larger than %d bytes", pageURL, maxSize).WithCode(codes.FailedPrecondition)
+	}
+	reader, err := charset.NewReader(bytes.NewReader(resp.Body), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, util.Errorf("unsupported charset of %s", pageURL).WithCause(err).WithCode(codes.FailedPrecondition)
+	}
+	// scripts never run in a snapshot, so <noscript> content is shown
+	doc, err := html.ParseWithOptions(reader, html.ParseOptionEnableScripting(false))
+	if err != nil {
+		return nil, util.Errorf("parse %s failed", pageURL).WithCause(err).WithCode(codes.FailedPrecondition)
+	}
+	base, _ := url.Parse(resp.URL)
+
+	s := &snapshotter{ctx: ctx, client: client, budget: maxSize - int64(len(resp.Body))}
+	s.walk(doc, base)
+	s.stamp(doc, resp.URL)
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, util.Errorf("render snapshot of %s failed", pageURL).WithCause(err)
+	}
+	return out.Bytes(), nil
+}
+
+func (s *snapshotter) walk(n *html.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && s.drop(c) {
+			n.RemoveChild(c)
+		} else {
+			s.walk(c, base)
+			if c.Type == html.ElementNode {
+				s.rewrite(c, base)
+			}
+		}
+		c = next
+	}
+}
+
+// drop reports whether n is left out of the snapshot.
+func (s *snapshotter) drop(n *html.Node) bool {
+	if removed[n.DataAtom] {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Meta:
+		// the snapshot is utf-8 and must not redirect
+		return getAttr(n, "charset") != "" || strings.EqualFold(getAttr(n, "http-equiv"), "refresh") ||
+			strings.EqualFold(getAttr(n, "http-equiv"), "content-type")
+	case atom.Link:
+		rels := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+		for _, rel := range rels {
+			if rel == "stylesheet" || rel == "icon" || rel == "apple-touch-icon" {
+				return false
+			}
+		}
+		// preload, manifest, alternate and the like point outside
+		return true
+	}
+	return false
+}
+
+func (s *snapshotter) rewrite(n *html.Node, base *url.URL) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		switch {
+		case strings.HasPrefix(key, "on"), key == "srcset", key == "integrity", key == "nonce":
+			continue
+		case (key == "href" || key == "src" || key == "action" || key == "formaction") &&
+			strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:"):
+			continue
+		case key == "style":
+			a.Val = s.inlineCSS(a.Val, base, 0)
+		case key == "href" || key == "action" || key == "poster":
+			a.Val = absolute(base, a.Val)
+		case key == "src" && n.DataAtom != atom.Img:
+			a.Val = absolute(base, a.Val)
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+
+	switch n.DataAtom {
+	case atom.Img:
+		if src := getAttr(n, "src"); src != "" {
+			setAttr(n, "src", s.dataURL(base, src, "image/"))
+		}
+	case atom.Style:
+		if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+			n.FirstChild.Data = styleText(s.inlineCSS(n.FirstChild.Data, base, 0))
+		}
+	case atom.Link:
+		href := getAttr(n, "href")
+		if strings.Contains(strings.ToLower(getAttr(n, "rel")), "stylesheet") {
+			css, cssBase, ok := s.fetchCSS(base, href)
+			// turn the link into a <style> holding the sheet
+			n.DataAtom, n.Data = atom.Style, "style"
+			n.Attr = nil
+			if ok {
+				n.AppendChild(&html.Node{Type: html.TextNode, Data: styleText(s.inlineCSS(css, cssBase, 1))})
+			}
+			return
+		}
+		setAttr(n, "href", s.dataURL(base, href, "image/"))
+	}
+}
+
+// stamp records where and when the page was archived.
+func (s *snapshotter) stamp(doc *html.Node, pageURL string) {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return
+	}
+	comment := &html.Node{Type: html.CommentNode, Data: fmt.Sprintf(" archived from %s at %s ",
+		strings.ReplaceAll(pageURL, "--", "%2D%2D"), time.Now().UTC().Format(time.RFC3339))}
+	meta := &html.Node{Type: html.ElementNode, DataAtom: atom.Meta, Data: "meta",
+		Attr: []html.Attribute{{Key: "charset", Val: "utf-8"}}}
+	head.InsertBefore(comment, head.FirstChild)
+	head.InsertBefore(meta, comment)
+}
+
+// inlineCSS replaces @import rules with the imported sheets and url()
+// references with data urls.
+func (s *snapshotter) inlineCSS(css string, base *url.URL, depth int) string {
+	css = cssImport.ReplaceAllStringFunc(css, func(rule string) string {
+		if depth >= maxCSSDepth {
+			return ""
+		}
+		imported, importBase, ok := s.fetchCSS(base, cssImport.FindStringSubmatch(rule)[1])
+		if !ok {
+			return ""
+		}
+		return s.inlineCSS(imported, importBase, depth+1)
+	})
+	return cssURL.ReplaceAllStringFunc(css, func(ref string) string {
+		m := cssURL.FindStringSubmatch(ref)
+		target := m[1] + m[2] + m[3]
+		if target == "" || strings.HasPrefix(target, "#") {
+			return ref
+		}
+		return `url("` + s.dataURL(base, target, "image/", "font/", "application/font", "application/x-font", "application/vnd.ms-fontobject") + `")`
+	})
+}
+
+var styleEnd = regexp.MustCompile(`(?i)</style`)
+
+// styleText keeps css from closing its <style> element, the text of which is
+// rendered unescaped.
+func styleText(css string) string {
+	return styleEnd.ReplaceAllString(css, `<\/style`)
+}
+
+func (s *snapshotter) fetchCSS(base *url.URL, ref string) (string, *url.URL, bool) {
+	data, contentType, finalURL, ok := s.fetchAsset(base, ref)
+	if !ok || !strings.HasPrefix(contentType, "text/css") {
+		return "", nil, false
+	}
+	cssBase, err := url.Parse(finalURL)
+	if err != nil {
+		return "", nil, false
+	}
+	return string(data), cssBase, true
+}
+
+// dataURL inlines the asset at ref if its type starts with one of types,
+// otherwise it returns the absolute url of ref, which the snapshot's
+// content security policy then blocks.
+func (s *snapshotter) dataURL(base *url.URL, ref string, types ...string) string {
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	data, contentType, _, ok := s.fetchAsset(base, ref)
+	if ok {
+		for _, t := range types {
+			if strings.HasPrefix(contentType, t) {
+				return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+			}
+		}
+	}
+	return absolute(base, ref)
+}
+
+func (s *snapshotter) fetchAsset(base *url.URL, ref string) (data []byte, contentType, finalURL string, ok bool) {
+	target := absolute(base, ref)
+	if target == "" || s.assets >= maxAssets || s.budget <= 0 {
+		return nil, "", "", false
+	}
+	s.assets++
+	resp, err := outbound.Fetch(s.ctx, s.client, outbound.Request{
+		URL:       target,
+		UserAgent: *userAgent,
+		MaxSize:   s.budget,
+	})
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Truncated {
+		return nil, "", "", false
+	}
+	s.budget -= int64(len(resp.Body))
+	contentType = resp.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(resp.Body)
+		contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return resp.Body, contentType, resp.URL, true
+}
+
+// absolute resolves ref against base, keeping only http and https urls.
+func absolute(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == nil || ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+		return ""
+	}
+	return u.String()
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"server/util"
+
+	"google.golang.org/grpc/codes"
+)
+
+// BlobStore keeps snapshot files. Keys are relative slash separated paths
+// like "12/6543a1b2c3d4e5f6a7b8c9d0.html".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// stores are the blob stores selectable with -archive.store.
+var stores = map[string]func() (BlobStore, error){
+	"file": func() (BlobStore, error) { return NewFileStore(*archiveDir) },
+}
+
+func newStore(name string) (BlobStore, error) {
+	newStore, ok := stores[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown archive store %q", name)
+	}
+	return newStore()
+}
+
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*\.[a-z]+$`)
+
+// FileStore keeps blobs as files below a directory.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create archive dir %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	if !validKey.MatchString(key) {
+		return "", util.Errorf("invalid blob key %q", key).WithCode(codes.InvalidArgument)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file first, so readers never see half a blob.
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return util.Errorf("put blob %s failed", key).WithCause(err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return util.Errorf("put blob %s failed", key).WithCause(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return util.Errorf("put blob %s failed", key).WithCause(err)
+	}
+	return nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, util.Errorf("blob %s not found", key).WithCause(err).WithCode(codes.NotFound)
+		}
+		return nil, util.Errorf("get blob %s failed", key).WithCause(err)
+	}
+	return f, nil
+}
+
+// Delete removes key, deleting a missing key is no error.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return util.Errorf("delete blob %s failed", key).WithCause(err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"server/mongodb"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Write streams an archive of the instance to w. Passwords are only written
+// with secrets.
+func Write(ctx context.Context, w io.Writer, secrets bool) error {
+	return write(ctx, w, secrets, database{})
+}
+
+// source is what Write reads, the database but in tests.
+type source interface {
+	users() ([]mongodb.DBUser, error)
+	categories() ([]mongodb.Category, error)
+	tags() ([]mongodb.Tag, error)
+	eachWebData(ctx context.Context, fn func(mongodb.WebData) error) error
+}
+
+type database struct{}
+
+func (database) users() ([]mongodb.DBUser, error)        { return mongodb.GetAllUsers() }
+func (database) categories() ([]mongodb.Category, error) { return mongodb.GetAllCategories() }
+func (database) tags() ([]mongodb.Tag, error)            { return mongodb.GetAllTags(bson.M{}) }
+func (database) eachWebData(ctx context.Context, fn func(mongodb.WebData) error) error {
+	// a manager sees all web data
+	everyone := mongodb.Viewer{Name: "backup", Role: util.RoleManager}
+	return mongodb.EachWebData(ctx, bson.M{}, everyone, fn)
+}
+
+func write(ctx context.Context, w io.Writer, secrets bool, src source) error {
+	aw := &archiveWriter{enc: json.NewEncoder(w)}
+	aw.write(typeHeader, Header{Version: Version, Created: time.Now().UTC(), Secrets: secrets})
+
+	users, err := src.users()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		user := User{Name: u.Name, Role: u.Role, Heros: u.Heros}
+		if secrets {
+			user.Password = u.Password
+		}
+		aw.write(typeUser, user)
+		aw.counts.Users++
+	}
+
+	categories, err := src.categories()
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		aw.write(typeCategory, Category{ID: c.Id.Hex(), Name: c.Name})
+		aw.counts.Categories++
+	}
+
+	tags, err := src.tags()
+	if err != nil {
+		return err
+	}
+	written := map[string]bool{}
+	for _, t := range tags {
+		tag := Tag{Name: t.Name, Order: t.Order}
+		if !t.Category.IsZero() {
+			tag.Category = t.Category.Hex()
+		}
+		aw.write(typeTag, tag)
+		aw.counts.Tags++
+		written[tag.Name] = true
+	}
+
+	// tags deleted while web data still had them, written after the web
+	// data so that the archive restores
+	var missing []string
+	err = src.eachWebData(ctx, func(d mongodb.WebData) error {
+		aw.write(typeWebData, WebData{
+			ID:          d.ID,
+			Name:        d.Name,
+			Url:         d.Url,
+			Tags:        d.Tags,
+			Description: d.Description,
+			Owner:       d.Owner,
+			Visibility:  d.Visibility,
+			Created:     d.Created,
+			Updated:     d.Updated,
+		})
+		aw.counts.WebData++
+		for _, tag := range d.Tags {
+			if !written[tag] {
+				written[tag] = true
+				missing = append(missing, tag)
+			}
+		}
+		return aw.err
+	})
+	if err != nil {
+		return err
+	}
+	for i, name := range missing {
+		aw.write(typeTag, Tag{Name: name, Order: len(tags) + i})
+		aw.counts.Tags++
+	}
+
+	aw.write(typeEnd, aw.counts)
+	return aw.err
+}
+
+// archiveWriter keeps the first error, so records are written unchecked.
+type archiveWriter struct {
+	enc    *json.Encoder
+	counts Counts
+	err    error
+}
+
+func (aw *archiveWriter) write(recordType string, data any) {
+	if aw.err != nil {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err == nil {
+		err = aw.enc.Encode(Record{Type: recordType, Data: raw})
+	}
+	if err != nil {
+		aw.err = util.Errorf("write %s record failed", recordType).WithCause(err)
+	}
+}
@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"server/mongodb"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const archiveText = `{"type":"header","data":{"version":1,"created":"2024-01-02T03:04:05Z","secrets":false}}
+{"type":"user","data":{"name":"alice","role":2}}
+{"type":"category","data":{"id":"c1","name":"Languages"}}
+{"type":"tag","data":{"name":"go","order":0,"category":"c1"}}
+{"type":"tag","data":{"name":"web","order":1}}
+
+{"type":"webData","data":{"id":7,"name":"Go","url":"https://go.dev","tags":["go","web"],"owner":"alice","visibility":"team","created":"2024-01-01T00:00:00Z","updated":"2024-01-01T00:00:00Z"}}
+{"type":"end","data":{"users":1,"categories":1,"tags":2,"webData":1}}
+`
+
+func TestRead(t *testing.T) {
+	a, err := Read(strings.NewReader(archiveText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.Version != 1 || len(a.Users) != 1 || len(a.Categories) != 1 || len(a.Tags) != 2 || len(a.WebData) != 1 {
+		t.Fatalf("Read = %+v", a)
+	}
+	if a.Tags[0].Category != "c1" || a.WebData[0].Tags[1] != "web" {
+		t.Errorf("Read = %+v", a)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(archiveText), "\n")
+	cases := map[string]string{
+		"empty":       "",
+		"cut off":     strings.Join(lines[:len(lines)-1], "\n"),
+		"no header":   strings.Join(lines[1:], "\n"),
+		"new version": strings.Replace(archiveText, `"version":1`, `"version":99`, 1),
+		"count":       strings.Replace(archiveText, `"tags":2`, `"tags":3`, 1),
+		"after end":   archiveText + lines[1] + "\n",
+		"unknown":     strings.Replace(archiveText, `"type":"user"`, `"type":"robot"`, 1),
+		"bad json":    strings.Replace(archiveText, `"role":2}`, `"role":2`, 1),
+		"bad role":    strings.Replace(archiveText, `"role":2`, `"role":7`, 1),
+		"empty tag":   strings.Replace(archiveText, `["go","web"]`, `["go",""]`, 1),
+		"category":    strings.Replace(archiveText, `"category":"c1"`, `"category":"c2"`, 1),
+		"no owner":    strings.Replace(archiveText, `"owner":"alice",`, ``, 1),
+		"visibility":  strings.Replace(archiveText, `"visibility":"team"`, `"visibility":"world"`, 1),
+	}
+	for name, text := range cases {
+		if _, err := Read(strings.NewReader(text)); err == nil {
+			t.Errorf("%s: Read accepted a broken archive", name)
+		}
+	}
+}
+
+func TestReadAddsTags(t *testing.T) {
+	text := strings.Replace(archiveText, `["go","web"]`, `["go","rust"]`, 1)
+	a, err := Read(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Tags) != 3 || a.Tags[2].Name != "rust" || a.Tags[2].Category != "" {
+		t.Errorf("tags = %+v, want rust added", a.Tags)
+	}
+}
+
+// memory is a source of fixed data.
+type memory struct {
+	dbUsers      []mongodb.DBUser
+	dbCategories []mongodb.Category
+	dbTags       []mongodb.Tag
+	webData      []mongodb.WebData
+}
+
+func (m memory) users() ([]mongodb.DBUser, error)        { return m.dbUsers, nil }
+func (m memory) categories() ([]mongodb.Category, error) { return m.dbCategories, nil }
+func (m memory) tags() ([]mongodb.Tag, error)            { return m.dbTags, nil }
+func (m memory) eachWebData(ctx context.Context, fn func(mongodb.WebData) error) error {
+	for _, d := range m.webData {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriteRead(t *testing.T) {
+	category := primitive.NewObjectID()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := memory{
+		dbUsers:      []mongodb.DBUser{{Name: "alice", Password: "pw", Role: 2}},
+		dbCategories: []mongodb.Category{{Id: category, Name: "Languages"}},
+		dbTags:       []mongodb.Tag{{Name: "go", Ref: 2, Category: category}},
+		// deleted is in use but has no tag record
+		webData: []mongodb.WebData{
+			{ID: 1, Name: "Go", Url: "https://go.dev", Tags: []string{"go", "deleted"}, Owner: "alice", Visibility: mongodb.VisibilityTeam, Created: created, Updated: created},
+			{ID: 2, Name: "Blog", Url: "https://go.dev/blog", Tags: []string{"deleted", "go"}, Owner: "alice", Visibility: mongodb.VisibilityPrivate, Created: created, Updated: created},
+		},
+	}
+	var out bytes.Buffer
+	if err := write(context.Background(), &out, false, src); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Read(&out)
+	if err != nil {
+		t.Fatalf("Read of a written archive: %v", err)
+	}
+	if len(a.Users) != 1 || a.Users[0].Password != "" {
+		t.Errorf("users = %+v, want no password without secrets", a.Users)
+	}
+	if len(a.Tags) != 2 || a.Tags[0].Category != category.Hex() || a.Tags[1].Name != "deleted" {
+		t.Errorf("tags = %+v, want go and the deleted one", a.Tags)
+	}
+	if len(a.WebData) != 2 || a.WebData[1].Visibility != mongodb.VisibilityPrivate || !a.WebData[0].Created.Equal(created) {
+		t.Errorf("web data = %+v", a.WebData)
+	}
+}
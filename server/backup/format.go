@@ -0,0 +1,253 @@
+// Package backup writes the users, categories, tags and web data of an
+// instance to a versioned NDJSON archive and restores them from it.
+//
+// Every line of an archive is a Record. The first is the header, the last
+// the end record counting the records before it, so a cut off archive is
+// detected:
+//
+//	{"type":"header","data":{"version":1,"created":"...","secrets":false}}
+//	{"type":"user","data":{"name":"alice","role":0}}
+//	{"type":"category","data":{"id":"6543...","name":"Languages"}}
+//	{"type":"tag","data":{"name":"go","order":0,"category":"6543..."}}
+//	{"type":"webData","data":{"id":1,"name":"Go","url":"https://go.dev",...}}
+//	{"type":"end","data":{"users":1,"categories":1,"tags":1,"webData":1}}
+//
+// Records of a type need not be adjacent: tags the web data have but the
+// instance deleted follow the web data.
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"server/mongodb"
+	"server/util"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Version is the archive version written. Restores read all versions up
+// to it.
+const Version = 1
+
+// maxLine is the longest archive line read.
+const maxLine = 4 << 20
+
+// record types
+const (
+	typeHeader   = "header"
+	typeUser     = "user"
+	typeCategory = "category"
+	typeTag      = "tag"
+	typeWebData  = "webData"
+	typeEnd      = "end"
+)
+
+type Record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type Header struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Secrets tells whether users have their passwords.
+	Secrets bool `json:"secrets"`
+}
+
+type User struct {
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+	Role     int    `json:"role"`
+	Heros    []int  `json:"heros,omitempty"`
+}
+
+// Category is identified by ID within the archive only.
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Tag struct {
+	Name     string `json:"name"`
+	Order    int    `json:"order"`
+	Category string `json:"category,omitempty"`
+}
+
+// WebData leaves out what the crawler and link checker find again.
+type WebData struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Url         string    `json:"url"`
+	Tags        []string  `json:"tags,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner"`
+	Visibility  string    `json:"visibility"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Counts are the records of each type, written in the end record.
+type Counts struct {
+	Users      int `json:"users"`
+	Categories int `json:"categories"`
+	Tags       int `json:"tags"`
+	WebData    int `json:"webData"`
+}
+
+// Archive is a read and validated archive.
+type Archive struct {
+	Header     Header
+	Users      []User
+	Categories []Category
+	Tags       []Tag
+	WebData    []WebData
+}
+
+// Read reads and validates a whole archive, nothing is restored from a
+// broken one.
+func Read(r io.Reader) (*Archive, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	a := &Archive{}
+	line, records, ended := 0, 0, false
+	var end Counts
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if ended {
+			return nil, lineError(line, "data after the end record")
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, lineError(line, "invalid json").WithCause(err)
+		}
+		records++
+		if records == 1 && rec.Type != typeHeader {
+			return nil, lineError(line, "archive does not start with a header")
+		}
+		var err error
+		switch rec.Type {
+		case typeHeader:
+			if records != 1 {
+				return nil, lineError(line, "second header")
+			}
+			if err = decode(rec.Data, &a.Header); err == nil && (a.Header.Version < 1 || a.Header.Version > Version) {
+				return nil, lineError(line, "unsupported archive version %d", a.Header.Version)
+			}
+		case typeUser:
+			var u User
+			err = decode(rec.Data, &u)
+			a.Users = append(a.Users, u)
+		case typeCategory:
+			var c Category
+			err = decode(rec.Data, &c)
+			a.Categories = append(a.Categories, c)
+		case typeTag:
+			var t Tag
+			err = decode(rec.Data, &t)
+			a.Tags = append(a.Tags, t)
+		case typeWebData:
+			var d WebData
+			err = decode(rec.Data, &d)
+			a.WebData = append(a.WebData, d)
+		case typeEnd:
+			err = decode(rec.Data, &end)
+			ended = true
+		default:
+			return nil, lineError(line, "unknown record type %q", rec.Type)
+		}
+		if err != nil {
+			return nil, lineError(line, "invalid %s record", rec.Type).WithCause(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, util.Errorf("read archive failed after line %d", line).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	if records == 0 {
+		return nil, util.Errorf("empty archive").WithCode(codes.InvalidArgument)
+	}
+	if !ended {
+		return nil, util.Errorf("archive has no end record, it is cut off").WithCode(codes.InvalidArgument)
+	}
+	if got := a.counts(); got != end {
+		return nil, util.Errorf("archive has %+v records, its end record counts %+v", got, end).WithCode(codes.InvalidArgument)
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func decode(data json.RawMessage, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func lineError(line int, format string, a ...any) *util.Error {
+	return util.Errorf("archive line %d: "+format, append([]any{line}, a...)...).WithCode(codes.InvalidArgument)
+}
+
+func (a *Archive) counts() Counts {
+	return Counts{Users: len(a.Users), Categories: len(a.Categories), Tags: len(a.Tags), WebData: len(a.WebData)}
+}
+
+// validate checks names are unique and references resolve. Tags of web data
+// without a tag record are added, older archives lack them.
+func (a *Archive) validate() error {
+	invalid := func(format string, args ...any) error {
+		return util.Errorf("invalid archive: "+format, args...).WithCode(codes.InvalidArgument)
+	}
+	users := map[string]bool{}
+	for _, u := range a.Users {
+		if u.Name == "" || users[u.Name] {
+			return invalid("empty or repeated user name %q", u.Name)
+		}
+		if u.Role < int(util.RolePlayer) || u.Role > int(util.RoleAdmin) {
+			return invalid("user %s has unknown role %d", u.Name, u.Role)
+		}
+		users[u.Name] = true
+	}
+	categories := map[string]bool{}
+	for _, c := range a.Categories {
+		if c.ID == "" || c.Name == "" || categories[c.ID] {
+			return invalid("category %q has an empty or repeated id %q", c.Name, c.ID)
+		}
+		categories[c.ID] = true
+	}
+	tags := map[string]bool{}
+	for _, t := range a.Tags {
+		if t.Name == "" || tags[t.Name] {
+			return invalid("empty or repeated tag name %q", t.Name)
+		}
+		if t.Category != "" && !categories[t.Category] {
+			return invalid("tag %s has unknown category %s", t.Name, t.Category)
+		}
+		tags[t.Name] = true
+	}
+	ids := map[int]bool{}
+	for _, d := range a.WebData {
+		if ids[d.ID] {
+			return invalid("repeated web data id %d", d.ID)
+		}
+		ids[d.ID] = true
+		if d.Url == "" || d.Owner == "" {
+			return invalid("web data %d has no url or owner", d.ID)
+		}
+		if d.Visibility != "" && !mongodb.ValidVisibility(d.Visibility) {
+			return invalid("web data %d has unknown visibility %s", d.ID, d.Visibility)
+		}
+		for _, tag := range d.Tags {
+			if tag == "" {
+				return invalid("web data %d has an empty tag", d.ID)
+			}
+			if !tags[tag] {
+				tags[tag] = true
+				a.Tags = append(a.Tags, Tag{Name: tag, Order: len(a.Tags)})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"context"
+	"server/archive"
+	"server/crawler"
+	"server/mongodb"
+	"server/urlnorm"
+	"server/util"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+// restore modes
+const (
+	// ModeMerge adds what the instance does not have and keeps the rest.
+	ModeMerge = "merge"
+	// ModeReplace deletes all data and users before restoring.
+	ModeReplace = "replace"
+)
+
+type RestoreOptions struct {
+	// Mode is ModeMerge or ModeReplace, merge by default.
+	Mode string
+	// Keep is a user replace mode does not delete, the admin restoring.
+	Keep string
+}
+
+// Count tells what a restore did with the records of a type.
+type Count struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+}
+
+type Report struct {
+	Mode       string `json:"mode"`
+	Users      Count  `json:"users"`
+	Categories Count  `json:"categories"`
+	Tags       Count  `json:"tags"`
+	WebData    Count  `json:"webData"`
+}
+
+// Restore restores a read archive. Web data get new IDs, tags and
+// categories are linked again by name, and tag refs are counted anew.
+// Merge mode skips users, categories, tags and urls the instance has
+// already, replace mode overwrites users of the archive and deletes the
+// others, except opts.Keep.
+func Restore(ctx context.Context, a *Archive, opts RestoreOptions) (Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeMerge
+	}
+	report := Report{Mode: opts.Mode}
+	if opts.Mode != ModeMerge && opts.Mode != ModeReplace {
+		return report, util.Errorf("unknown restore mode %s", opts.Mode).WithCode(codes.InvalidArgument)
+	}
+	replace := opts.Mode == ModeReplace
+	if replace {
+		if err := clearInstance(ctx, a, opts.Keep, &report); err != nil {
+			return report, err
+		}
+	}
+	if err := restoreUsers(ctx, a.Users, replace, &report.Users); err != nil {
+		return report, err
+	}
+	categories, err := restoreCategories(a.Categories, &report.Categories)
+	if err != nil {
+		return report, err
+	}
+	if err := restoreTags(ctx, a.Tags, categories, &report.Tags); err != nil {
+		return report, err
+	}
+	if err := restoreWebData(a.WebData, &report.WebData); err != nil {
+		return report, err
+	}
+	return report, mongodb.RecountTagRefs(ctx)
+}
+
+// clearInstance deletes the data of the instance, and the users not in a.
+func clearInstance(ctx context.Context, a *Archive, keep string, report *Report) error {
+	everyone := mongodb.Viewer{Name: "backup", Role: util.RoleManager}
+	var ids []int
+	err := mongodb.EachWebData(ctx, bson.M{}, everyone, func(d mongodb.WebData) error {
+		ids = append(ids, d.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := archive.DeleteAll(ctx, id); err != nil {
+			return err
+		}
+	}
+	tags, err := mongodb.GetAllTags(bson.M{})
+	if err != nil {
+		return err
+	}
+	categories, err := mongodb.GetAllCategories()
+	if err != nil {
+		return err
+	}
+	if err := mongodb.ClearData(ctx); err != nil {
+		return err
+	}
+	report.WebData.Deleted = len(ids)
+	report.Tags.Deleted = len(tags)
+	report.Categories.Deleted = len(categories)
+
+	names := []string{}
+	if keep != "" {
+		names = append(names, keep)
+	}
+	for _, u := range a.Users {
+		names = append(names, u.Name)
+	}
+	report.Users.Deleted, err = mongodb.DeleteUsersExcept(ctx, names)
+	return err
+}
+
+func restoreUsers(ctx context.Context, users []User, replace bool, count *Count) error {
+	existing := map[string]bool{}
+	if !replace {
+		all, err := mongodb.GetAllUsers()
+		if err != nil {
+			return err
+		}
+		for _, u := range all {
+			existing[u.Name] = true
+		}
+	}
+	for _, u := range users {
+		if existing[u.Name] {
+			count.Skipped++
+			continue
+		}
+		created, err := mongodb.RestoreUser(ctx, mongodb.DBUser{Name: u.Name, Password: u.Password, Role: u.Role, Heros: u.Heros})
+		if err != nil {
+			return err
+		}
+		if created {
+			count.Created++
+		} else {
+			count.Updated++
+		}
+	}
+	return nil
+}
+
+// restoreCategories returns the IDs of the instance by archive ID. A
+// category named like one of the instance becomes that one.
+func restoreCategories(categories []Category, count *Count) (map[string]primitive.ObjectID, error) {
+	existing, err := mongodb.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]primitive.ObjectID{}
+	for _, c := range existing {
+		byName[strings.ToLower(c.Name)] = c.Id
+	}
+	ids := map[string]primitive.ObjectID{}
+	for _, c := range categories {
+		if id, ok := byName[strings.ToLower(c.Name)]; ok {
+			ids[c.ID] = id
+			count.Skipped++
+			continue
+		}
+		id, err := mongodb.AddCategory(mongodb.Category{Name: c.Name})
+		if err != nil {
+			return nil, err
+		}
+		ids[c.ID] = id
+		byName[strings.ToLower(c.Name)] = id
+		count.Created++
+	}
+	return ids, nil
+}
+
+func restoreTags(ctx context.Context, tags []Tag, categories map[string]primitive.ObjectID, count *Count) error {
+	for _, t := range tags {
+		err := mongodb.InsertTag(ctx, mongodb.Tag{Name: t.Name, Order: t.Order, Category: categories[t.Category]})
+		if util.HaveErrorCode(err, codes.AlreadyExists) {
+			count.Skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		count.Created++
+	}
+	return nil
+}
+
+// restoreWebData saves the web data with new IDs and queues fetching
+// their pages, the crawler finds icons and link states again.
+func restoreWebData(items []WebData, count *Count) error {
+	for _, d := range items {
+		canonical, err := urlnorm.Normalize(d.Url)
+		if err != nil {
+			count.Skipped++
+			continue
+		}
+		_, err = mongodb.GetWebDataByCanonical(d.Owner, canonical)
+		if err == nil {
+			count.Skipped++
+			continue
+		}
+		if !util.HaveErrorCode(err, codes.NotFound) {
+			return err
+		}
+		visibility := d.Visibility
+		if visibility == "" {
+			visibility = mongodb.VisibilityTeam
+		}
+		webData := mongodb.WebData{
+			Name:        d.Name,
+			Url:         d.Url,
+			Canonical:   canonical,
+			Tags:        d.Tags,
+			Description: d.Description,
+			Owner:       d.Owner,
+			Visibility:  visibility,
+			Created:     d.Created,
+			Updated:     d.Updated,
+			Fetch:       &mongodb.FetchInfo{Status: mongodb.FetchPending},
+			Enrich:      mongodb.FetchPending,
+		}
+		id, err := mongodb.AddWebData(webData)
+		if util.HaveErrorCode(err, codes.AlreadyExists) {
+			count.Skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		crawler.Enqueue(id, webData.Url)
+		count.Created++
+	}
+	return nil
+}
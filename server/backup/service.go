@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/util"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+var maxRestoreSize = &config.Current.Backup.MaxRestoreSize
+
+func requireAdmin(r *http.Request) (string, error) {
+	user, role, err := util.GetUser(r)
+	if err != nil {
+		return "", err
+	}
+	if role < util.RoleAdmin {
+		return "", util.Errorf("admin role required").WithCode(codes.PermissionDenied)
+	}
+	return user, nil
+}
+
+// HandleBackup streams an archive of the instance, with passwords when
+// secrets=true.
+func HandleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	user, err := requireAdmin(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	var secrets bool
+	if s := r.URL.Query().Get("secrets"); s != "" {
+		if secrets, err = strconv.ParseBool(s); err != nil {
+			util.WriteError(w, r, util.Errorf("invalid secrets %s", s).WithCause(err).WithCode(codes.InvalidArgument))
+			return
+		}
+	}
+	logrus.Infof("backup by %s, secrets: %v", user, secrets)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="backup-%s.ndjson"`, time.Now().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+	// the status is sent already, a cut off archive lacks its end record
+	if err := Write(r.Context(), w, secrets); err != nil {
+		logrus.Errorf("backup failed: %v", err)
+	}
+}
+
+// HandleRestore restores the archive of the body, mode=merge or replace.
+func HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	user, err := requireAdmin(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	a, err := Read(http.MaxBytesReader(w, r.Body, *maxRestoreSize))
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	logrus.Infof("restore by %s, mode: %s", user, r.URL.Query().Get("mode"))
+	// a restore half done by a closed connection is worse than a slow one
+	report, err := Restore(context.Background(), a, RestoreOptions{Mode: r.URL.Query().Get("mode"), Keep: user})
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(report))
+}
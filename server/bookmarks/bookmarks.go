@@ -0,0 +1,19 @@
+// Package bookmarks reads and writes the bookmark files of browsers and
+// other bookmark services.
+package bookmarks
+
+import "time"
+
+// Bookmark is one entry of a bookmark file.
+type Bookmark struct {
+	// ID is the id the file gives the bookmark, 0 if it has none.
+	ID          int
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	// Folders is the folder path of the bookmark, outermost first.
+	Folders []string
+	Created time.Time
+	Updated time.Time
+}
@@ -0,0 +1,257 @@
+package bookmarks
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ParseNetscape reads a Netscape bookmark file, the html format every
+// browser exports. The folders holding a bookmark become its Folders,
+// except the toolbar and unfiled folders browsers add themselves.
+func ParseNetscape(r io.Reader) ([]Bookmark, error) {
+	reader, err := charset.NewReader(r, "text/html")
+	if err != nil {
+		return nil, err
+	}
+	z := xhtml.NewTokenizer(reader)
+
+	var (
+		result  []Bookmark
+		folders []string // path of the current <DL>, "" for skipped folders
+		// folder is the <H3> read last, it names the next <DL>
+		folder     *string
+		inFolder   bool
+		current    *Bookmark
+		inLink     bool
+		inDescribe bool
+		text       strings.Builder
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Title = strings.TrimSpace(current.Title)
+		current.Description = strings.TrimSpace(current.Description)
+		result = append(result, *current)
+		current = nil
+	}
+	for {
+		tt := z.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			if z.Err() == io.EOF {
+				flush()
+				return result, nil
+			}
+			return nil, z.Err()
+		case xhtml.TextToken:
+			switch {
+			case inLink:
+				current.Title += string(z.Text())
+			case inFolder:
+				text.Write(z.Text())
+			case inDescribe && current != nil:
+				current.Description += string(z.Text())
+			}
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+			switch atom.Lookup(name) {
+			case atom.A:
+				flush()
+				current = &Bookmark{
+					URL:     strings.TrimSpace(attrs["href"]),
+					Tags:    splitTags(attrs["tags"]),
+					Folders: pathOf(folders),
+					Created: parseDate(attrs["add_date"]),
+					Updated: parseDate(attrs["last_modified"]),
+				}
+				inLink, inDescribe = true, false
+			case atom.H3:
+				flush()
+				inFolder, inDescribe = true, false
+				text.Reset()
+				if attrs["personal_toolbar_folder"] == "true" || attrs["unfiled_bookmarks_folder"] == "true" {
+					// browsers add these, they are no folder of the user
+					folder = new(string)
+					inFolder = false
+				} else {
+					folder = nil
+				}
+			case atom.Dd:
+				inDescribe = true
+			case atom.Dl:
+				flush()
+				inDescribe = false
+				name := ""
+				if folder != nil {
+					name = *folder
+				}
+				folders = append(folders, name)
+				folder = nil
+			case atom.Dt:
+				flush()
+				inDescribe = false
+			}
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.A:
+				inLink = false
+			case atom.H3:
+				if inFolder {
+					name := strings.TrimSpace(text.String())
+					folder = &name
+				}
+				inFolder = false
+			case atom.Dl:
+				flush()
+				inDescribe = false
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			}
+		}
+	}
+}
+
+// pathOf returns the named folders of the <DL> stack.
+func pathOf(folders []string) []string {
+	var path []string
+	for _, f := range folders {
+		if f != "" {
+			path = append(path, f)
+		}
+	}
+	return path
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseDate reads a unix time in seconds, or in the milli and microseconds
+// some exporters write.
+func parseDate(s string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	switch {
+	case n > 1e14:
+		return time.UnixMicro(n)
+	case n > 1e11:
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`
+
+// folderTree groups bookmarks by their Folders for writing.
+type folderTree struct {
+	bookmarks []Bookmark
+	children  map[string]*folderTree
+}
+
+func (t *folderTree) add(b Bookmark, path []string) {
+	if len(path) == 0 {
+		t.bookmarks = append(t.bookmarks, b)
+		return
+	}
+	if t.children == nil {
+		t.children = map[string]*folderTree{}
+	}
+	child, ok := t.children[path[0]]
+	if !ok {
+		child = &folderTree{}
+		t.children[path[0]] = child
+	}
+	child.add(b, path[1:])
+}
+
+// WriteNetscape writes bookmarks as a Netscape bookmark file, nested in
+// their Folders. Tags are kept in the TAGS attribute Firefox reads.
+func WriteNetscape(w io.Writer, bookmarks []Bookmark) error {
+	root := &folderTree{}
+	for _, b := range bookmarks {
+		root.add(b, b.Folders)
+	}
+	ew := &errWriter{w: w}
+	io.WriteString(ew, netscapeHeader)
+	writeFolder(ew, root, "")
+	return ew.err
+}
+
+func writeFolder(w io.Writer, t *folderTree, indent string) {
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+	inner := indent + "    "
+	names := make([]string, 0, len(t.children))
+	for name := range t.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s<DT><H3>%s</H3>\n", inner, html.EscapeString(name))
+		writeFolder(w, t.children[name], inner)
+	}
+	for _, b := range t.bookmarks {
+		fmt.Fprintf(w, `%s<DT><A HREF="%s"`, inner, html.EscapeString(b.URL))
+		if !b.Created.IsZero() {
+			fmt.Fprintf(w, ` ADD_DATE="%d"`, b.Created.Unix())
+		}
+		if !b.Updated.IsZero() {
+			fmt.Fprintf(w, ` LAST_MODIFIED="%d"`, b.Updated.Unix())
+		}
+		if len(b.Tags) > 0 {
+			fmt.Fprintf(w, ` TAGS="%s"`, html.EscapeString(strings.Join(b.Tags, ",")))
+		}
+		fmt.Fprintf(w, ">%s</A>\n", html.EscapeString(b.Title))
+		if b.Description != "" {
+			fmt.Fprintf(w, "%s<DD>%s\n", inner, html.EscapeString(b.Description))
+		}
+	}
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
+
+// errWriter keeps the first write error, so writing goes on unchecked.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
@@ -0,0 +1,71 @@
+package bookmarks
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chromeExport = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file. -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" LAST_MODIFIED="1700000001" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000100" ICON="data:image/png;base64,AAAA">The Go &amp; Programming Language</A>
+        <DT><H3 ADD_DATE="1700000000">Dev</H3>
+        <DL><p>
+            <DT><H3>Rust</H3>
+            <DL><p>
+                <DT><A HREF="https://www.rust-lang.org/" ADD_DATE="1700000200000" TAGS="lang,systems">Rust</A>
+                <DD>A language empowering everyone
+            </DL><p>
+            <DT><A HREF="javascript:alert(1)">bookmarklet</A>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF="https://example.com/">Example</A>
+</DL><p>
+`
+
+func TestParseNetscape(t *testing.T) {
+	got, err := ParseNetscape(strings.NewReader(chromeExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{
+		{URL: "https://go.dev/", Title: "The Go & Programming Language", Created: time.Unix(1700000100, 0)},
+		{URL: "https://www.rust-lang.org/", Title: "Rust", Description: "A language empowering everyone",
+			Tags: []string{"lang", "systems"}, Folders: []string{"Dev", "Rust"}, Created: time.UnixMilli(1700000200000)},
+		{URL: "javascript:alert(1)", Title: "bookmarklet", Folders: []string{"Dev"}},
+		{URL: "https://example.com/", Title: "Example"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNetscape =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestWriteNetscapeRoundTrip(t *testing.T) {
+	in := []Bookmark{
+		{URL: "https://example.com/?a=1&b=2", Title: `"Quoted" <title>`, Description: "about",
+			Tags: []string{"web"}, Folders: []string{"web"}, Created: time.Unix(1600000000, 0), Updated: time.Unix(1600000500, 0)},
+		{URL: "https://go.dev/", Title: "Go"},
+		{URL: "https://doc.rust-lang.org/", Title: "Rust docs", Folders: []string{"lang", "rust"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteNetscape(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ParseNetscape(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// folders are written before the bookmarks of a level, sorted by name
+	want := []Bookmark{in[2], in[0], in[1]}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", out, want)
+	}
+}
@@ -0,0 +1,37 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+type pinboardPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Time        string `json:"time"`
+	Tags        string `json:"tags"`
+}
+
+// ParsePinboard reads the json export of Pinboard. Its description is the
+// title and its extended description the notes, tags are space separated.
+func ParsePinboard(r io.Reader) ([]Bookmark, error) {
+	var posts []pinboardPost
+	if err := json.NewDecoder(r).Decode(&posts); err != nil {
+		return nil, err
+	}
+	result := make([]Bookmark, 0, len(posts))
+	for _, p := range posts {
+		created, _ := time.Parse(time.RFC3339, p.Time)
+		result = append(result, Bookmark{
+			URL:         strings.TrimSpace(p.Href),
+			Title:       strings.TrimSpace(p.Description),
+			Description: strings.TrimSpace(p.Extended),
+			Tags:        append([]string(nil), strings.Fields(p.Tags)...),
+			Created:     created,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package bookmarks
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParsePocket reads the export of Pocket, the html file of older exports or
+// the csv file of newer ones.
+func ParsePocket(r io.Reader) ([]Bookmark, error) {
+	br := bufio.NewReader(r)
+	start, _ := br.Peek(512)
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(start, []byte("\ufeff"))), []byte("<")) {
+		return parsePocketHTML(br)
+	}
+	return parsePocketCSV(br)
+}
+
+// parsePocketHTML reads the lists of links of ril_export.html:
+//
+//	<li><a href="https://go.dev" time_added="1600000000" tags="go,lang">Go</a></li>
+func parsePocketHTML(r io.Reader) ([]Bookmark, error) {
+	z := xhtml.NewTokenizer(r)
+	var (
+		result  []Bookmark
+		current *Bookmark
+	)
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			if z.Err() == io.EOF {
+				return result, nil
+			}
+			return nil, z.Err()
+		case xhtml.StartTagToken:
+			name, hasAttr := z.TagName()
+			if atom.Lookup(name) != atom.A {
+				continue
+			}
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+			current = &Bookmark{
+				URL:     strings.TrimSpace(attrs["href"]),
+				Tags:    splitTags(attrs["tags"]),
+				Created: parseDate(attrs["time_added"]),
+			}
+		case xhtml.TextToken:
+			if current != nil {
+				current.Title += string(z.Text())
+			}
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			if atom.Lookup(name) == atom.A && current != nil {
+				current.Title = strings.TrimSpace(current.Title)
+				result = append(result, *current)
+				current = nil
+			}
+		}
+	}
+}
+
+// parsePocketCSV reads part_000000.csv, with tags separated by "|":
+//
+//	title,url,time_added,tags,status
+func parsePocketCSV(r io.Reader) ([]Bookmark, error) {
+	records, err := readCSV(r, "url")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Bookmark, 0, len(records))
+	for _, record := range records {
+		var tags []string
+		for _, tag := range strings.Split(record["tags"], "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		result = append(result, Bookmark{
+			URL:     record["url"],
+			Title:   record["title"],
+			Tags:    tags,
+			Created: parseDate(record["time_added"]),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package bookmarks
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseRaindrop reads the csv export of Raindrop.io:
+//
+//	id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite
+//
+// The note, or else the excerpt, becomes the description. Nested folders
+// are written as "Parent/Child", the Unsorted folder is no folder.
+func ParseRaindrop(r io.Reader) ([]Bookmark, error) {
+	records, err := readCSV(r, "url")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Bookmark, 0, len(records))
+	for _, record := range records {
+		description := record["note"]
+		if description == "" {
+			description = record["excerpt"]
+		}
+		var folders []string
+		if folder := record["folder"]; folder != "" && folder != "Unsorted" {
+			for _, f := range strings.Split(folder, "/") {
+				if f = strings.TrimSpace(f); f != "" {
+					folders = append(folders, f)
+				}
+			}
+		}
+		created, _ := time.Parse(time.RFC3339, record["created"])
+		result = append(result, Bookmark{
+			URL:         record["url"],
+			Title:       record["title"],
+			Description: description,
+			Tags:        splitTags(record["tags"]),
+			Folders:     folders,
+			Created:     created,
+		})
+	}
+	return result, nil
+}
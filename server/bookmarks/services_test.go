@@ -0,0 +1,83 @@
+package bookmarks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePinboard(t *testing.T) {
+	got, err := ParsePinboard(strings.NewReader(`[
+{"href":"https://go.dev/","description":"Go","extended":"notes","meta":"x","hash":"y","time":"2020-01-02T03:04:05Z","shared":"yes","toread":"no","tags":"go lang"},
+{"href":"https://example.com/","description":"","extended":"","time":"bad","tags":""}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{
+		{URL: "https://go.dev/", Title: "Go", Description: "notes", Tags: []string{"go", "lang"},
+			Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{URL: "https://example.com/"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePinboard =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParsePocket(t *testing.T) {
+	want := []Bookmark{
+		{URL: "https://go.dev/", Title: "Go", Tags: []string{"go", "lang"}, Created: time.Unix(1600000000, 0)},
+		{URL: "https://example.com/", Title: "Example", Created: time.Unix(1600000100, 0)},
+	}
+
+	html, err := ParsePocket(strings.NewReader(`<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://go.dev/" time_added="1600000000" tags="go,lang">Go</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://example.com/" time_added="1600000100" tags="">Example</a></li>
+</ul>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(html, want) {
+		t.Errorf("ParsePocket html =\n%+v\nwant\n%+v", html, want)
+	}
+
+	csv, err := ParsePocket(strings.NewReader("\ufefftitle,url,time_added,tags,status\n" +
+		"Go,https://go.dev/,1600000000,go|lang,unread\n" +
+		"Example,https://example.com/,1600000100,,archive\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(csv, want) {
+		t.Errorf("ParsePocket csv =\n%+v\nwant\n%+v", csv, want)
+	}
+}
+
+func TestParseRaindrop(t *testing.T) {
+	got, err := ParseRaindrop(strings.NewReader(`id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite
+1,Go,my note,an excerpt,https://go.dev/,Dev/Languages,"go, lang",2023-05-01T10:00:00.000Z,,,false
+2,Example,,an excerpt,https://example.com/,Unsorted,,2023-05-02T10:00:00.000Z,,,true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{
+		{URL: "https://go.dev/", Title: "Go", Description: "my note", Tags: []string{"go", "lang"},
+			Folders: []string{"Dev", "Languages"}, Created: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{URL: "https://example.com/", Title: "Example", Description: "an excerpt",
+			Created: time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRaindrop =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := ParseRaindrop(strings.NewReader("id,title\n1,x\n")); err == nil {
+		t.Error("ParseRaindrop accepted a file without url column")
+	}
+}
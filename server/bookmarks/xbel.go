@@ -0,0 +1,183 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ParseXBEL reads an XBEL file, the format of bookmark sync tools like
+// Floccus. Separators and aliases are skipped.
+func ParseXBEL(r io.Reader) ([]Bookmark, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := xml.NewDecoder(bytes.NewReader(floccusComment.ReplaceAll(data, nil)))
+	d.CharsetReader = charset.NewReaderLabel
+	d.Entity = xml.HTMLEntity
+
+	var (
+		result  []Bookmark
+		folders []string // path of the current <folder>
+		current *Bookmark
+		// text collects the <title> or <desc> being read, of current or
+		// of the innermost folder
+		text    *strings.Builder
+		inTitle bool
+		seenXML bool
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			if !seenXML {
+				return nil, fmt.Errorf("no xbel element")
+			}
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "xbel":
+				seenXML = true
+			case "folder":
+				folders = append(folders, "")
+			case "bookmark":
+				current = &Bookmark{Folders: append([]string(nil), folders...)}
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "href":
+						current.URL = strings.TrimSpace(attr.Value)
+					case "id":
+						current.ID, _ = strconv.Atoi(attr.Value)
+					case "added":
+						current.Created, _ = time.Parse(time.RFC3339, attr.Value)
+					case "modified":
+						current.Updated, _ = time.Parse(time.RFC3339, attr.Value)
+					}
+				}
+			case "title", "desc":
+				text, inTitle = &strings.Builder{}, t.Name.Local == "title"
+			case "info":
+				// metadata of other applications
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.CharData:
+			if text != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "title", "desc":
+				if text == nil {
+					break
+				}
+				s := strings.TrimSpace(text.String())
+				switch {
+				case current != nil && inTitle:
+					current.Title = s
+				case current != nil:
+					current.Description = s
+				case inTitle && len(folders) > 0:
+					folders[len(folders)-1] = s
+				}
+				text = nil
+			case "bookmark":
+				if current != nil {
+					result = append(result, *current)
+					current = nil
+				}
+			case "folder":
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			}
+		}
+	}
+}
+
+// floccusComment is no valid xml comment, "--" is not allowed in it.
+var floccusComment = regexp.MustCompile(`<!---[^>]*--->`)
+
+const xbelHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE xbel PUBLIC "+//IDN python.org//DTD XML Bookmark Exchange Language 1.0//EN//XML" "http://pyxml.sourceforge.net/topics/dtds/xbel.dtd">
+<xbel version="1.0">
+`
+
+// WriteXBEL writes bookmarks as an XBEL file, nested in their Folders.
+// Floccus wants ids on folders too, they are numbered after the highest
+// bookmark ID, which the file names in the comment Floccus reads.
+func WriteXBEL(w io.Writer, bookmarks []Bookmark) error {
+	root := &folderTree{}
+	highest := 0
+	for _, b := range bookmarks {
+		root.add(b, b.Folders)
+		if b.ID > highest {
+			highest = b.ID
+		}
+	}
+	ew := &errWriter{w: w}
+	io.WriteString(ew, xbelHeader)
+	x := &xbelWriter{w: ew, nextID: highest + 1}
+	x.writeItems(root, "")
+	fmt.Fprintf(ew, "<!--- highestId :%d: for Floccus bookmark sync browser extension --->\n", x.nextID-1)
+	io.WriteString(ew, "</xbel>\n")
+	return ew.err
+}
+
+type xbelWriter struct {
+	w      io.Writer
+	nextID int
+}
+
+func (x *xbelWriter) writeItems(t *folderTree, indent string) {
+	inner := indent + "  "
+	names := make([]string, 0, len(t.children))
+	for name := range t.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(x.w, "%s<folder id=\"%d\">\n", inner, x.nextID)
+		x.nextID++
+		fmt.Fprintf(x.w, "%s  <title>%s</title>\n", inner, escapeXML(name))
+		x.writeItems(t.children[name], inner)
+		fmt.Fprintf(x.w, "%s</folder>\n", inner)
+	}
+	for _, b := range t.bookmarks {
+		fmt.Fprintf(x.w, `%s<bookmark href="%s"`, inner, escapeXML(b.URL))
+		if b.ID != 0 {
+			fmt.Fprintf(x.w, ` id="%d"`, b.ID)
+		}
+		if !b.Created.IsZero() {
+			fmt.Fprintf(x.w, ` added="%s"`, b.Created.UTC().Format(time.RFC3339))
+		}
+		if !b.Updated.IsZero() {
+			fmt.Fprintf(x.w, ` modified="%s"`, b.Updated.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintf(x.w, ">\n%s  <title>%s</title>\n", inner, escapeXML(b.Title))
+		if b.Description != "" {
+			fmt.Fprintf(x.w, "%s  <desc>%s</desc>\n", inner, escapeXML(b.Description))
+		}
+		fmt.Fprintf(x.w, "%s</bookmark>\n", inner)
+	}
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package bookmarks
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// as Floccus writes it
+const floccusXBEL = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE xbel PUBLIC "+//IDN python.org//DTD XML Bookmark Exchange Language 1.0//EN//XML" "http://pyxml.sourceforge.net/topics/dtds/xbel.dtd">
+<xbel version="1.0">
+<!--- highestId :12: for Floccus bookmark sync browser extension --->
+<folder id="10">
+<title>Dev &amp; Ops</title>
+<folder id="11">
+<title>go</title>
+<bookmark href="https://go.dev/" id="3">
+<title>The Go Programming Language</title>
+<info><metadata owner="other"><title>ignored</title></metadata></info>
+</bookmark>
+</folder>
+<separator/>
+</folder>
+<bookmark href="https://example.com/?a=1&amp;b=2" id="12">
+<title>Example</title>
+<desc>about</desc>
+</bookmark>
+</xbel>`
+
+func TestParseXBEL(t *testing.T) {
+	got, err := ParseXBEL(strings.NewReader(floccusXBEL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{
+		{ID: 3, URL: "https://go.dev/", Title: "The Go Programming Language", Folders: []string{"Dev & Ops", "go"}},
+		{ID: 12, URL: "https://example.com/?a=1&b=2", Title: "Example", Description: "about"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseXBEL =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := ParseXBEL(strings.NewReader("<html></html>")); err == nil {
+		t.Error("ParseXBEL accepted a file without xbel element")
+	}
+}
+
+func TestWriteXBELRoundTrip(t *testing.T) {
+	in := []Bookmark{
+		{ID: 7, URL: "https://example.com/?a=1&b=2", Title: `"Quoted" <title>`, Description: "about",
+			Folders: []string{"web"}, Created: time.Unix(1600000000, 0).UTC(), Updated: time.Unix(1600000500, 0).UTC()},
+		{ID: 2, URL: "https://go.dev/", Title: "Go"},
+	}
+	var buf bytes.Buffer
+	if err := WriteXBEL(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<folder id="8">`) || !strings.Contains(buf.String(), "highestId :8:") {
+		t.Errorf("folder ids do not follow the bookmark ids:\n%s", buf.String())
+	}
+	out, err := ParseXBEL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", out, in)
+	}
+}
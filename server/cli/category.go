@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"server/api"
+)
+
+func runCategory(e *env, args []string) error {
+	return subcommand(e, "category", args, map[string]func(*env, []string) error{
+		"list":   categoryList,
+		"rename": categoryRename,
+	})
+}
+
+func categoryList(e *env, args []string) error {
+	categories, err := e.client.Categories()
+	if err != nil {
+		return err
+	}
+	return e.out.categories(categories)
+}
+
+func categoryRename(e *env, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: webstorage category rename category name")
+	}
+	categories, err := e.client.Categories()
+	if err != nil {
+		return err
+	}
+	id, err := findCategory(categories, args[0])
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("category %s not found", args[0])
+	}
+	return e.client.UpdateCategory(id, api.CategoryUpdate{Name: args[1]})
+}
+
+// findCategory returns the id of the category named or identified by s, ""
+// for none.
+func findCategory(categories []api.Category, s string) (string, error) {
+	if s == "none" || s == "" {
+		return "", nil
+	}
+	for _, c := range categories {
+		if c.Name == s || c.ID == s {
+			return c.ID, nil
+		}
+	}
+	return "", fmt.Errorf("category %s not found", s)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config is what login keeps for the next commands.
+type config struct {
+	Server string `json:"server"`
+	User   string `json:"user,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+const defaultServer = "http://localhost:8071"
+
+// configPath is $WEBSTORAGE_CONFIG, or config.json in the user config
+// directory.
+func configPath() (string, error) {
+	if path := os.Getenv("WEBSTORAGE_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "webstorage", "config.json"), nil
+}
+
+func loadConfig() (config, error) {
+	cfg := config{Server: defaultServer}
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// saveConfig writes cfg readable by the user only, it holds the token.
+func saveConfig(cfg config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,161 @@
+// Command webstorage manages bookmarks of a server from the command line.
+//
+//	webstorage [-server url] [-json] <command> [arguments]
+//
+// login keeps a token in the user config directory for the other commands.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"server/client"
+	"strings"
+)
+
+var (
+	flagServer = flag.String("server", "", "server url, the one of the last login by default")
+	flagJSON   = flag.Bool("json", false, "print the json of the api instead of tables")
+)
+
+// usages lists the commands in the order usage prints them.
+var usages = []struct{ name, usage string }{
+	{"login", "login [-password pw] user"},
+	{"logout", "logout"},
+	{"web", "web add|list|search|edit|delete ..."},
+	{"tag", "tag add|list|edit|delete ..."},
+	{"category", "category list|rename ..."},
+	{"import", "import [-format netscape] [-visibility v] [-dry-run] file"},
+	{"export", "export [-o file]"},
+}
+
+// commands run with the arguments after their name.
+var commands = map[string]func(e *env, args []string) error{
+	"login":    runLogin,
+	"logout":   runLogout,
+	"web":      runWeb,
+	"tag":      runTag,
+	"category": runCategory,
+	"import":   runImport,
+	"export":   runExport,
+}
+
+// env is what the commands share.
+type env struct {
+	cfg    config
+	client *client.Client
+	out    printer
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	if *flagServer != "" && *flagServer != cfg.Server {
+		// the token belongs to another server
+		cfg = config{Server: *flagServer}
+	}
+	e := &env{
+		cfg:    cfg,
+		client: client.New(cfg.Server, cfg.Token),
+		out:    printer{w: os.Stdout, json: *flagJSON},
+	}
+	if err := cmd(e, flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: webstorage [flags] <command> [arguments]\n\ncommands:\n")
+	for _, u := range usages {
+		fmt.Fprintf(os.Stderr, "  %s\n", u.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "webstorage: %s\n", err)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		for _, field := range apiErr.Fields {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", field.Field, field.Message)
+		}
+	}
+	os.Exit(1)
+}
+
+func usageOf(name string) string {
+	for _, u := range usages {
+		if u.name == name {
+			return u.usage
+		}
+	}
+	return name
+}
+
+// badUsage tells how to call command name.
+func badUsage(name string) error {
+	return fmt.Errorf("usage: webstorage %s", usageOf(name))
+}
+
+// subcommand picks the action of a command like web or tag.
+func subcommand(e *env, name string, args []string, actions map[string]func(*env, []string) error) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs an action, see %s", name, usageOf(name))
+	}
+	action, ok := actions[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown action %s %s, see %s", name, args[0], usageOf(name))
+	}
+	return action(e, args[1:])
+}
+
+func runLogin(e *env, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	password := fs.String("password", "", "password, read from stdin when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return badUsage("login")
+	}
+	user := fs.Arg(0)
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read password failed: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if err := e.client.Login(user, *password); err != nil {
+		return err
+	}
+	e.cfg.User = user
+	e.cfg.Token = e.client.Token
+	if err := saveConfig(e.cfg); err != nil {
+		return fmt.Errorf("save token failed: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "logged in to %s as %s\n", e.cfg.Server, user)
+	return nil
+}
+
+func runLogout(e *env, args []string) error {
+	e.cfg.User = ""
+	e.cfg.Token = ""
+	return saveConfig(e.cfg)
+}
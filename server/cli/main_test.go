@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"server/api"
+	"server/client"
+	"strings"
+	"testing"
+)
+
+// call is a request the fake server got.
+type call struct {
+	method, path string
+	query        url.Values
+	body         string
+}
+
+// newEnv returns an env calling a fake server answering with answers by
+// method and path, and the calls it got.
+func newEnv(t *testing.T, answers map[string]any) (*env, *bytes.Buffer, *[]call) {
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		path := strings.TrimPrefix(r.URL.Path, "/v1")
+		calls = append(calls, call{r.Method, path, r.URL.Query(), string(body)})
+		if answer, ok := answers[r.Method+" "+path]; ok {
+			json.NewEncoder(w).Encode(answer)
+			return
+		}
+		w.Write([]byte("success"))
+	}))
+	t.Cleanup(server.Close)
+	out := &bytes.Buffer{}
+	return &env{client: client.New(server.URL, "t1"), out: printer{w: out}}, out, &calls
+}
+
+// decodeStrict decodes body into v, failing on unknown fields like the
+// server.
+func decodeStrict(t *testing.T, body string, v any) {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+}
+
+func TestWebAdd(t *testing.T) {
+	e, _, calls := newEnv(t, nil)
+	if err := runWeb(e, []string{"add", "-tags", "go, news,", "-visibility", "private", "https://go.dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 || (*calls)[0].method != http.MethodPost || (*calls)[0].path != "/web" {
+		t.Fatalf("calls = %+v", *calls)
+	}
+	var req api.WebRequest
+	decodeStrict(t, (*calls)[0].body, &req)
+	if req.Url != "https://go.dev" || strings.Join(req.Tags, ",") != "go,news" || req.Visibility != "private" || req.Name != "" {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+// TestWebEdit checks edit sends the fields of the web data a client may
+// set, with the flags given changed.
+func TestWebEdit(t *testing.T) {
+	saved := api.WebData{ID: 3, Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, Owner: "user1",
+		Visibility: "team", Fetch: &api.FetchInfo{Status: "done"}, Icon: "https://go.dev/favicon.ico"}
+	e, _, calls := newEnv(t, map[string]any{"GET /web": api.WebDataPage{Items: []api.WebData{saved}, Total: 1}})
+	if err := runWeb(e, []string{"edit", "-description", "lang", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	last := (*calls)[len(*calls)-1]
+	if last.method != http.MethodPatch || last.path != "/web/3" {
+		t.Fatalf("last call = %+v", last)
+	}
+	var req api.WebRequest
+	decodeStrict(t, last.body, &req)
+	want := api.WebRequest{ID: 3, Name: "Go", Url: "https://go.dev", Description: "lang", Tags: []string{"go"}, Visibility: "team"}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("request = %+v, want %+v", req, want)
+	}
+}
+
+func TestTagEdit(t *testing.T) {
+	answers := map[string]any{
+		"GET /tag":        []api.Tag{{Name: "news", Category: "000000000000000000000000"}},
+		"GET /categories": []api.Category{{ID: "65f0c0ffee0000000000c0de", Name: "dev", Tags: []api.Tag{{Name: "go", Category: "65f0c0ffee0000000000c0de"}}}},
+	}
+	for _, tt := range []struct {
+		args []string
+		body string
+	}{
+		{[]string{"-order", "2", "go"}, `{"Order":2}`},
+		{[]string{"-category", "none", "go"}, `{"Category":null}`},
+		{[]string{"-category", "dev", "news"}, `{"Category":"65f0c0ffee0000000000c0de"}`},
+		{[]string{"-order", "0", "-category", "65f0c0ffee0000000000c0de", "news"}, `{"Order":0,"Category":"65f0c0ffee0000000000c0de"}`},
+	} {
+		e, _, calls := newEnv(t, answers)
+		if err := runTag(e, append([]string{"edit"}, tt.args...)); err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		last := (*calls)[len(*calls)-1]
+		name := tt.args[len(tt.args)-1]
+		if last.method != http.MethodPatch || last.path != "/tag/"+name || strings.TrimSpace(last.body) != tt.body {
+			t.Errorf("%v: sent %s %s %s, want body %s", tt.args, last.method, last.path, last.body, tt.body)
+		}
+	}
+
+	e, _, _ := newEnv(t, answers)
+	if err := runTag(e, []string{"edit", "-category", "ops", "go"}); err == nil || !strings.Contains(err.Error(), "category ops not found") {
+		t.Errorf("unknown category error = %v", err)
+	}
+	if err := runTag(e, []string{"edit", "-order", "1", "rust"}); err == nil || !strings.Contains(err.Error(), "tag rust not found") {
+		t.Errorf("unknown tag error = %v", err)
+	}
+}
+
+func TestWebList(t *testing.T) {
+	e, _, calls := newEnv(t, map[string]any{"GET /web": api.WebDataPage{Items: []api.WebData{}}})
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"list", "go", "|", "rust"}, `go | rust`},
+		{[]string{"list", "-tags", "Dev Tools,-draft"}, `"Dev Tools","-draft"`},
+		{[]string{"list", "-tags", "and", "go", "|", "rust"}, `(go | rust),"and"`},
+	} {
+		*calls = nil
+		if err := runWeb(e, tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if len(*calls) != 1 || (*calls)[0].query.Get("q") != tt.want {
+			t.Errorf("web %q: calls %+v, want q %s", tt.args, *calls, tt.want)
+		}
+	}
+}
+
+func TestTagList(t *testing.T) {
+	e, out, _ := newEnv(t, map[string]any{
+		"GET /tag":        []api.Tag{{Name: "news", Ref: 1, Category: "000000000000000000000000"}},
+		"GET /categories": []api.Category{{ID: "65f0c0ffee0000000000c0de", Name: "dev", Tags: []api.Tag{{Name: "go", Ref: 2, Order: 1, Category: "65f0c0ffee0000000000c0de"}}}},
+	})
+	if err := runTag(e, []string{"list"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || strings.Fields(lines[1])[0] != "news" || len(strings.Fields(lines[1])) != 3 ||
+		strings.Join(strings.Fields(lines[2]), " ") != "go 2 1 dev" {
+		t.Errorf("tag list =\n%s", out)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	e, _, calls := newEnv(t, nil)
+	for _, args := range [][]string{
+		{},
+		{"move"},
+		{"add"},
+		{"edit", "1", "2"},
+		{"delete", "0"},
+		{"delete", "x"},
+	} {
+		if err := runWeb(e, args); err == nil {
+			t.Errorf("web %v: no error", args)
+		}
+	}
+	if len(*calls) != 0 {
+		t.Errorf("bad arguments called the server: %+v", *calls)
+	}
+	if got := splitList(" a,,b ,"); strings.Join(got, "|") != "a|b" {
+		t.Errorf("splitList = %q", got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webstorage", "config.json")
+	t.Setenv("WEBSTORAGE_CONFIG", path)
+	cfg, err := loadConfig()
+	if err != nil || cfg != (config{Server: defaultServer}) {
+		t.Fatalf("loadConfig without file = %+v, %v", cfg, err)
+	}
+	want := config{Server: "https://bookmarks.example", User: "user1", Token: "t1"}
+	if err := saveConfig(want); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, want 0600, it holds the token", info.Mode().Perm())
+	}
+	if cfg, err := loadConfig(); err != nil || cfg != want {
+		t.Errorf("loadConfig = %+v, %v, want %+v", cfg, err, want)
+	}
+}
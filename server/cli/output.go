@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"server/api"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes results as tables, or as the json of the api with -json.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) print(v any, header []string, rows func(add func(cells ...any))) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	rows(func(cells ...any) {
+		texts := make([]string, len(cells))
+		for i, cell := range cells {
+			texts[i] = strings.ReplaceAll(fmt.Sprint(cell), "\t", " ")
+		}
+		fmt.Fprintln(tw, strings.Join(texts, "\t"))
+	})
+	return tw.Flush()
+}
+
+func (p printer) webData(v any, items []api.WebData) error {
+	return p.print(v, []string{"ID", "NAME", "URL", "TAGS", "VISIBILITY"}, func(add func(...any)) {
+		for _, d := range items {
+			add(d.ID, shorten(d.Name, 40), shorten(d.Url, 60), strings.Join(d.Tags, ","), d.Visibility)
+		}
+	})
+}
+
+func (p printer) categories(categories []api.Category) error {
+	return p.print(categories, []string{"ID", "NAME", "TAGS"}, func(add func(...any)) {
+		for _, c := range categories {
+			names := make([]string, len(c.Tags))
+			for i, t := range c.Tags {
+				names[i] = t.Name
+			}
+			add(c.ID, c.Name, strings.Join(names, ","))
+		}
+	})
+}
+
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"server/api"
+)
+
+func runTag(e *env, args []string) error {
+	return subcommand(e, "tag", args, map[string]func(*env, []string) error{
+		"add":    tagAdd,
+		"list":   tagList,
+		"edit":   tagEdit,
+		"delete": tagDelete,
+	})
+}
+
+// allTags returns the tags without category and the tags of the categories.
+func allTags(e *env) ([]api.Tag, []api.Category, error) {
+	tags, err := e.client.Tags()
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := e.client.Categories()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range categories {
+		tags = append(tags, c.Tags...)
+	}
+	return tags, categories, nil
+}
+
+func tagAdd(e *env, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: webstorage tag add name...")
+	}
+	// the server orders new tags last, tag edit moves them
+	for _, name := range args {
+		if err := e.client.AddTag(api.TagRequest{Name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tagList(e *env, args []string) error {
+	tags, categories, err := allTags(e)
+	if err != nil {
+		return err
+	}
+	names := map[string]string{}
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+	return e.out.print(tags, []string{"NAME", "REFS", "ORDER", "CATEGORY"}, func(add func(...any)) {
+		for _, t := range tags {
+			add(t.Name, t.Ref, t.Order, names[t.Category])
+		}
+	})
+}
+
+func tagEdit(e *env, args []string) error {
+	fs := flag.NewFlagSet("tag edit", flag.ExitOnError)
+	order := fs.Int("order", 0, "order among the tags")
+	category := fs.String("category", "", "name or id of the category, none to remove it")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: webstorage tag edit [-order n] [-category c] name")
+	}
+	tags, categories, err := allTags(e)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, t := range tags {
+		found = found || t.Name == fs.Arg(0)
+	}
+	if !found {
+		return fmt.Errorf("tag %s not found", fs.Arg(0))
+	}
+	var update api.TagUpdate
+	var visitErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "order":
+			update.Order = order
+		case "category":
+			var id string
+			if id, visitErr = findCategory(categories, *category); visitErr == nil {
+				update.Category = api.MoveTag(id)
+			}
+		}
+	})
+	if visitErr != nil {
+		return visitErr
+	}
+	return e.client.UpdateTag(fs.Arg(0), update)
+}
+
+func tagDelete(e *env, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: webstorage tag delete name...")
+	}
+	for _, name := range args {
+		if err := e.client.DeleteTag(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
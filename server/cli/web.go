@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"server/api"
+	"strconv"
+	"strings"
+)
+
+func runWeb(e *env, args []string) error {
+	return subcommand(e, "web", args, map[string]func(*env, []string) error{
+		"add":    webAdd,
+		"list":   webList,
+		"search": webSearch,
+		"edit":   webEdit,
+		"delete": webDelete,
+	})
+}
+
+// webFlags are the fields of web data add and edit set.
+type webFlags struct {
+	name, description, tags, visibility *string
+}
+
+func newWebFlags(fs *flag.FlagSet) webFlags {
+	return webFlags{
+		name:        fs.String("name", "", "name, the page title when empty"),
+		description: fs.String("description", "", "description"),
+		tags:        fs.String("tags", "", "comma separated tags"),
+		visibility:  fs.String("visibility", "", "private, team or public"),
+	}
+}
+
+// apply sets the fields of the flags given on the command line.
+func (f webFlags) apply(fs *flag.FlagSet, webData *api.WebRequest) {
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name":
+			webData.Name = *f.name
+		case "description":
+			webData.Description = *f.description
+		case "tags":
+			webData.Tags = splitList(*f.tags)
+		case "visibility":
+			webData.Visibility = *f.visibility
+		}
+	})
+}
+
+func webAdd(e *env, args []string) error {
+	fs := flag.NewFlagSet("web add", flag.ExitOnError)
+	f := newWebFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: webstorage web add [-name n] [-description d] [-tags a,b] [-visibility v] url")
+	}
+	webData := api.WebRequest{Url: fs.Arg(0), Tags: []string{}}
+	f.apply(fs, &webData)
+	if err := e.client.AddWeb(webData); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "added %s\n", webData.Url)
+	return nil
+}
+
+func webList(e *env, args []string) error {
+	fs := flag.NewFlagSet("web list", flag.ExitOnError)
+	sort := fs.String("sort", "", "id, name, created or updated")
+	desc := fs.Bool("desc", false, "sort descending")
+	limit := fs.Int("limit", 0, "page size, the server default when 0")
+	cursor := fs.String("cursor", "", "next cursor of the previous page")
+	tags := fs.String("tags", "", "comma separated tags the web data all have")
+	fs.Parse(args)
+	query := strings.Join(fs.Args(), " ")
+	if names := splitList(*tags); len(names) > 0 {
+		if query != "" {
+			query = "(" + query + ")," + api.TagQuery(names...)
+		} else {
+			query = api.TagQuery(names...)
+		}
+	}
+	page, err := e.client.ListWeb(query, api.PageRequest{
+		Sort:   *sort,
+		Desc:   *desc,
+		Limit:  *limit,
+		Cursor: *cursor,
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.out.webData(page, page.Items); err != nil {
+		return err
+	}
+	if !e.out.json && page.NextCursor != "" {
+		fmt.Fprintf(os.Stderr, "%d of %d, next page: -cursor %s\n", len(page.Items), page.Total, page.NextCursor)
+	}
+	return nil
+}
+
+func webSearch(e *env, args []string) error {
+	fs := flag.NewFlagSet("web search", flag.ExitOnError)
+	tags := fs.String("tags", "", "tag query narrowing the result")
+	limit := fs.Int("limit", 0, "page size, the server default when 0")
+	offset := fs.Int("offset", 0, "hits to skip")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: webstorage web search [-tags q] [-limit n] [-offset n] text")
+	}
+	result, err := e.client.SearchWeb(strings.Join(fs.Args(), " "), *tags, *limit, *offset)
+	if err != nil {
+		return err
+	}
+	return e.out.print(result, []string{"ID", "SCORE", "NAME", "URL", "TAGS"}, func(add func(...any)) {
+		for _, hit := range result.Items {
+			add(hit.ID, strconv.FormatFloat(hit.Score, 'f', 2, 64), shorten(hit.Name, 40), shorten(hit.Url, 60), strings.Join(hit.Tags, ","))
+		}
+	})
+}
+
+func webEdit(e *env, args []string) error {
+	fs := flag.NewFlagSet("web edit", flag.ExitOnError)
+	f := newWebFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: webstorage web edit [-name n] [-description d] [-tags a,b] [-visibility v] id")
+	}
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	webData, err := e.client.GetWeb(id)
+	if err != nil {
+		return err
+	}
+	req := api.WebRequest{
+		ID:          webData.ID,
+		Name:        webData.Name,
+		Url:         webData.Url,
+		Description: webData.Description,
+		Tags:        webData.Tags,
+		Visibility:  webData.Visibility,
+	}
+	f.apply(fs, &req)
+	return e.client.UpdateWeb(req)
+}
+
+func webDelete(e *env, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: webstorage web delete id...")
+	}
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		if err := e.client.DeleteWeb(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runImport(e *env, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "netscape", "netscape, pinboard, pocket or raindrop")
+	visibility := fs.String("visibility", "", "visibility of the created web data, team by default")
+	dryRun := fs.Bool("dry-run", false, "only report what the import would do")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return badUsage("import")
+	}
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open %s failed: %w", fs.Arg(0), err)
+	}
+	defer file.Close()
+	report, err := e.client.ImportWeb(*format, file, api.ImportOptions{Visibility: *visibility, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	return e.out.print(report, []string{"RESULT", "ID", "URL", "TAGS", "REASON"}, func(add func(...any)) {
+		entries := func(result string, entries []api.ImportEntry) {
+			for _, entry := range entries {
+				id := ""
+				if entry.ID != 0 {
+					id = strconv.Itoa(entry.ID)
+				}
+				add(result, id, shorten(entry.Url, 60), strings.Join(entry.Tags, ","), entry.Reason)
+			}
+		}
+		entries("created", report.Created)
+		entries("skipped", report.Skipped)
+		entries("conflicting", report.Conflicting)
+	})
+}
+
+func runExport(e *env, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "", "output file, stdout when empty")
+	fs.Parse(args)
+	if *output == "" {
+		return e.client.ExportWeb(os.Stdout)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("create %s failed: %w", *output, err)
+	}
+	if err := e.client.ExportWeb(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %s", s)
+	}
+	return id, nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
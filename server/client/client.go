@@ -0,0 +1,265 @@
+// Package client calls the /v1 api of a server. It uses the payload types
+// of package api the server decodes and encodes, so it does not drift from
+// the api, and imports nothing of the server else.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"server/api"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiPrefix = "/v1"
+
+// Client calls the api of Server as the user of Token.
+type Client struct {
+	Server string
+	Token  string
+	HTTP   *http.Client
+}
+
+func New(server, token string) *Client {
+	return &Client{
+		Server: strings.TrimSuffix(server, "/"),
+		Token:  token,
+		HTTP:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+// request is a call of the api. Body is sent as json unless it is an
+// io.Reader, the response is decoded into out unless out is an io.Writer.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	contentType string
+	out         any
+}
+
+func (c *Client) do(req request) error {
+	u := c.Server + apiPrefix + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	contentType := req.contentType
+	switch b := req.body.(type) {
+	case nil:
+	case io.Reader:
+		body = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("encode request body failed: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	httpReq, err := http.NewRequest(req.method, u, body)
+	if err != nil {
+		return fmt.Errorf("invalid request %s %s: %w", req.method, u, err)
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.method, u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	switch out := req.out.(type) {
+	case nil:
+		return nil
+	case io.Writer:
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			return fmt.Errorf("read response of %s %s failed: %w", req.method, u, err)
+		}
+		return nil
+	default:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response of %s %s failed: %w", req.method, u, err)
+		}
+		return nil
+	}
+}
+
+// Error is an error answer of the server. Code and Fields are empty when
+// the answer is no ErrorResponse, like the one of a proxy.
+type Error struct {
+	Status int
+	api.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// decodeError turns an ErrorResponse back into an Error.
+func decodeError(resp *http.Response) error {
+	e := &Error{Status: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &e.ErrorResponse); err != nil || e.Message == "" {
+		e.ErrorResponse = api.ErrorResponse{Message: "server answered " + resp.Status}
+	}
+	return e
+}
+
+// Login logs in with a password and sets Token to a new api token.
+func (c *Client) Login(username, password string) error {
+	jar, _ := cookiejar.New(nil)
+	session := &Client{Server: c.Server, HTTP: &http.Client{Timeout: c.HTTP.Timeout, Jar: jar}}
+	err := session.do(request{
+		method: http.MethodPost,
+		path:   "/login",
+		body:   map[string]string{"Username": username, "Password": password},
+	})
+	if err != nil {
+		return err
+	}
+	var token struct {
+		Token string `json:"token"`
+	}
+	if err := session.do(request{method: http.MethodPost, path: "/token", out: &token}); err != nil {
+		return err
+	}
+	c.Token = token.Token
+	return nil
+}
+
+func (c *Client) AddWeb(req api.WebRequest) error {
+	return c.do(request{method: http.MethodPost, path: "/web", body: req})
+}
+
+// ListWeb returns a page of the web data matching a tag query, all for an
+// empty one.
+func (c *Client) ListWeb(tagQuery string, page api.PageRequest) (api.WebDataPage, error) {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("q", tagQuery)
+	set("sort", page.Sort)
+	set("cursor", page.Cursor)
+	if page.Desc {
+		query.Set("order", "desc")
+	}
+	if page.Limit > 0 {
+		query.Set("limit", strconv.Itoa(page.Limit))
+	}
+	var result api.WebDataPage
+	err := c.do(request{method: http.MethodGet, path: "/web", query: query, out: &result})
+	return result, err
+}
+
+// GetWeb returns web data id. The api lists web data only, so this pages
+// through them.
+func (c *Client) GetWeb(id int) (api.WebData, error) {
+	page := api.PageRequest{Sort: "id", Limit: 500}
+	for {
+		result, err := c.ListWeb("", page)
+		if err != nil {
+			return api.WebData{}, err
+		}
+		for _, d := range result.Items {
+			if d.ID == id {
+				return d, nil
+			}
+		}
+		if result.NextCursor == "" || len(result.Items) == 0 || result.Items[len(result.Items)-1].ID > id {
+			return api.WebData{}, fmt.Errorf("web data %d not found", id)
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+// SearchWeb ranks web data by how well they match text.
+func (c *Client) SearchWeb(text, tagQuery string, limit, offset int) (api.SearchResult, error) {
+	query := url.Values{"q": {text}}
+	if tagQuery != "" {
+		query.Set("tags", tagQuery)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	var result api.SearchResult
+	err := c.do(request{method: http.MethodGet, path: "/search", query: query, out: &result})
+	return result, err
+}
+
+// UpdateWeb replaces web data req.ID.
+func (c *Client) UpdateWeb(req api.WebRequest) error {
+	return c.do(request{method: http.MethodPatch, path: fmt.Sprintf("/web/%d", req.ID), body: req})
+}
+
+func (c *Client) DeleteWeb(id int) error {
+	return c.do(request{method: http.MethodDelete, path: fmt.Sprintf("/web/%d", id)})
+}
+
+func (c *Client) Tags() ([]api.Tag, error) {
+	var tags []api.Tag
+	err := c.do(request{method: http.MethodGet, path: "/tag", out: &tags})
+	return tags, err
+}
+
+func (c *Client) AddTag(req api.TagRequest) error {
+	return c.do(request{method: http.MethodPost, path: "/tag", body: req})
+}
+
+// UpdateTag sets the order and category of tag name, as far as update has
+// them.
+func (c *Client) UpdateTag(name string, update api.TagUpdate) error {
+	return c.do(request{method: http.MethodPatch, path: "/tag/" + url.PathEscape(name), body: update})
+}
+
+func (c *Client) DeleteTag(name string) error {
+	return c.do(request{method: http.MethodDelete, path: "/tag/" + url.PathEscape(name)})
+}
+
+func (c *Client) Categories() ([]api.Category, error) {
+	var categories []api.Category
+	err := c.do(request{method: http.MethodGet, path: "/categories", out: &categories})
+	return categories, err
+}
+
+func (c *Client) UpdateCategory(id string, update api.CategoryUpdate) error {
+	return c.do(request{method: http.MethodPatch, path: "/categories/" + url.PathEscape(id), body: update})
+}
+
+// ImportWeb imports a bookmark file of format, see POST /v1/web/import.
+func (c *Client) ImportWeb(format string, file io.Reader, opts api.ImportOptions) (api.ImportReport, error) {
+	query := url.Values{"format": {format}}
+	if opts.Visibility != "" {
+		query.Set("visibility", opts.Visibility)
+	}
+	if opts.DryRun {
+		query.Set("dryRun", "true")
+	}
+	var report api.ImportReport
+	err := c.do(request{method: http.MethodPost, path: "/web/import", query: query, body: file, contentType: "application/octet-stream", out: &report})
+	return report, err
+}
+
+// ExportWeb writes the web data of the user as a Netscape bookmark file.
+func (c *Client) ExportWeb(w io.Writer) error {
+	return c.do(request{method: http.MethodGet, path: "/web/export", out: w})
+}
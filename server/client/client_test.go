@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"server/api"
+	"server/util"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL+"/", "")
+}
+
+func TestLogin(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			w.Write([]byte("success"))
+		case "/v1/token":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "s1" {
+				util.WriteError(w, r, util.Errorf("not logged in").WithCode(codes.Unauthenticated))
+				return
+			}
+			w.Write([]byte(`{"token":"t1"}`))
+		case "/v1/tag":
+			if r.Header.Get("Authorization") != "Bearer t1" {
+				util.WriteError(w, r, util.Errorf("not logged in").WithCode(codes.Unauthenticated))
+				return
+			}
+			w.Write([]byte(`[{"Name":"go","Ref":2,"Order":0}]`))
+		}
+	})
+	if err := c.Login("user1", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token != "t1" {
+		t.Fatalf("Token = %q, want t1", c.Token)
+	}
+	tags, err := c.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "go" || tags[0].Ref != 2 {
+		t.Errorf("Tags() = %+v", tags)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/web":
+			util.WriteError(w, r, util.Errorf("invalid web data").WithCode(codes.InvalidArgument).
+				WithFields(util.FieldError{Field: "Url", Message: "is required"}))
+		case "/v1/web/7":
+			util.WriteError(w, r, util.Errorf("web data 7 not found").WithCode(codes.NotFound))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	err := c.AddWeb(api.WebRequest{Name: "no url"})
+	var e *Error
+	if !errors.As(err, &e) || e.Status != http.StatusBadRequest || e.Code != "InvalidArgument" {
+		t.Fatalf("AddWeb error = %#v, want InvalidArgument", err)
+	}
+	if e.Message != "invalid web data" {
+		t.Errorf("message = %q", e.Message)
+	}
+	if len(e.Fields) != 1 || e.Fields[0].Field != "Url" {
+		t.Errorf("fields = %+v", e.Fields)
+	}
+
+	if err := c.DeleteWeb(7); !errors.As(err, &e) || e.Code != "NotFound" {
+		t.Errorf("DeleteWeb error = %v, want NotFound", err)
+	}
+	// no ErrorResponse, only the status tells
+	if _, err := c.Categories(); !errors.As(err, &e) || e.Status != http.StatusServiceUnavailable || e.Code != "" {
+		t.Errorf("Categories error = %#v, want status 503", err)
+	}
+}
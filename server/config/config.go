@@ -0,0 +1,208 @@
+// Package config holds the settings of the server in one typed struct.
+//
+// Every field is a flag, named by its flag tag. Load fills the fields from a
+// YAML or TOML file, then from environment variables, then from the command
+// line, each overriding the one before. In the file a flag a.b is the key b
+// of table a:
+//
+//	port: "8071"
+//	cors:
+//	  origins: [https://example.com]
+//	crawler:
+//	  timeout: 30s
+//
+// The environment variable of flag crawler.maxBodySize is
+// WEBSTORAGE_CRAWLER_MAX_BODY_SIZE.
+//
+// config imports no package of the server, so every package can read it.
+package config
+
+import "time"
+
+type Config struct {
+	Port    string `flag:"port" usage:"server port"`
+	Debug   bool   `flag:"debug" usage:"if print debug log"`
+	Swagger bool   `flag:"swagger" usage:"if serve swagger"`
+
+	Grpc      Grpc
+	CORS      CORS
+	HTTP      HTTP
+	Token     Token
+	User      User
+	Mongodb   Mongodb
+	Web       Web
+	Dav       Dav
+	Crawler   Crawler
+	Outbound  Outbound
+	Linkcheck Linkcheck
+	Archive   Archive
+	Backup    Backup
+	Graphql   Graphql
+}
+
+type Grpc struct {
+	Port string `flag:"grpc.port" usage:"grpc server port, disabled when empty"`
+	// Host defaults to localhost as bearer tokens travel in plaintext
+	// unless CertFile and KeyFile are set, a tls terminator in front may
+	// listen for other hosts.
+	Host     string `flag:"grpc.host" usage:"host grpc listens on, empty for every interface, only expose it with tls"`
+	CertFile string `flag:"grpc.certFile" usage:"pem certificate chain of the grpc server, serving tls with grpc.keyFile"`
+	KeyFile  string `flag:"grpc.keyFile" usage:"pem private key of grpc.certFile"`
+}
+
+type CORS struct {
+	Origins []string `flag:"cors.origins" usage:"comma separated origins allowed to call the api with credentials, * for any"`
+}
+
+type HTTP struct {
+	MaxBodySize int64 `flag:"http.maxBodySize" usage:"max bytes of a json request body"`
+}
+
+type Token struct {
+	Expire time.Duration `flag:"token.expire" usage:"lifetime of api tokens"`
+	// Secret signs api tokens and session cookies, serve refuses to start
+	// without one.
+	Secret     string `flag:"token.secret" secret:"true" usage:"random key of at least 32 bytes signing api tokens and session cookies"`
+	SecretFile string `flag:"token.secretFile" usage:"file holding token.secret"`
+}
+
+type User struct {
+	Test        bool   `flag:"user.test" usage:"test without database"`
+	AutoManager bool   `flag:"user.auto-manager" usage:"new user as manager"`
+	SetupToken  string `flag:"user.setupToken" secret:"true" usage:"one-time token of the first run setup, a random one is logged when empty"`
+}
+
+type Mongodb struct {
+	// URI is a full connection string, the options below override it.
+	URI           string        `flag:"mongodb.uri" secret:"true" usage:"mongodb connection uri, mongodb:// + mongodb.addr when empty"`
+	Addr          string        `flag:"mongodb.addr" usage:"comma separated mongodb hosts"`
+	Name          string        `flag:"mongodb.name" usage:"mongodb name"`
+	User          string        `flag:"mongodb.user" usage:"user to authenticate as"`
+	Password      string        `flag:"mongodb.password" secret:"true" usage:"password of mongodb.user"`
+	PasswordFile  string        `flag:"mongodb.passwordFile" usage:"file holding the password of mongodb.user, instead of mongodb.password"`
+	AuthSource    string        `flag:"mongodb.authSource" usage:"database of the user, the driver default when empty"`
+	AuthMechanism string        `flag:"mongodb.authMechanism" usage:"SCRAM-SHA-256, SCRAM-SHA-1 or MONGODB-X509, negotiated when empty"`
+	ReplicaSet    string        `flag:"mongodb.replicaSet" usage:"replica set name"`
+	TLS           bool          `flag:"mongodb.tls" usage:"connect with tls"`
+	TLSCAFile     string        `flag:"mongodb.tlsCAFile" usage:"pem file of the certificate authorities trusted, the system ones when empty"`
+	TLSCertFile   string        `flag:"mongodb.tlsCertFile" usage:"pem file of the client certificate and its key"`
+	TLSInsecure   bool          `flag:"mongodb.tlsInsecure" usage:"skip verifying the server certificate"`
+	MaxPoolSize   int           `flag:"mongodb.maxPoolSize" usage:"max connections per server, the driver default when 0"`
+	MinPoolSize   int           `flag:"mongodb.minPoolSize" usage:"connections per server kept open"`
+	Timeout       time.Duration `flag:"mongodb.timeout" usage:"timeout of one database operation"`
+	// ConnectTimeout bounds one attempt, ConnectRetry all attempts at
+	// startup.
+	ConnectTimeout time.Duration `flag:"mongodb.connectTimeout" usage:"timeout of connecting to mongodb"`
+	ConnectRetry   time.Duration `flag:"mongodb.connectRetry" usage:"how long connecting is retried at startup, with backoff"`
+
+	DefaultOwner string `flag:"mongodb.webdata.defaultOwner" usage:"owner of web data saved before ownership existed"`
+	TagIgnoreRef bool   `flag:"mongodb.tag.ignoreRef" usage:"if ref abort delete tag"`
+}
+
+type Web struct {
+	URLSchemes    string `flag:"web.urlSchemes" usage:"comma separated url schemes web data may link to"`
+	CreateTags    bool   `flag:"web.createTags" usage:"create unknown tags of web data instead of rejecting them"`
+	MaxImportSize int64  `flag:"web.maxImportSize" usage:"max bytes of an imported bookmark file"`
+}
+
+type Dav struct {
+	File    string `flag:"dav.file" usage:"name of the xbel document of bookmark sync"`
+	MaxSize int64  `flag:"dav.maxSize" usage:"max bytes of an uploaded xbel document"`
+}
+
+type Crawler struct {
+	Workers     int           `flag:"crawler.workers" usage:"number of page fetch workers, disabled when 0"`
+	Timeout     time.Duration `flag:"crawler.timeout" usage:"timeout of fetching one page"`
+	MaxBodySize int64         `flag:"crawler.maxBodySize" usage:"max bytes of a page read"`
+	MaxTextSize int           `flag:"crawler.maxTextSize" usage:"max bytes of page text stored"`
+	UserAgent   string        `flag:"crawler.userAgent" usage:"user agent of page fetches, also matched against robots.txt"`
+	Robots      bool          `flag:"crawler.robots" usage:"skip pages disallowed by robots.txt"`
+	MaxIconSize int64         `flag:"crawler.maxIconSize" usage:"max bytes of a cached favicon"`
+	IconTTL     time.Duration `flag:"crawler.iconTTL" usage:"how long a cached favicon is used before fetching it again"`
+}
+
+type Outbound struct {
+	Allow        string `flag:"outbound.allow" usage:"comma separated hosts, ips or cidrs outbound requests may reach although they are private"`
+	MaxRedirects int    `flag:"outbound.maxRedirects" usage:"max redirects followed by outbound requests"`
+}
+
+type Linkcheck struct {
+	Interval      time.Duration `flag:"linkcheck.interval" usage:"how often every link is checked, disabled when 0"`
+	Workers       int           `flag:"linkcheck.workers" usage:"number of links checked concurrently"`
+	HostInterval  time.Duration `flag:"linkcheck.hostInterval" usage:"min time between two checks of the same host"`
+	Timeout       time.Duration `flag:"linkcheck.timeout" usage:"timeout of checking one link"`
+	FailThreshold int           `flag:"linkcheck.failThreshold" usage:"failed checks in a row after which a link is flagged broken"`
+	UserAgent     string        `flag:"linkcheck.userAgent" usage:"user agent of link checks"`
+}
+
+type Archive struct {
+	Store           string        `flag:"archive.store" usage:"blob store of snapshots, only file for now"`
+	Dir             string        `flag:"archive.dir" usage:"directory of the file store"`
+	MaxSize         int64         `flag:"archive.maxSize" usage:"max bytes of a snapshot with its inlined assets"`
+	Timeout         time.Duration `flag:"archive.timeout" usage:"timeout of taking one snapshot"`
+	Workers         int           `flag:"archive.workers" usage:"number of snapshots taken concurrently"`
+	MaxPending      int           `flag:"archive.maxPending" usage:"snapshots a user may have pending, more are rejected until they are done"`
+	MaxPerUser      int           `flag:"archive.maxPerUser" usage:"snapshots kept per user, the oldest are deleted first"`
+	MaxBytesPerUser int64         `flag:"archive.maxBytesPerUser" usage:"bytes of snapshots kept per user, the oldest are deleted first"`
+	UserAgent       string        `flag:"archive.userAgent" usage:"user agent of snapshot requests"`
+}
+
+type Backup struct {
+	MaxRestoreSize int64 `flag:"backup.maxRestoreSize" usage:"max bytes of an archive restored over http"`
+}
+
+type Graphql struct {
+	MaxDepth      int `flag:"graphql.maxDepth" usage:"max selection depth of a graphql query"`
+	MaxComplexity int `flag:"graphql.maxComplexity" usage:"max estimated number of fields a graphql query resolves"`
+}
+
+// Current is the config of the process, the defaults until Load.
+var Current = Default()
+
+func Default() Config {
+	var c Config
+	c.Port = "8071"
+	c.Grpc.Host = "localhost"
+	c.CORS.Origins = []string{"http://localhost:8080", "http://localhost:3001"}
+	c.HTTP.MaxBodySize = 1 << 20
+	c.Token.Expire = 7 * 24 * time.Hour
+	c.Mongodb.Addr = "localhost:27017"
+	c.Mongodb.Name = "webStorage"
+	c.Mongodb.Timeout = 5 * time.Second
+	c.Mongodb.ConnectTimeout = 10 * time.Second
+	c.Mongodb.ConnectRetry = 2 * time.Minute
+	c.Mongodb.DefaultOwner = "user1"
+	c.Mongodb.TagIgnoreRef = true
+	c.Web.URLSchemes = "http,https"
+	c.Web.MaxImportSize = 20 << 20
+	c.Dav.File = "bookmarks.xbel"
+	c.Dav.MaxSize = 20 << 20
+	c.Crawler.Workers = 2
+	c.Crawler.Timeout = 15 * time.Second
+	c.Crawler.MaxBodySize = 2 << 20
+	c.Crawler.MaxTextSize = 100 << 10
+	c.Crawler.UserAgent = "WebStorageBot/1.0"
+	c.Crawler.Robots = true
+	c.Crawler.MaxIconSize = 256 << 10
+	c.Crawler.IconTTL = 7 * 24 * time.Hour
+	c.Outbound.MaxRedirects = 5
+	c.Linkcheck.Interval = 24 * time.Hour
+	c.Linkcheck.Workers = 4
+	c.Linkcheck.HostInterval = 2 * time.Second
+	c.Linkcheck.Timeout = 15 * time.Second
+	c.Linkcheck.FailThreshold = 3
+	c.Linkcheck.UserAgent = "WebStorageBot/1.0 (link check)"
+	c.Archive.Store = "file"
+	c.Archive.Dir = "data/archive"
+	c.Archive.MaxSize = 20 << 20
+	c.Archive.Timeout = 2 * time.Minute
+	c.Archive.Workers = 1
+	c.Archive.MaxPending = 5
+	c.Archive.MaxPerUser = 200
+	c.Archive.MaxBytesPerUser = 1 << 30
+	c.Archive.UserAgent = "WebStorageBot/1.0 (archive)"
+	c.Backup.MaxRestoreSize = 512 << 20
+	c.Graphql.MaxDepth = 8
+	c.Graphql.MaxComplexity = 5000
+	return c
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix = "WEBSTORAGE_"
+	// FileEnv names the config file when the config flag is not given.
+	FileEnv = envPrefix + "CONFIG_FILE"
+)
+
+// Load registers the flags of Current on flag.CommandLine and fills Current
+// from the config file, the environment and args. flag.Args returns the
+// arguments left.
+func Load(args []string) (Fields, error) {
+	file := flag.String("config", "", "YAML or TOML config file, $"+FileEnv+" when empty")
+	fields := Bind(flag.CommandLine, &Current)
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, err
+	}
+	if *file == "" {
+		*file = os.Getenv(FileEnv)
+	}
+	return fields, load(flag.CommandLine, fields, *file, os.Getenv)
+}
+
+// Field is the field of a flag.
+type Field struct {
+	Value  reflect.Value
+	Secret bool // redacted by Print
+}
+
+// Fields maps flag names to the fields of a Config.
+type Fields map[string]Field
+
+// Bind registers a flag of every field of c on fs.
+func Bind(fs *flag.FlagSet, c *Config) Fields {
+	fields := Fields{}
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field, tag := v.Field(i), v.Type().Field(i).Tag
+			name := tag.Get("flag")
+			if name == "" {
+				if field.Kind() == reflect.Struct {
+					walk(field)
+				}
+				continue
+			}
+			usage := tag.Get("usage")
+			switch p := field.Addr().Interface().(type) {
+			case *string:
+				fs.StringVar(p, name, *p, usage)
+			case *bool:
+				fs.BoolVar(p, name, *p, usage)
+			case *int:
+				fs.IntVar(p, name, *p, usage)
+			case *int64:
+				fs.Int64Var(p, name, *p, usage)
+			case *time.Duration:
+				fs.DurationVar(p, name, *p, usage)
+			case *[]string:
+				fs.Var((*list)(p), name, usage)
+			default:
+				panic(fmt.Sprintf("config: flag %s has unsupported type %s", name, field.Type()))
+			}
+			fields[name] = Field{Value: field, Secret: tag.Get("secret") == "true"}
+		}
+	}
+	walk(reflect.ValueOf(c).Elem())
+	return fields
+}
+
+// list is a comma separated flag, setting it replaces the whole list.
+type list []string
+
+func (l *list) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// load sets the fields of the flags not given on the command line, from the
+// environment over the file.
+func load(fs *flag.FlagSet, fields Fields, file string, getenv func(string) string) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	values := map[string]string{}
+	if file != "" {
+		if err := readFile(file, values); err != nil {
+			return err
+		}
+	}
+	for name := range values {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("unknown key %s in %s", name, file)
+		}
+	}
+	sources := map[string]string{}
+	for name := range values {
+		sources[name] = file
+	}
+	for name := range fields {
+		if v := getenv(EnvName(name)); v != "" {
+			values[name] = v
+			sources[name] = "$" + EnvName(name)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if given[name] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid %s %q in %s: %v", name, values[name], sources[name], err)
+		}
+	}
+	return nil
+}
+
+// EnvName is the environment variable of flag name:
+// crawler.maxBodySize is WEBSTORAGE_CRAWLER_MAX_BODY_SIZE.
+func EnvName(name string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	prev := rune(0)
+	for _, r := range name {
+		switch {
+		case r == '.' || r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// readFile reads a YAML or TOML file, by its extension, into values keyed
+// by flag name.
+func readFile(file string, values map[string]string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	tree := map[string]any{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return fmt.Errorf("config file %s is neither .yaml, .yml nor .toml", file)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", file, err)
+	}
+	return flatten("", tree, values)
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) error {
+	for key, value := range tree {
+		name := prefix + key
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(name+".", v, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				if _, ok := item.(map[string]any); ok {
+					return fmt.Errorf("config key %s is a list of tables", name)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"port":                         "WEBSTORAGE_PORT",
+		"crawler.maxBodySize":          "WEBSTORAGE_CRAWLER_MAX_BODY_SIZE",
+		"crawler.iconTTL":              "WEBSTORAGE_CRAWLER_ICON_TTL",
+		"user.auto-manager":            "WEBSTORAGE_USER_AUTO_MANAGER",
+		"mongodb.webdata.defaultOwner": "WEBSTORAGE_MONGODB_WEBDATA_DEFAULT_OWNER",
+	} {
+		if got := EnvName(name); got != want {
+			t.Errorf("EnvName(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadArgs loads c like Load does, with env instead of the environment.
+func loadArgs(c *Config, file string, env map[string]string, args ...string) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fields := Bind(fs, c)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return load(fs, fields, file, func(key string) string { return env[key] })
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+port: 9000
+cors:
+  origins: [https://a.example.com, https://b.example.com]
+crawler:
+  timeout: 30s
+  workers: 3
+  userAgent: file
+mongodb:
+  webdata:
+    defaultOwner: admin
+`)
+	c := Default()
+	env := map[string]string{
+		"WEBSTORAGE_CRAWLER_WORKERS":    "5",
+		"WEBSTORAGE_CRAWLER_USER_AGENT": "env",
+	}
+	if err := loadArgs(&c, file, env, "-crawler.userAgent", "flag"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != "9000" || c.Crawler.Timeout != 30*time.Second || c.Mongodb.DefaultOwner != "admin" {
+		t.Errorf("file values not loaded: %+v", c)
+	}
+	if got := strings.Join(c.CORS.Origins, " "); got != "https://a.example.com https://b.example.com" {
+		t.Errorf("cors.origins = %s", got)
+	}
+	if c.Crawler.Workers != 5 {
+		t.Errorf("crawler.workers = %d, want 5 of the environment", c.Crawler.Workers)
+	}
+	if c.Crawler.UserAgent != "flag" {
+		t.Errorf("crawler.userAgent = %s, want the flag", c.Crawler.UserAgent)
+	}
+	if c.Crawler.MaxBodySize != Default().Crawler.MaxBodySize {
+		t.Errorf("crawler.maxBodySize = %d, want the default", c.Crawler.MaxBodySize)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+port = "9000"
+
+[archive]
+maxPerUser = 10
+timeout = "1m"
+`)
+	c := Default()
+	if err := loadArgs(&c, file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != "9000" || c.Archive.MaxPerUser != 10 || c.Archive.Timeout != time.Minute {
+		t.Errorf("toml values not loaded: port %s, archive %+v", c.Port, c.Archive)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.yaml": "crawler:\n  bogus: 1\n",
+		"invalid.yaml": "crawler:\n  workers: many\n",
+		"config.json":  "{}",
+	} {
+		c := Default()
+		if err := loadArgs(&c, writeFile(t, name, content), nil); err == nil {
+			t.Errorf("%s: load accepted %q", name, content)
+		}
+	}
+}
+
+func TestPrintRedacts(t *testing.T) {
+	c := Default()
+	c.User.SetupToken = "s3cret"
+	fields := Bind(flag.NewFlagSet("test", flag.ContinueOnError), &c)
+	var out bytes.Buffer
+	if err := Print(&out, fields, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "s3cret") || !strings.Contains(out.String(), "setupToken: "+redacted) {
+		t.Errorf("setup token not redacted:\n%s", out.String())
+	}
+
+	// the output loads back
+	file := writeFile(t, "printed.yaml", out.String())
+	loaded := Default()
+	if err := loadArgs(&loaded, file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Crawler.IconTTL != c.Crawler.IconTTL || strings.Join(loaded.CORS.Origins, ",") != strings.Join(c.CORS.Origins, ",") {
+		t.Errorf("printed config loads as %+v", loaded)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "<redacted>"
+
+// Print writes the values of fields as a config file of format, yaml or
+// toml. Secrets are redacted, so the output can be shared.
+func Print(w io.Writer, fields Fields, format string) error {
+	tree := map[string]any{}
+	for name, field := range fields {
+		var value any
+		switch v := field.Value.Interface().(type) {
+		case time.Duration:
+			value = v.String()
+		default:
+			value = v
+		}
+		if field.Secret && !field.Value.IsZero() {
+			value = redacted
+		}
+		node := tree
+		keys := strings.Split(name, ".")
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = value
+	}
+	switch format {
+	case "yaml", "":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(tree); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "toml":
+		return toml.NewEncoder(w).Encode(tree)
+	}
+	return fmt.Errorf("unknown config format %s", format)
+}
@@ -0,0 +1,222 @@
+// Package crawler fetches the pages of web data in the background and stores
+// their readable text, so full text search also finds what a page said.
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"server/config"
+	"server/mongodb"
+	"server/outbound"
+	"server/util"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html/charset"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	workers      = &config.Current.Crawler.Workers
+	fetchTimeout = &config.Current.Crawler.Timeout
+	maxBodySize  = &config.Current.Crawler.MaxBodySize
+	maxTextSize  = &config.Current.Crawler.MaxTextSize
+	userAgent    = &config.Current.Crawler.UserAgent
+	obeyRobots   = &config.Current.Crawler.Robots
+	maxIconSize  = &config.Current.Crawler.MaxIconSize
+	iconTTL      = &config.Current.Crawler.IconTTL
+)
+
+const queueSize = 1024
+
+type job struct {
+	id  int
+	url string
+}
+
+var queue = make(chan job, queueSize)
+
+// defaultFetcher is used by the workers.
+var defaultFetcher *Fetcher
+
+// Start launches the workers and queues the web data never fetched. Without
+// workers the pending web data is marked disabled instead.
+func Start() {
+	if *workers <= 0 {
+		logrus.Info("page fetch disabled")
+		if n, err := mongodb.DisablePendingFetches(); err != nil {
+			logrus.Errorf("disable pending page fetches err: %v", err)
+		} else if n > 0 {
+			logrus.Infof("marked %d pending page fetches disabled", n)
+		}
+		return
+	}
+	policy, err := outbound.DefaultPolicy()
+	if err != nil {
+		logrus.Fatalf("page fetch: %v", err)
+	}
+	defaultFetcher = NewFetcher(outbound.NewClient(*fetchTimeout, policy))
+	for i := 0; i < *workers; i++ {
+		go work()
+	}
+	go func() {
+		err := mongodb.EachPendingFetch(context.Background(), func(data mongodb.WebData) error {
+			queue <- job{data.ID, data.Url}
+			return nil
+		})
+		if err != nil {
+			logrus.Errorf("queue pending page fetches err: %v", err)
+		}
+	}()
+}
+
+// Enqueue queues fetching the page of web data id. When the queue is full
+// the page stays pending and is fetched after the next restart. Without
+// workers the page is marked disabled, it is fetched once they run.
+func Enqueue(id int, url string) {
+	if *workers <= 0 {
+		disable(id, url)
+		return
+	}
+	select {
+	case queue <- job{id, url}:
+	default:
+		logrus.Warnf("page fetch queue full, WebData %d stays pending", id)
+	}
+}
+
+func disable(id int, url string) {
+	info := mongodb.FetchInfo{Status: mongodb.FetchDisabled}
+	if err := mongodb.SetWebDataContent(id, url, "", info); err != nil {
+		util.Errorf("disable page fetch of WebData %d failed", id).WithCause(err).Log()
+		return
+	}
+	if err := mongodb.EnrichWebData(id, url, "", "", "", mongodb.FetchDisabled); err != nil {
+		util.Errorf("disable enrich of WebData %d failed", id).WithCause(err).Log()
+	}
+}
+
+func work() {
+	for j := range queue {
+		info := mongodb.FetchInfo{Status: mongodb.FetchDone, FetchedAt: time.Now()}
+		page, err := defaultFetcher.Fetch(context.Background(), j.url)
+		switch {
+		case util.HaveErrorCode(err, codes.PermissionDenied):
+			info.Status = mongodb.FetchBlocked
+			info.Error = util.Message(err)
+		case err != nil:
+			info.Status = mongodb.FetchFailed
+			info.Error = util.Message(err)
+			logrus.Debugf("fetch WebData %d page %s err: %v", j.id, j.url, err)
+		default:
+			info.Size = len(page.Text)
+		}
+		if err := mongodb.SetWebDataContent(j.id, j.url, page.Text, info); err != nil {
+			util.Errorf("store page of WebData %d failed", j.id).WithCause(err).Log()
+		}
+		enrich(j, page, info.Status)
+	}
+}
+
+// enrich fills the name, description and icon of the web data of j from
+// page, status is the fetch status of page.
+func enrich(j job, page Page, status string) {
+	icon := ""
+	if status == mongodb.FetchDone && page.Icon != "" {
+		if err := defaultFetcher.CacheIcon(context.Background(), page.Icon); err != nil {
+			logrus.Debugf("fetch icon %s of WebData %d err: %v", page.Icon, j.id, err)
+		} else {
+			icon = page.Icon
+		}
+	}
+	err := mongodb.EnrichWebData(j.id, j.url, page.Title, page.Description, icon, status)
+	if err != nil {
+		util.Errorf("enrich WebData %d failed", j.id).WithCause(err).Log()
+	}
+}
+
+// Page is the readable part of a fetched page.
+type Page struct {
+	URL         string // after redirects
+	Title       string
+	Description string
+	Icon        string // absolute url of the favicon
+	Text        string
+}
+
+// Fetcher fetches pages with size and time limits, obeying robots.txt. Its
+// client should come from outbound.NewClient, since urls are user supplied.
+type Fetcher struct {
+	client *http.Client
+	robots *robotsCache
+}
+
+func NewFetcher(client *http.Client) *Fetcher {
+	return &Fetcher{client: client, robots: newRobotsCache()}
+}
+
+// Fetch downloads url and extracts its readable text. Pages disallowed by
+// robots.txt fail with codes.PermissionDenied.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (Page, error) {
+	if *obeyRobots {
+		allowed, err := f.robots.allowed(ctx, f.client, url)
+		if err != nil {
+			return Page{}, err
+		}
+		if !allowed {
+			return Page{}, util.Errorf("robots.txt disallows %s", url).WithCode(codes.PermissionDenied)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *fetchTimeout)
+	defer cancel()
+	resp, err := outbound.Fetch(ctx, f.client, outbound.Request{
+		URL:          url,
+		UserAgent:    *userAgent,
+		Accept:       "text/html,text/plain;q=0.9",
+		MaxSize:      *maxBodySize, // the start of a page is the most useful
+		ContentTypes: []string{"text/html", "text/plain", "application/xhtml+xml"},
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Page{}, util.Errorf("fetch %s failed with status %d", url, resp.StatusCode).WithCode(codes.Unavailable)
+	}
+	reader, err := charset.NewReader(bytes.NewReader(resp.Body), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return Page{}, util.Errorf("unsupported charset of %s", url).WithCause(err).WithCode(codes.FailedPrecondition)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return Page{}, util.Errorf("decode %s failed", url).WithCause(err).WithCode(codes.FailedPrecondition)
+	}
+
+	page := Page{URL: resp.URL, Icon: "/favicon.ico"}
+	if resp.ContentType == "text/plain" {
+		page.Text = collapseSpace(string(body))
+	} else {
+		extractPage(body, &page)
+	}
+	page.Text = truncate(page.Text, *maxTextSize)
+	page.Icon = resolveURL(resp.URL, page.Icon)
+	return page, nil
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && (s[n]&0xC0) == 0x80 {
+		n--
+	}
+	return s[:n]
+}
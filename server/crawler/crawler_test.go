@@ -0,0 +1,245 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/mongodb"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc/codes"
+)
+
+const article = `<!doctype html>
+<html><head><title> Go  Generics </title><style>body{color:red}</style></head>
+<body>
+<nav>Home | About</nav>
+<article><h1>Type parameters</h1><p>Generics landed in <b>Go 1.18</b>.</p>
+<script>track()</script></article>
+<footer>Copyright</footer>
+</body></html>`
+
+func newSite(t *testing.T, robots string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, robots)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, article)
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Plain title</title>
+<meta property="og:title" content="Open Graph title">
+<meta name="description" content="  Meta
+ description ">
+<link rel="shortcut icon" href="/static/icon.png">
+</head><body>text</body></html>`)
+	})
+	mux.HandleFunc("/static/icon.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("\x89PNG\r\n\x1a\n0000"))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("word ", 1<<20))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchExtractsReadableText(t *testing.T) {
+	site := newSite(t, "")
+	page, err := NewFetcher(site.Client()).Fetch(context.Background(), site.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.URL != site.URL+"/article" {
+		t.Errorf("URL = %q, want the redirect target", page.URL)
+	}
+	if page.Title != "Go Generics" {
+		t.Errorf("Title = %q", page.Title)
+	}
+	want := "Type parameters\nGenerics landed in Go 1.18."
+	if page.Text != want {
+		t.Errorf("Text = %q, want %q", page.Text, want)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	site := newSite(t, "")
+	fetcher := NewFetcher(site.Client())
+	page, err := fetcher.Fetch(context.Background(), site.URL+"/meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Title != "Open Graph title" || page.Description != "Meta description" {
+		t.Errorf("Title, Description = %q, %q", page.Title, page.Description)
+	}
+	if page.Icon != site.URL+"/static/icon.png" {
+		t.Errorf("Icon = %q", page.Icon)
+	}
+
+	icon, err := fetcher.FetchIcon(context.Background(), page.Icon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, want the sniffed image/png", icon.ContentType)
+	}
+	if _, err := fetcher.FetchIcon(context.Background(), site.URL+"/article"); !util.HaveErrorCode(err, codes.FailedPrecondition) {
+		t.Errorf("fetch html as icon: got %v, want FailedPrecondition", err)
+	}
+
+	// pages without icon link fall back to /favicon.ico
+	page, err = fetcher.Fetch(context.Background(), site.URL+"/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Icon != site.URL+"/favicon.ico" {
+		t.Errorf("Icon = %q, want /favicon.ico", page.Icon)
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	site := newSite(t, "")
+	fetcher := NewFetcher(site.Client())
+
+	page, err := fetcher.Fetch(context.Background(), site.URL+"/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Text) > *maxTextSize {
+		t.Errorf("stored %d bytes of text, limit %d", len(page.Text), *maxTextSize)
+	}
+
+	if _, err := fetcher.Fetch(context.Background(), site.URL+"/image"); !util.HaveErrorCode(err, codes.FailedPrecondition) {
+		t.Errorf("fetch image: got %v, want FailedPrecondition", err)
+	}
+
+	old := *fetchTimeout
+	*fetchTimeout = 50 * time.Millisecond
+	defer func() { *fetchTimeout = old }()
+	if _, err := fetcher.Fetch(context.Background(), site.URL+"/slow"); !util.HaveErrorCode(err, codes.Unavailable) {
+		t.Errorf("fetch slow page: got %v, want Unavailable", err)
+	}
+}
+
+func TestFetchObeysRobots(t *testing.T) {
+	site := newSite(t, `
+User-agent: *
+Disallow: /
+
+User-agent: WebStorageBot
+Disallow: /private
+Allow: /article
+Disallow: /*.pdf$
+`)
+	fetcher := NewFetcher(site.Client())
+	if _, err := fetcher.Fetch(context.Background(), site.URL+"/article"); err != nil {
+		t.Errorf("fetch allowed page: %v", err)
+	}
+	for _, path := range []string{"/private/notes", "/files/report.pdf"} {
+		_, err := fetcher.Fetch(context.Background(), site.URL+path)
+		if !util.HaveErrorCode(err, codes.PermissionDenied) {
+			t.Errorf("fetch %s: got %v, want PermissionDenied", path, err)
+		}
+	}
+}
+
+func TestRobotsRules(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /search
+Allow: /search/about
+Disallow: /tmp/
+`), "WebStorageBot/1.0")
+	for path, want := range map[string]bool{
+		"/":             true,
+		"/search?q=go":  false,
+		"/search/about": true,
+		"/tmp/":         false,
+		"/tmpfile":      true,
+	} {
+		if got := rules.allows(path); got != want {
+			t.Errorf("allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	site := newSite(t, "User-agent: *\nDisallow: /\n")
+	p, err := preview(context.Background(), site.Client(), site.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Preview{URL: site.URL + "/moved", FinalURL: site.URL + "/article", Status: 200, ContentType: "text/html", Title: "Go Generics",
+		Icon: site.URL + "/favicon.ico"}
+	if p != want {
+		t.Errorf("preview = %+v, want %+v", p, want)
+	}
+
+	p, err = preview(context.Background(), site.Client(), site.URL+"/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ContentType != "image/png" || p.Title != "" {
+		t.Errorf("preview of image = %+v", p)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("no workers", func(mt *mtest.T) {
+		oldColl, oldWorkers := mongodb.WebDatadb, *workers
+		defer func() { mongodb.WebDatadb, *workers = oldColl, oldWorkers }()
+		mongodb.WebDatadb, *workers = mt.Coll, 0
+
+		updated := mtest.CreateSuccessResponse(bson.E{"n", 1}, bson.E{"nModified", 1})
+		mt.AddMockResponses(updated, updated, updated)
+		Enqueue(1, "https://go.dev")
+		Start()
+		if len(queue) != 0 {
+			t.Errorf("queued %d jobs without workers", len(queue))
+		}
+
+		events := mt.GetAllStartedEvents()
+		if len(events) != 3 {
+			t.Fatalf("sent %d commands, want the fetch and enrich of the page and the pending ones", len(events))
+		}
+		for i, e := range events {
+			if e.CommandName != "update" || !strings.Contains(e.Command.String(), `"disabled"`) {
+				t.Errorf("command %d = %s, want an update to disabled", i, e.Command)
+			}
+		}
+		if !strings.Contains(events[2].Command.String(), `"multi": true`) {
+			t.Errorf("Start disabled only one web data: %s", events[2].Command)
+		}
+	})
+}
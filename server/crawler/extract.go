@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped hold navigation, code and other text nobody remembers a page by.
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Button:   true,
+}
+
+// blocks end a line of text.
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Tr: true, atom.Td: true, atom.Th: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Section: true, atom.Article: true, atom.Main: true,
+}
+
+// extractPage fills the title, description, icon and readable text of page
+// from its html. The text of <article> or <main> is preferred over the whole
+// body when present.
+func extractPage(body []byte, page *Page) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	if head := find(doc, atom.Head); head != nil {
+		extractMeta(head, page)
+	}
+	root := find(doc, atom.Article)
+	if root == nil {
+		root = find(doc, atom.Main)
+	}
+	if root == nil {
+		root = find(doc, atom.Body)
+	}
+	if root == nil {
+		return
+	}
+	page.Text = readableText(root)
+}
+
+// extractMeta prefers OpenGraph over <title> and <meta name="description">,
+// and takes the first icon link.
+func extractMeta(head *html.Node, page *Page) {
+	var title, ogTitle, description, ogDescription, icon string
+	for n := head.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Title:
+			title = nodeText(n)
+		case atom.Meta:
+			content := attr(n, "content")
+			switch strings.ToLower(attr(n, "property") + attr(n, "name")) {
+			case "og:title":
+				ogTitle = content
+			case "og:description":
+				ogDescription = content
+			case "description":
+				description = content
+			}
+		case atom.Link:
+			for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+				if (rel == "icon" || rel == "apple-touch-icon") && icon == "" {
+					icon = attr(n, "href")
+				}
+			}
+		}
+	}
+	page.Title = collapseSpace(firstNonEmpty(ogTitle, title))
+	page.Description = collapseSpace(firstNonEmpty(ogDescription, description))
+	if icon != "" {
+		page.Icon = icon
+	}
+}
+
+// resolveURL resolves ref against base, or returns "" unless the result is
+// an http or https url.
+func resolveURL(base, ref string) string {
+	u, err := url.Parse(base)
+	if err == nil {
+		u, err = u.Parse(ref)
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// readableText joins the text under root, one line per block element.
+func readableText(root *html.Node) string {
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if s := collapseSpace(line.String()); s != "" {
+			lines = append(lines, s)
+		}
+		line.Reset()
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if skipped[n.DataAtom] {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if blocks[n.DataAtom] {
+			flush()
+		}
+	}
+	walk(root)
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// find returns the first element a in document order.
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"server/mongodb"
+	"server/outbound"
+	"server/util"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// CacheIcon downloads the favicon at url unless it is cached already.
+func (f *Fetcher) CacheIcon(ctx context.Context, url string) error {
+	if icon, err := mongodb.GetIcon(url); err == nil && time.Since(icon.FetchedAt) < *iconTTL {
+		return nil
+	}
+	icon, err := f.FetchIcon(ctx, url)
+	if err != nil {
+		return err
+	}
+	return mongodb.SaveIcon(icon)
+}
+
+// FetchIcon downloads an image of at most maxIconSize bytes.
+func (f *Fetcher) FetchIcon(ctx context.Context, url string) (mongodb.Icon, error) {
+	ctx, cancel := context.WithTimeout(ctx, *fetchTimeout)
+	defer cancel()
+	resp, err := outbound.Fetch(ctx, f.client, outbound.Request{
+		URL:       url,
+		UserAgent: *userAgent,
+		Accept:    "image/*",
+		MaxSize:   *maxIconSize,
+	})
+	if err != nil {
+		return mongodb.Icon{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return mongodb.Icon{}, util.Errorf("fetch icon %s failed with status %d", url, resp.StatusCode).WithCode(codes.Unavailable)
+	}
+	if resp.Truncated {
+		return mongodb.Icon{}, util.Errorf("icon %s larger than %d bytes", url, *maxIconSize).WithCode(codes.FailedPrecondition)
+	}
+	data := resp.Body
+	contentType := resp.ContentType
+	if !strings.HasPrefix(contentType, "image/") {
+		// favicon.ico is often served without a proper type
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return mongodb.Icon{}, util.Errorf("icon %s is %s, not an image", url, contentType).WithCode(codes.FailedPrecondition)
+	}
+	return mongodb.Icon{URL: url, ContentType: contentType, Data: data, FetchedAt: time.Now()}, nil
+}
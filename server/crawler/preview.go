@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"server/outbound"
+	"server/util"
+	"sync"
+
+	"golang.org/x/net/html/charset"
+	"google.golang.org/grpc/codes"
+)
+
+const maxPreviewSize = 512 << 10
+
+// Preview describes what a url points to, without saving it.
+type Preview struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"finalUrl"` // after redirects
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"` // absolute url of the favicon
+}
+
+var (
+	previewClient     *http.Client
+	previewClientErr  error
+	previewClientOnce sync.Once
+)
+
+// PreviewURL fetches url through the outbound policy. Unlike the background
+// fetch it ignores robots.txt, a person asked for the page.
+func PreviewURL(ctx context.Context, url string) (Preview, error) {
+	previewClientOnce.Do(func() {
+		policy, err := outbound.DefaultPolicy()
+		if err != nil {
+			previewClientErr = util.Errorf("invalid outbound policy").WithCause(err)
+			return
+		}
+		previewClient = outbound.NewClient(*fetchTimeout, policy)
+	})
+	if previewClientErr != nil {
+		return Preview{}, previewClientErr
+	}
+	return preview(ctx, previewClient, url)
+}
+
+func preview(ctx context.Context, client *http.Client, url string) (Preview, error) {
+	ctx, cancel := context.WithTimeout(ctx, *fetchTimeout)
+	defer cancel()
+	resp, err := outbound.Fetch(ctx, client, outbound.Request{
+		URL:          url,
+		UserAgent:    *userAgent,
+		Accept:       "text/html,*/*;q=0.8",
+		MaxSize:      maxPreviewSize,
+		ContentTypes: []string{"text/html", "application/xhtml+xml"},
+	})
+	if resp == nil {
+		return Preview{}, err
+	}
+	p := Preview{URL: url, FinalURL: resp.URL, Status: resp.StatusCode, ContentType: resp.ContentType}
+	if err != nil {
+		if util.HaveErrorCode(err, codes.FailedPrecondition) {
+			// not a page, there is no title to read
+			return p, nil
+		}
+		return Preview{}, err
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(resp.Body), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return p, nil
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return p, nil
+	}
+	page := Page{Icon: "/favicon.ico"}
+	extractPage(body, &page)
+	p.Title = page.Title
+	p.Description = page.Description
+	p.Icon = resolveURL(resp.URL, page.Icon)
+	return p, nil
+}
@@ -0,0 +1,195 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"server/outbound"
+	"server/util"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	robotsTTL     = time.Hour
+	maxRobotsSize = 512 << 10
+)
+
+// robotsRules are the rules of the robots.txt group matching our user agent.
+type robotsRules struct {
+	allow    []string
+	disallow []string
+	expires  time.Time
+}
+
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]robotsRules // by scheme://host
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: map[string]robotsRules{}}
+}
+
+// allowed reports whether robots.txt of the host of rawURL lets us fetch it.
+func (c *robotsCache) allowed(ctx context.Context, client *http.Client, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false, util.Errorf("invalid url %s", rawURL).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	site := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	rules, ok := c.hosts[site]
+	c.mu.Unlock()
+	if !ok || time.Now().After(rules.expires) {
+		rules, err = fetchRobots(ctx, client, site)
+		if err != nil {
+			return false, err
+		}
+		c.mu.Lock()
+		c.hosts[site] = rules
+		c.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allows(path), nil
+}
+
+// fetchRobots loads the rules of site. Missing robots.txt allows everything,
+// unreachable sites and server errors disallow everything until the rules
+// expire. Only destinations the client may not reach fail.
+func fetchRobots(ctx context.Context, client *http.Client, site string) (robotsRules, error) {
+	ctx, cancel := context.WithTimeout(ctx, *fetchTimeout)
+	defer cancel()
+	rules := robotsRules{expires: time.Now().Add(robotsTTL)}
+	resp, err := outbound.Fetch(ctx, client, outbound.Request{
+		URL:       site + "/robots.txt",
+		UserAgent: *userAgent,
+		MaxSize:   maxRobotsSize,
+	})
+	switch {
+	case util.HaveErrorCode(err, codes.PermissionDenied), util.HaveErrorCode(err, codes.InvalidArgument):
+		return rules, err
+	case err != nil, resp.StatusCode >= 500:
+		rules.disallow = []string{"/"}
+		rules.expires = time.Now().Add(robotsTTL / 12)
+		return rules, nil
+	case resp.StatusCode >= 400:
+		return rules, nil
+	}
+	parsed := parseRobots(bytes.NewReader(resp.Body), *userAgent)
+	parsed.expires = rules.expires
+	return parsed, nil
+}
+
+// parseRobots picks the group naming agent, falling back to the "*" group.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	// the product token, "WebStorageBot" of "WebStorageBot/1.0"
+	agent = strings.ToLower(strings.SplitN(agent, "/", 2)[0])
+
+	var own, star robotsRules
+	var foundOwn bool
+	var groupAgents []string
+	inRules := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// a user-agent after rules starts a new group
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			for _, a := range groupAgents {
+				var target *robotsRules
+				switch {
+				case a != "*" && strings.Contains(agent, a):
+					target, foundOwn = &own, true
+				case a == "*":
+					target = &star
+				default:
+					continue
+				}
+				if value == "" {
+					// an empty disallow allows everything
+					continue
+				}
+				if key == "allow" {
+					target.allow = append(target.allow, value)
+				} else {
+					target.disallow = append(target.disallow, value)
+				}
+			}
+		}
+	}
+	if foundOwn {
+		return own
+	}
+	return star
+}
+
+// allows applies the most specific matching rule, allow wins ties.
+func (r robotsRules) allows(path string) bool {
+	longest := func(patterns []string) int {
+		n := -1
+		for _, p := range patterns {
+			if len(p) > n && matchRobots(p, path) {
+				n = len(p)
+			}
+		}
+		return n
+	}
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// matchRobots matches path against a robots.txt pattern, where "*" matches
+// any characters and a trailing "$" anchors the end.
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored && rest != "" {
+		// a later occurrence of the last part may end the path
+		last := parts[len(parts)-1]
+		return len(parts) > 1 && strings.HasSuffix(path, last)
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+package datasys
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"server/crawler"
+	"server/mongodb"
+	"server/urlnorm"
+	"server/util"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+)
+
+// Clipping saves the page a browser shows in two calls, for extensions and
+// bookmarklets: ClipWeb tells what saving it would look like, SaveClip
+// saves what the user confirmed.
+
+const (
+	maxSuggestedTags = 8
+	maxSelectionLen  = maxDescriptionLen
+)
+
+type ClipRequest struct {
+	Url string `json:"url"`
+	// Title is the title the browser shows, used when the page can not be
+	// fetched.
+	Title string `json:"title,omitempty"`
+	// Selection is the text selected on the page, it becomes the
+	// description.
+	Selection string `json:"selection,omitempty"`
+}
+
+// ClipPreview is what saving a page would look like.
+type ClipPreview struct {
+	Url       string `json:"url"`
+	Canonical string `json:"canonical"`
+	// Existing is the web data of the viewer saving the url already.
+	// Saving updates it when Editable.
+	Existing      *mongodb.WebData `json:"existing,omitempty"`
+	Editable      bool             `json:"editable"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Tags          []string         `json:"tags"`
+	SuggestedTags []string         `json:"suggestedTags"`
+	// Page is what fetching the url found, unless FetchError tells why it
+	// failed.
+	Page       *crawler.Preview `json:"page,omitempty"`
+	FetchError string           `json:"fetchError,omitempty"`
+}
+
+// ClipSave is what the user confirmed. ID is the existing web data to
+// update, 0 for a new one.
+type ClipSave struct {
+	ID          int      `json:"id,omitempty"`
+	Url         string   `json:"url"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+	Visibility  string   `json:"visibility,omitempty"`
+}
+
+// ClipWeb fetches the page of req.Url and suggests how to save it, nothing
+// is saved.
+func ClipWeb(ctx context.Context, viewer mongodb.Viewer, req ClipRequest) (ClipPreview, error) {
+	if err := requireLogin(viewer); err != nil {
+		return ClipPreview{}, err
+	}
+	if err := addWebRules.Validate(mongodb.WebData{Url: req.Url}); err != nil {
+		return ClipPreview{}, err
+	}
+	canonical, err := urlnorm.Normalize(req.Url)
+	if err != nil {
+		return ClipPreview{}, util.Errorf("invalid url %s", req.Url).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	clip := ClipPreview{
+		Url:         req.Url,
+		Canonical:   canonical,
+		Name:        truncate(cleanText(req.Title), maxNameLen),
+		Description: truncate(strings.TrimSpace(req.Selection), maxSelectionLen),
+		Tags:        []string{},
+	}
+
+	existing, err := mongodb.GetWebDataByCanonical(viewer.Name, canonical)
+	switch {
+	case err == nil:
+		clip.Existing = &existing
+		clip.Editable = viewer.CanEdit(existing)
+		clip.Name = existing.Name
+		if clip.Description == "" {
+			clip.Description = existing.Description
+		}
+		clip.Tags = append(clip.Tags, existing.Tags...)
+	case !util.HaveErrorCode(err, codes.NotFound):
+		return clip, err
+	}
+
+	page, err := crawler.PreviewURL(ctx, req.Url)
+	if err != nil {
+		clip.FetchError = util.Message(err)
+	} else {
+		clip.Page = &page
+		if clip.Existing == nil && page.Title != "" {
+			clip.Name = truncate(page.Title, maxNameLen)
+		}
+		if clip.Description == "" {
+			clip.Description = truncate(page.Description, maxDescriptionLen)
+		}
+	}
+
+	hostTags, err := tagsOfHost(ctx, viewer, canonical)
+	if err != nil {
+		return clip, err
+	}
+	tags, err := mongodb.GetAllTags(bson.M{})
+	if err != nil {
+		return clip, err
+	}
+	text := strings.Join([]string{clip.Name, clip.Description, req.Selection, canonical}, " ")
+	clip.SuggestedTags = suggestTags(text, clip.Tags, hostTags, tags, maxSuggestedTags)
+	return clip, nil
+}
+
+// SaveClip saves a confirmed clip and returns the ID of its web data.
+func SaveClip(viewer mongodb.Viewer, save ClipSave) (int, error) {
+	if save.ID == 0 {
+		return AddWeb(viewer, mongodb.WebData{
+			Name:        save.Name,
+			Url:         save.Url,
+			Description: save.Description,
+			Tags:        save.Tags,
+			Visibility:  save.Visibility,
+		})
+	}
+	webData, err := editableWebData(viewer, save.ID)
+	if err != nil {
+		return 0, err
+	}
+	if save.Name != "" {
+		webData.Name = save.Name
+	}
+	webData.Description = save.Description
+	webData.Tags = save.Tags
+	if save.Visibility != "" {
+		webData.Visibility = save.Visibility
+	}
+	return webData.ID, UpdateWeb(viewer, webData)
+}
+
+// tagsOfHost counts the tags of the web data viewer sees on the host of
+// canonical.
+func tagsOfHost(ctx context.Context, viewer mongodb.Viewer, canonical string) (map[string]int, error) {
+	u, err := url.Parse(canonical)
+	if err != nil {
+		return nil, util.Errorf("invalid url %s", canonical).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	site := u.Scheme + "://" + u.Host
+	filter := bson.M{"canonical": bson.M{"$regex": "^" + regexp.QuoteMeta(site) + "([/?]|$)"}}
+	counts := map[string]int{}
+	err = mongodb.EachWebData(ctx, filter, viewer, func(d mongodb.WebData) error {
+		for _, tag := range d.Tags {
+			counts[tag]++
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// suggestTags ranks the tags of the saved web data first, then the tags
+// used on the same host, then the tags named in text, and returns at most
+// limit of them.
+func suggestTags(text string, saved []string, hostTags map[string]int, tags []mongodb.Tag, limit int) []string {
+	suggested := []string{}
+	add := func(tag string) {
+		if len(suggested) < limit && !contains(suggested, tag) {
+			suggested = append(suggested, tag)
+		}
+	}
+	for _, tag := range saved {
+		add(tag)
+	}
+
+	host := make([]string, 0, len(hostTags))
+	for tag := range hostTags {
+		host = append(host, tag)
+	}
+	sort.Slice(host, func(i, j int) bool {
+		if hostTags[host[i]] != hostTags[host[j]] {
+			return hostTags[host[i]] > hostTags[host[j]]
+		}
+		return host[i] < host[j]
+	})
+	for _, tag := range host {
+		add(tag)
+	}
+
+	text = strings.ToLower(text)
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(text, notWord) {
+		words[word] = true
+	}
+	named := []mongodb.Tag{}
+	for _, tag := range tags {
+		name := strings.ToLower(tag.Name)
+		// names of several words are looked up as they are
+		if words[name] || strings.IndexFunc(name, notWord) >= 0 && strings.Contains(text, name) {
+			named = append(named, tag)
+		}
+	}
+	// the more used tag first
+	sort.SliceStable(named, func(i, j int) bool { return named[i].Ref > named[j].Ref })
+	for _, tag := range named {
+		add(tag.Name)
+	}
+	return suggested
+}
+
+func notWord(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
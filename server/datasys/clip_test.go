@@ -0,0 +1,29 @@
+package datasys
+
+import (
+	"reflect"
+	"server/mongodb"
+	"testing"
+)
+
+func TestSuggestTags(t *testing.T) {
+	tags := []mongodb.Tag{
+		{Name: "go", Ref: 3},
+		{Name: "Generics", Ref: 9},
+		{Name: "type theory", Ref: 1},
+		{Name: "rust", Ref: 5},
+		{Name: "news", Ref: 2},
+	}
+	text := "Go generics: an intro to type theory, https://go.dev/blog/intro-generics"
+	hostTags := map[string]int{"blog": 1, "lang": 4}
+
+	got := suggestTags(text, []string{"saved"}, hostTags, tags, 8)
+	want := []string{"saved", "lang", "blog", "Generics", "go", "type theory"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestTags = %v, want %v", got, want)
+	}
+
+	if got := suggestTags(text, nil, hostTags, tags, 3); len(got) != 3 {
+		t.Errorf("suggestTags with limit 3 = %v", got)
+	}
+}
@@ -0,0 +1,350 @@
+package datasys
+
+import (
+	"context"
+	"io"
+	"server/archive"
+	"server/crawler"
+	"server/mongodb"
+	"server/tagquery"
+	"server/urlnorm"
+	"server/util"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+)
+
+// The functions here are shared by the http handlers and the grpc service.
+
+func requireLogin(viewer mongodb.Viewer) error {
+	if viewer.Name == "" {
+		return util.Errorf("login required").WithCode(codes.Unauthenticated)
+	}
+	return nil
+}
+
+func requireManager(viewer mongodb.Viewer) error {
+	if err := requireLogin(viewer); err != nil {
+		return err
+	}
+	if viewer.Role < util.RoleManager {
+		return util.Errorf("manager role required").WithCode(codes.PermissionDenied)
+	}
+	return nil
+}
+
+// AddWeb saves webData owned by viewer and returns its ID.
+func AddWeb(viewer mongodb.Viewer, webData mongodb.WebData) (int, error) {
+	if err := requireLogin(viewer); err != nil {
+		return 0, err
+	}
+	webData.Owner = viewer.Name
+	if webData.Visibility == "" {
+		webData.Visibility = mongodb.VisibilityTeam
+	}
+	if err := addWebRules.Validate(webData); err != nil {
+		return 0, err
+	}
+	canonical, err := canonicalURL(webData.Url, webData.Owner, 0)
+	if err != nil {
+		return 0, err
+	}
+	webData.Canonical = canonical
+	if err := checkTags(webData.Tags, nil); err != nil {
+		return 0, err
+	}
+	webData.Created, webData.Updated = time.Time{}, time.Time{}
+	return insertWeb(webData)
+}
+
+// insertWeb saves checked web data and queues fetching its page.
+func insertWeb(webData mongodb.WebData) (int, error) {
+	webData.Fetch = &mongodb.FetchInfo{Status: mongodb.FetchPending}
+	webData.Enrich = mongodb.FetchPending
+	webData.Icon = ""
+	webData.Link = nil
+	id, err := mongodb.AddWebData(webData)
+	if err != nil {
+		return 0, err
+	}
+	crawler.Enqueue(id, webData.Url)
+	return id, nil
+}
+
+// UpdateWeb replaces web data webData.ID. The owner never changes.
+func UpdateWeb(viewer mongodb.Viewer, webData mongodb.WebData) error {
+	originData, err := editableWebData(viewer, webData.ID)
+	if err != nil {
+		return err
+	}
+	// 所有者不能通过修改转移
+	webData.Owner = originData.Owner
+	if webData.Visibility == "" {
+		webData.Visibility = originData.Visibility
+	}
+	if err := updateWebRules.Validate(webData); err != nil {
+		return err
+	}
+	if webData.Canonical, err = canonicalURL(webData.Url, webData.Owner, webData.ID); err != nil {
+		return err
+	}
+	// tags deleted while in use may stay
+	if err := checkTags(webData.Tags, originData.Tags); err != nil {
+		return err
+	}
+	// the fetch state, icon and link status are only changed by the
+	// crawler and link checker
+	webData.Fetch = nil
+	webData.Enrich = ""
+	webData.Icon = ""
+	webData.Link = nil
+	if err := mongodb.UpdateWebData(webData); err != nil {
+		return err
+	}
+	if webData.Url != originData.Url {
+		if err := mongodb.ClearLinkStatus(webData.ID); err != nil {
+			return err
+		}
+		return RefetchWeb(viewer, webData.ID)
+	}
+	return nil
+}
+
+// PreviewWeb fetches url for a logged in viewer, so it can be checked before
+// it is saved.
+func PreviewWeb(ctx context.Context, viewer mongodb.Viewer, url string) (crawler.Preview, error) {
+	if err := requireLogin(viewer); err != nil {
+		return crawler.Preview{}, err
+	}
+	if url == "" {
+		return crawler.Preview{}, util.Errorf("url required").WithCode(codes.InvalidArgument)
+	}
+	return crawler.PreviewURL(ctx, url)
+}
+
+// GetWebIcon returns the cached favicon of web data id.
+func GetWebIcon(viewer mongodb.Viewer, id int) (mongodb.Icon, error) {
+	webData, err := mongodb.GetWebDataByID(id, viewer)
+	if err != nil {
+		return mongodb.Icon{}, err
+	}
+	if webData.Icon == "" {
+		return mongodb.Icon{}, util.Errorf("web data %d has no icon", id).WithCode(codes.NotFound)
+	}
+	return mongodb.GetIcon(webData.Icon)
+}
+
+// TakeSnapshot starts archiving the page of web data id.
+func TakeSnapshot(viewer mongodb.Viewer, id int) (mongodb.Snapshot, error) {
+	webData, err := editableWebData(viewer, id)
+	if err != nil {
+		return mongodb.Snapshot{}, err
+	}
+	return archive.Take(webData)
+}
+
+// GetSnapshots returns the snapshots of web data id, newest first.
+func GetSnapshots(viewer mongodb.Viewer, id int) ([]mongodb.Snapshot, error) {
+	if _, err := mongodb.GetWebDataByID(id, viewer); err != nil {
+		return nil, err
+	}
+	return mongodb.GetSnapshots(id)
+}
+
+// OpenSnapshot returns the html of snapshot snapshotID of web data id.
+func OpenSnapshot(ctx context.Context, viewer mongodb.Viewer, id int, snapshotID string) (io.ReadCloser, error) {
+	if _, err := mongodb.GetWebDataByID(id, viewer); err != nil {
+		return nil, err
+	}
+	snapshot, err := mongodb.GetSnapshot(id, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return archive.Open(ctx, snapshot)
+}
+
+// RefetchWeb queues fetching the page content of web data id again.
+func RefetchWeb(viewer mongodb.Viewer, id int) error {
+	webData, err := editableWebData(viewer, id)
+	if err != nil {
+		return err
+	}
+	if err := mongodb.MarkWebDataFetchPending(id); err != nil {
+		return err
+	}
+	crawler.Enqueue(id, webData.Url)
+	return nil
+}
+
+func DeleteWeb(viewer mongodb.Viewer, id int) error {
+	if _, err := editableWebData(viewer, id); err != nil {
+		return err
+	}
+	if err := archive.DeleteAll(context.Background(), id); err != nil {
+		return err
+	}
+	return mongodb.DeleteWebData(id)
+}
+
+// SearchWeb returns at most limit web data visible to viewer having all
+// tags, after skipping offset of them.
+func SearchWeb(viewer mongodb.Viewer, tags []string, offset, limit int) ([]mongodb.WebData, error) {
+	return mongodb.GetWebDataByTags(tags, viewer, offset, limit)
+}
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// ListWeb returns one page of the web data visible to viewer matching the
+// tag query, see package tagquery. An empty query matches everything.
+func ListWeb(viewer mongodb.Viewer, query string, req mongodb.PageRequest) (mongodb.WebDataPage, error) {
+	filter, err := tagFilter(query)
+	if err != nil {
+		return mongodb.WebDataPage{}, err
+	}
+	return listWeb(viewer, filter, req)
+}
+
+// ListBrokenWeb returns one page of the web data visible to viewer whose
+// links the link checker flagged broken.
+func ListBrokenWeb(viewer mongodb.Viewer, req mongodb.PageRequest) (mongodb.WebDataPage, error) {
+	if err := requireLogin(viewer); err != nil {
+		return mongodb.WebDataPage{}, err
+	}
+	return listWeb(viewer, mongodb.BrokenLinkFilter(), req)
+}
+
+func listWeb(viewer mongodb.Viewer, filter bson.M, req mongodb.PageRequest) (mongodb.WebDataPage, error) {
+	if req.Limit == 0 {
+		req.Limit = defaultPageLimit
+	}
+	if req.Limit < 0 || req.Limit > maxPageLimit {
+		return mongodb.WebDataPage{}, util.Errorf("limit must be between 1 and %d", maxPageLimit).WithCode(codes.InvalidArgument)
+	}
+	return mongodb.ListWebData(filter, viewer, req)
+}
+
+func tagFilter(query string) (bson.M, error) {
+	if strings.TrimSpace(query) == "" {
+		return bson.M{}, nil
+	}
+	expr, err := tagquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return tagquery.Filter(expr, categoryTags)
+}
+
+// categoryTags returns the tag names of category name.
+func categoryTags(name string) ([]string, error) {
+	category, err := mongodb.GetCategoryByName(name)
+	if util.HaveErrorCode(err, codes.NotFound) {
+		// the query is wrong, not the path
+		return nil, util.Errorf("unknown category %s", name).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags, err := mongodb.GetAllTags(bson.M{"category": category.Id})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// EachSearchWeb is SearchWeb calling fn per result instead of collecting them.
+func EachSearchWeb(ctx context.Context, viewer mongodb.Viewer, tags []string, fn func(mongodb.WebData) error) error {
+	return mongodb.EachWebDataByTags(ctx, tags, viewer, fn)
+}
+
+// canonicalURL normalizes url and checks no web data of owner other than id
+// saves it already, 0 checks every web data of owner.
+func canonicalURL(url string, owner string, id int) (string, error) {
+	canonical, err := urlnorm.Normalize(url)
+	if err != nil {
+		return "", util.Errorf("invalid url %s", url).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	// the unique index of owner and canonical url catches this too, unless
+	// old duplicates kept it from being created
+	other, err := mongodb.GetWebDataByCanonical(owner, canonical)
+	if err == nil && other.ID != id {
+		return "", util.Errorf("%s is already saved", url).WithCode(codes.AlreadyExists)
+	}
+	if err != nil && !util.HaveErrorCode(err, codes.NotFound) {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// editableWebData loads web data id and checks viewer may edit it.
+func editableWebData(viewer mongodb.Viewer, id int) (mongodb.WebData, error) {
+	if err := requireLogin(viewer); err != nil {
+		return mongodb.WebData{}, err
+	}
+	webData, err := mongodb.GetWebDataByID(id, viewer)
+	if err != nil {
+		return webData, err
+	}
+	if !viewer.CanEdit(webData) {
+		return webData, util.Errorf("only the owner or a manager can edit web data %d", id).WithCode(codes.PermissionDenied)
+	}
+	return webData, nil
+}
+
+func AddTag(viewer mongodb.Viewer, tagData mongodb.Tag) error {
+	if err := requireLogin(viewer); err != nil {
+		return err
+	}
+	if err := tagRules.Validate(tagData); err != nil {
+		return err
+	}
+	return mongodb.AddTag(tagData)
+}
+
+// UpdateTag sets the order and category of tag name, as far as patch has
+// them.
+func UpdateTag(viewer mongodb.Viewer, name string, patch mongodb.TagPatch) error {
+	if err := requireLogin(viewer); err != nil {
+		return err
+	}
+	if err := tagPatchRules.Validate(patch); err != nil {
+		return err
+	}
+	return mongodb.UpdateTag(name, patch)
+}
+
+func DeleteTag(viewer mongodb.Viewer, name string) error {
+	if err := requireManager(viewer); err != nil {
+		return err
+	}
+	return mongodb.DeleteTag(name)
+}
+
+// GetTags returns the tags without category.
+func GetTags() ([]mongodb.Tag, error) {
+	filter := bson.M{"category": bson.M{"$exists": false}}
+	return mongodb.GetAllTags(filter)
+}
+
+func GetCategories() ([]mongodb.Category, error) {
+	return mongodb.GetAllCategories()
+}
+
+// UpdateCategory renames category id.
+func UpdateCategory(viewer mongodb.Viewer, id string, categoryData mongodb.Category) error {
+	if err := requireLogin(viewer); err != nil {
+		return err
+	}
+	if err := categoryRules.Validate(categoryData); err != nil {
+		return err
+	}
+	return mongodb.UpdateCategory(id, categoryData)
+}
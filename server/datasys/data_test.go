@@ -0,0 +1,107 @@
+package datasys
+
+import (
+	"strings"
+	"testing"
+
+	"server/mongodb"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUnknownCategory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("not found", func(mt *mtest.T) {
+		old := mongodb.CategoryDb
+		defer func() { mongodb.CategoryDb = old }()
+		mongodb.CategoryDb = mt.Coll
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch))
+		_, err := tagFilter(`go & category:"No Such"`)
+		if util.Code(err) != codes.InvalidArgument {
+			t.Errorf("tagFilter with an unknown category: %v, want InvalidArgument", err)
+		}
+	})
+}
+
+// TestTagRules checks the rules hold for every front-end, not only the
+// http handlers.
+func TestTagRules(t *testing.T) {
+	viewer := mongodb.Viewer{Name: "alice", Role: util.RolePlayer}
+	order := -1
+	for name, err := range map[string]error{
+		"tag name":      AddTag(viewer, mongodb.Tag{Name: "a,b"}),
+		"long tag name": AddTag(viewer, mongodb.Tag{Name: strings.Repeat("a", maxTagNameLen+1)}),
+		"order":         UpdateTag(viewer, "go", mongodb.TagPatch{Order: &order}),
+		"category name": UpdateCategory(viewer, "65f0c0ffee0000000000c0de", mongodb.Category{Name: ""}),
+	} {
+		if util.Code(err) != codes.InvalidArgument || len(util.Fields(err)) != 1 {
+			t.Errorf("%s: %v, want a field error", name, err)
+		}
+	}
+}
+
+// TestEditableWebData checks only the owner and managers edit or delete web
+// data, the database filter hiding what a viewer cannot see is mocked.
+func TestEditableWebData(t *testing.T) {
+	viewers := map[string]mongodb.Viewer{
+		"anonymous":    {},
+		"another user": {Name: "bob", Role: util.RolePlayer},
+		"team member":  {Name: "carol", Role: util.RolePlayer},
+		"owner":        {Name: "alice", Role: util.RolePlayer},
+		"manager":      {Name: "dave", Role: util.RoleManager},
+	}
+	want := map[string]map[string]codes.Code{
+		"anonymous":    {mongodb.VisibilityPrivate: codes.Unauthenticated, mongodb.VisibilityTeam: codes.Unauthenticated, mongodb.VisibilityPublic: codes.Unauthenticated},
+		"another user": {mongodb.VisibilityPrivate: codes.NotFound, mongodb.VisibilityTeam: codes.PermissionDenied, mongodb.VisibilityPublic: codes.PermissionDenied},
+		"team member":  {mongodb.VisibilityPrivate: codes.NotFound, mongodb.VisibilityTeam: codes.PermissionDenied, mongodb.VisibilityPublic: codes.PermissionDenied},
+		"owner":        {mongodb.VisibilityPrivate: codes.OK, mongodb.VisibilityTeam: codes.OK, mongodb.VisibilityPublic: codes.OK},
+		"manager":      {mongodb.VisibilityPrivate: codes.OK, mongodb.VisibilityTeam: codes.OK, mongodb.VisibilityPublic: codes.OK},
+	}
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for name, viewer := range viewers {
+		for visibility, code := range want[name] {
+			mt.Run(name+" "+visibility, func(mt *mtest.T) {
+				old := mongodb.WebDatadb
+				defer func() { mongodb.WebDatadb = old }()
+				mongodb.WebDatadb = mt.Coll
+
+				ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+				data := mongodb.WebData{ID: 1, Owner: "alice", Visibility: visibility}
+				found := func() bson.D {
+					if !viewer.CanSee(data) {
+						return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch)
+					}
+					return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+						bson.D{{Key: "_id", Value: 1}, {Key: "owner", Value: "alice"}, {Key: "visibility", Value: visibility}})
+				}
+
+				mt.AddMockResponses(found())
+				_, err := editableWebData(viewer, 1)
+				if code == codes.OK && err != nil || code != codes.OK && util.Code(err) != code {
+					t.Errorf("edit %s data as %s: %v, want %s", visibility, name, err, code)
+				}
+				if code == codes.OK {
+					return
+				}
+				// the denied calls stop before writing
+				mt.AddMockResponses(found(), found())
+				if err := DeleteWeb(viewer, 1); util.Code(err) != code {
+					t.Errorf("delete %s data as %s: %v, want %s", visibility, name, err, code)
+				}
+				if err := UpdateWeb(viewer, mongodb.WebData{ID: 1, Name: "mine", Url: "https://example.com"}); util.Code(err) != code {
+					t.Errorf("update %s data as %s: %v, want %s", visibility, name, err, code)
+				}
+				for _, e := range mt.GetAllStartedEvents() {
+					if e.CommandName != "find" {
+						t.Errorf("%s data as %s sent %s", visibility, name, e.CommandName)
+					}
+				}
+			})
+		}
+	}
+}
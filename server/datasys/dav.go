@@ -0,0 +1,280 @@
+package datasys
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"server/config"
+	"server/mongodb"
+	"server/usersys"
+	"server/util"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	davFile    = &config.Current.Dav.File
+	davMaxSize = &config.Current.Dav.MaxSize
+)
+
+// DavPrefix is the path the WebDAV collection of bookmark sync is served
+// at. Each user sees their own document in it.
+const DavPrefix = "/v1/dav"
+
+// davLocks are the other files clients put next to the document, Floccus
+// puts a lock file there while it syncs. They are kept in memory only, so a
+// user may keep a few small ones for a while.
+const (
+	maxDavLocks    = 4
+	maxDavLockSize = 64 << 10
+	// davLockExpiry is long past any sync, a lock left by a client that
+	// stopped while syncing goes away.
+	davLockExpiry = time.Hour
+)
+
+var davLocks = struct {
+	sync.Mutex
+	files map[string]map[string]davLock // by user and name
+}{files: map[string]map[string]davLock{}}
+
+type davLock struct {
+	data     []byte
+	modified time.Time
+}
+
+// HandleDav serves the WebDAV collection of bookmark sync. WebDAV clients
+// log in with basic auth, the password may also be an api token.
+func HandleDav(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, GET, HEAD, PUT, DELETE")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	viewer, err := davViewer(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="bookmarks", charset="UTF-8"`)
+		util.WriteError(w, r, err)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, DavPrefix), "/")
+	if strings.Contains(name, "/") {
+		util.WriteError(w, r, util.Errorf("no such file %s", name).WithCode(codes.NotFound))
+		return
+	}
+	switch {
+	case r.Method == "PROPFIND":
+		davPropfind(w, r, viewer, name)
+	case name == "":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case name == *davFile:
+		davDocument(w, r, viewer)
+	default:
+		davLockFile(w, r, viewer, name)
+	}
+}
+
+func davViewer(r *http.Request) (mongodb.Viewer, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		user, role, err := util.GetUser(r)
+		return mongodb.Viewer{Name: user, Role: role}, err
+	}
+	if user, role, err := util.ParseToken(password); err == nil && user == name {
+		return mongodb.Viewer{Name: user, Role: role}, nil
+	}
+	user, err := usersys.Login(name, password)
+	if err != nil {
+		return mongodb.Viewer{}, util.Errorf("invalid username or password").WithCause(err).WithCode(codes.Unauthenticated)
+	}
+	return mongodb.Viewer{Name: user.Name, Role: user.Role}, nil
+}
+
+func davDocument(w http.ResponseWriter, r *http.Request, viewer mongodb.Viewer) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// only a download is the base of the next upload
+		document, etag, err := syncDocument(r.Context(), viewer, r.Method == http.MethodGet)
+		if err != nil {
+			util.WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Content-Length", fmt.Sprint(len(document)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(document)
+		}
+	case http.MethodPut:
+		body := http.MaxBytesReader(w, r.Body, *davMaxSize)
+		report, err := PutSyncDocument(r.Context(), viewer, body, r.Header.Get("If-Match"))
+		if util.HaveErrorCode(err, codes.FailedPrecondition) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, r, err)
+			return
+		}
+		if len(report.Conflicts) > 0 || len(report.Rejected) > 0 {
+			logrus.Infof("sync of %s: %d conflicts, %d rejected", viewer.Name, len(report.Conflicts), len(report.Rejected))
+		}
+		w.Header().Set("ETag", report.ETag)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, util.EncodeJson(report))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// expireDavLocks deletes the files not modified since davLockExpiry, of
+// every user. The caller holds davLocks.
+func expireDavLocks(now time.Time) {
+	for user, files := range davLocks.files {
+		for name, file := range files {
+			if now.Sub(file.modified) > davLockExpiry {
+				delete(files, name)
+			}
+		}
+		if len(files) == 0 {
+			delete(davLocks.files, user)
+		}
+	}
+}
+
+func davLockFile(w http.ResponseWriter, r *http.Request, viewer mongodb.Viewer, name string) {
+	davLocks.Lock()
+	defer davLocks.Unlock()
+	expireDavLocks(time.Now())
+	files := davLocks.files[viewer.Name]
+	file, ok := files[name]
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !ok {
+			util.WriteError(w, r, util.Errorf("no such file %s", name).WithCode(codes.NotFound))
+			return
+		}
+		w.Header().Set("Last-Modified", file.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", fmt.Sprint(len(file.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(file.data)
+		}
+	case http.MethodPut:
+		if !ok && len(files) >= maxDavLocks {
+			util.WriteError(w, r, util.Errorf("at most %d files besides %s", maxDavLocks, *davFile).WithCode(codes.ResourceExhausted))
+			return
+		}
+		// lock files are small
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDavLockSize))
+		if err != nil {
+			util.WriteError(w, r, util.Errorf("read %s failed", name).WithCause(err).WithCode(codes.InvalidArgument))
+			return
+		}
+		if files == nil {
+			files = map[string]davLock{}
+			davLocks.files[viewer.Name] = files
+		}
+		files[name] = davLock{data: data, modified: time.Now()}
+		if ok {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		if !ok {
+			util.WriteError(w, r, util.Errorf("no such file %s", name).WithCode(codes.NotFound))
+			return
+		}
+		delete(files, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type davResponse struct {
+	Href   string  `xml:"D:href"`
+	Prop   davProp `xml:"D:propstat>D:prop"`
+	Status string  `xml:"D:propstat>D:status"`
+}
+
+type davProp struct {
+	DisplayName  string    `xml:"D:displayname"`
+	ResourceType *struct{} `xml:"D:resourcetype>D:collection,omitempty"`
+	Length       int       `xml:"D:getcontentlength,omitempty"`
+	Type         string    `xml:"D:getcontenttype,omitempty"`
+	ETag         string    `xml:"D:getetag,omitempty"`
+	Modified     string    `xml:"D:getlastmodified"`
+}
+
+// davPropfind lists the collection and its files, or a file.
+func davPropfind(w http.ResponseWriter, r *http.Request, viewer mongodb.Viewer, name string) {
+	now := time.Now().UTC().Format(http.TimeFormat)
+	var responses []davResponse
+	if name == "" {
+		responses = append(responses, davResponse{
+			Href: DavPrefix + "/",
+			Prop: davProp{DisplayName: "bookmarks", ResourceType: &struct{}{}, Modified: now},
+		})
+	}
+	if name == *davFile || name == "" && r.Header.Get("Depth") != "0" {
+		document, etag, err := syncDocument(r.Context(), viewer, false)
+		if err != nil {
+			util.WriteError(w, r, err)
+			return
+		}
+		responses = append(responses, davResponse{
+			Href: path.Join(DavPrefix, *davFile),
+			Prop: davProp{DisplayName: *davFile, Length: len(document), Type: "application/xml", ETag: etag, Modified: now},
+		})
+	}
+	if name != *davFile {
+		davLocks.Lock()
+		expireDavLocks(time.Now())
+		for lockName, file := range davLocks.files[viewer.Name] {
+			if lockName == name || name == "" && r.Header.Get("Depth") != "0" {
+				responses = append(responses, davResponse{
+					Href: path.Join(DavPrefix, lockName),
+					Prop: davProp{DisplayName: lockName, Length: len(file.data), Modified: file.modified.UTC().Format(http.TimeFormat)},
+				})
+			}
+		}
+		davLocks.Unlock()
+	}
+	if len(responses) == 0 {
+		util.WriteError(w, r, util.Errorf("no such file %s", name).WithCode(codes.NotFound))
+		return
+	}
+	for i := range responses {
+		responses[i].Status = "HTTP/1.1 200 OK"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	err := xml.NewEncoder(&buf).Encode(struct {
+		XMLName   xml.Name      `xml:"D:multistatus"`
+		NS        string        `xml:"xmlns:D,attr"`
+		Responses []davResponse `xml:"D:response"`
+	}{NS: "DAV:", Responses: responses})
+	if err != nil {
+		util.WriteError(w, r, util.Errorf("write propfind response failed").WithCause(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,66 @@
+package datasys
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/mongodb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func putDavLock(viewer mongodb.Viewer, name, body string) int {
+	w := httptest.NewRecorder()
+	davLockFile(w, httptest.NewRequest(http.MethodPut, DavPrefix+"/"+name, strings.NewReader(body)), viewer, name)
+	return w.Code
+}
+
+func TestDavLockLimits(t *testing.T) {
+	defer func() { davLocks.files = map[string]map[string]davLock{} }()
+	user1, user2 := mongodb.Viewer{Name: "user1"}, mongodb.Viewer{Name: "user2"}
+
+	for i := 0; i < maxDavLocks; i++ {
+		if code := putDavLock(user1, fmt.Sprintf("lock%d", i), "locked"); code != http.StatusCreated {
+			t.Fatalf("put lock%d: status %d", i, code)
+		}
+	}
+	if code := putDavLock(user1, "one-more", "locked"); code != http.StatusTooManyRequests {
+		t.Errorf("put over the limit: status %d, want 429", code)
+	}
+	if code := putDavLock(user1, "lock0", "again"); code != http.StatusNoContent {
+		t.Errorf("overwrite at the limit: status %d, want 204", code)
+	}
+	// the limit is per user
+	if code := putDavLock(user2, "lock", "locked"); code != http.StatusCreated {
+		t.Errorf("put of another user: status %d", code)
+	}
+	if code := putDavLock(user2, "big", strings.Repeat("x", maxDavLockSize+1)); code != http.StatusBadRequest {
+		t.Errorf("put over the size limit: status %d, want 400", code)
+	}
+
+	// old files expire, freeing room
+	davLocks.Lock()
+	for name, file := range davLocks.files["user1"] {
+		if name != "lock0" {
+			file.modified = file.modified.Add(-davLockExpiry - time.Minute)
+			davLocks.files["user1"][name] = file
+		}
+	}
+	old := davLocks.files["user2"]["lock"]
+	old.modified = old.modified.Add(-davLockExpiry - time.Minute)
+	davLocks.files["user2"]["lock"] = old
+	davLocks.Unlock()
+
+	if code := putDavLock(user1, "one-more", "locked"); code != http.StatusCreated {
+		t.Errorf("put after expiry: status %d, want 201", code)
+	}
+	davLocks.Lock()
+	defer davLocks.Unlock()
+	if n := len(davLocks.files["user1"]); n != 2 {
+		t.Errorf("user1 keeps %d files, want lock0 and one-more", n)
+	}
+	if _, ok := davLocks.files["user2"]; ok {
+		t.Error("the expired files of user2 are kept")
+	}
+}
@@ -0,0 +1,77 @@
+package datasys
+
+import (
+	"context"
+	"server/archive"
+	"server/mongodb"
+	"server/util"
+
+	"google.golang.org/grpc/codes"
+)
+
+// FindDuplicates returns the web data visible to viewer grouped by their
+// canonical url, only groups of more than one.
+func FindDuplicates(viewer mongodb.Viewer) ([]mongodb.Duplicates, error) {
+	if err := requireLogin(viewer); err != nil {
+		return nil, err
+	}
+	return mongodb.FindDuplicates(viewer)
+}
+
+// MergeWeb merges the web data ids into keep and deletes them. Keep gets
+// the tags of all of them, and their name and description if it has none.
+func MergeWeb(viewer mongodb.Viewer, keep int, ids []int) (mongodb.WebData, error) {
+	kept, err := editableWebData(viewer, keep)
+	if err != nil {
+		return kept, err
+	}
+	if len(ids) == 0 {
+		return kept, util.Errorf("no web data to merge into %d", keep).WithCode(codes.InvalidArgument)
+	}
+	merged := make([]mongodb.WebData, 0, len(ids))
+	seen := map[int]bool{keep: true}
+	for _, id := range ids {
+		if seen[id] {
+			return kept, util.Errorf("web data %d merged twice", id).WithCode(codes.InvalidArgument)
+		}
+		seen[id] = true
+		// check all before changing any
+		webData, err := editableWebData(viewer, id)
+		if err != nil {
+			return kept, err
+		}
+		merged = append(merged, webData)
+	}
+
+	hasTag := make(map[string]bool, len(kept.Tags))
+	for _, tag := range kept.Tags {
+		hasTag[tag] = true
+	}
+	for _, webData := range merged {
+		for _, tag := range webData.Tags {
+			if !hasTag[tag] {
+				hasTag[tag] = true
+				kept.Tags = append(kept.Tags, tag)
+			}
+		}
+		if kept.Name == "" {
+			kept.Name = webData.Name
+		}
+		if kept.Description == "" {
+			kept.Description = webData.Description
+		}
+	}
+	// tag refs of kept are raised before the merged ones lower theirs
+	if err := mongodb.UpdateWebData(kept); err != nil {
+		return kept, err
+	}
+	for _, webData := range merged {
+		if err := archive.DeleteAll(context.Background(), webData.ID); err != nil {
+			return kept, err
+		}
+		if err := mongodb.DeleteWebData(webData.ID); err != nil {
+			return kept, err
+		}
+	}
+	return mongodb.GetWebDataByID(keep, viewer)
+}
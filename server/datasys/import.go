@@ -0,0 +1,284 @@
+package datasys
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"server/api"
+	"server/bookmarks"
+	"server/config"
+	"server/mongodb"
+	"server/urlnorm"
+	"server/util"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+var maxImportSize = &config.Current.Web.MaxImportSize
+
+// importFormats parse the bookmark files of POST /v1/web/import.
+var importFormats = map[string]func(io.Reader) ([]bookmarks.Bookmark, error){
+	"netscape": bookmarks.ParseNetscape,
+	"pinboard": bookmarks.ParsePinboard,
+	"pocket":   bookmarks.ParsePocket,
+	"raindrop": bookmarks.ParseRaindrop,
+}
+
+type (
+	ImportEntry   = api.ImportEntry
+	ImportReport  = api.ImportReport
+	ImportOptions = api.ImportOptions
+)
+
+// ParseImport reads a bookmark file of format.
+func ParseImport(format string, r io.Reader) ([]bookmarks.Bookmark, error) {
+	parse, ok := importFormats[format]
+	if !ok {
+		return nil, util.Errorf("unknown import format %s", format).WithCode(codes.InvalidArgument)
+	}
+	items, err := parse(r)
+	if err != nil {
+		return nil, util.Errorf("invalid %s bookmark file", format).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	return items, nil
+}
+
+// ImportWeb saves bookmarks as web data of viewer. Urls saved already are
+// not saved again, so importing a file twice changes nothing. Folders become tags, unless the outermost one is named
+// like a category, which then gets the tags created for its bookmarks.
+func ImportWeb(viewer mongodb.Viewer, items []bookmarks.Bookmark, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{DryRun: opts.DryRun, Created: []ImportEntry{}, Skipped: []ImportEntry{}, Conflicting: []ImportEntry{}, NewTags: []string{}}
+	if err := requireLogin(viewer); err != nil {
+		return report, err
+	}
+	if opts.Visibility == "" {
+		opts.Visibility = mongodb.VisibilityTeam
+	}
+	if !mongodb.ValidVisibility(opts.Visibility) {
+		return report, util.Errorf("invalid visibility %s", opts.Visibility).WithCode(codes.InvalidArgument)
+	}
+	im, err := newImporter(viewer, opts)
+	if err != nil {
+		return report, err
+	}
+	for _, item := range items {
+		if err := im.add(item, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+type importer struct {
+	viewer     mongodb.Viewer
+	opts       ImportOptions
+	categories map[string]primitive.ObjectID // by lower case name
+	tags       map[string]bool               // existing
+	seen       map[string]bool               // canonical urls of the file
+}
+
+func newImporter(viewer mongodb.Viewer, opts ImportOptions) (*importer, error) {
+	im := &importer{
+		viewer:     viewer,
+		opts:       opts,
+		categories: map[string]primitive.ObjectID{},
+		tags:       map[string]bool{},
+		seen:       map[string]bool{},
+	}
+	categories, err := mongodb.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		im.categories[strings.ToLower(c.Name)] = c.Id
+	}
+	tags, err := mongodb.GetAllTags(bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		im.tags[t.Name] = true
+	}
+	return im, nil
+}
+
+func (im *importer) add(item bookmarks.Bookmark, report *ImportReport) error {
+	tags, category := im.tagsOf(item)
+	webData := mongodb.WebData{
+		Name:        truncate(cleanText(item.Title), maxNameLen),
+		Url:         strings.TrimSpace(item.URL),
+		Description: truncate(strings.TrimSpace(item.Description), maxDescriptionLen),
+		Tags:        tags,
+		Owner:       im.viewer.Name,
+		Visibility:  im.opts.Visibility,
+		Created:     item.Created,
+	}
+	entry := ImportEntry{Url: webData.Url, Name: webData.Name, Tags: tags}
+	skip := func(reason string) {
+		entry.Reason = reason
+		report.Skipped = append(report.Skipped, entry)
+	}
+	conflict := func(reason string) {
+		entry.Reason = reason
+		report.Conflicting = append(report.Conflicting, entry)
+	}
+
+	if err := addWebRules.Validate(webData); err != nil {
+		skip(util.Message(err))
+		return nil
+	}
+	canonical, err := urlnorm.Normalize(webData.Url)
+	if err != nil {
+		skip("invalid url")
+		return nil
+	}
+	if im.seen[canonical] {
+		skip("repeated in the file")
+		return nil
+	}
+	im.seen[canonical] = true
+
+	existing, err := mongodb.GetWebDataByCanonical(im.viewer.Name, canonical)
+	switch {
+	case err == nil && containsAll(existing.Tags, tags):
+		entry.ID = existing.ID
+		skip("already saved")
+		return nil
+	case err == nil:
+		entry.ID = existing.ID
+		conflict("already saved with other tags")
+		return nil
+	case !util.HaveErrorCode(err, codes.NotFound):
+		return err
+	}
+
+	newTags, err := im.createTags(tags, category)
+	if err != nil {
+		return err
+	}
+	report.NewTags = append(report.NewTags, newTags...)
+	if im.opts.DryRun {
+		report.Created = append(report.Created, entry)
+		return nil
+	}
+	webData.Canonical = canonical
+	id, err := insertWeb(webData)
+	if util.HaveErrorCode(err, codes.AlreadyExists) {
+		conflict("already saved")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	report.Created = append(report.Created, entry)
+	return nil
+}
+
+// tagsOf returns the valid tag names of item and its folders, and the
+// category its outermost folder names.
+func (im *importer) tagsOf(item bookmarks.Bookmark) ([]string, primitive.ObjectID) {
+	var category primitive.ObjectID
+	folders := item.Folders
+	if len(folders) > 0 {
+		if id, ok := im.categories[strings.ToLower(strings.TrimSpace(folders[0]))]; ok {
+			category = id
+			folders = folders[1:]
+		}
+	}
+	var tags []string
+	for _, name := range append(append([]string{}, item.Tags...), folders...) {
+		name = truncate(cleanText(name), maxTagNameLen)
+		if name != "" && !contains(tags, name) && len(tags) < maxTags {
+			tags = append(tags, name)
+		}
+	}
+	return tags, category
+}
+
+// createTags creates the missing tags, a dry run only returns them. Their
+// refs are counted when the web data is saved.
+func (im *importer) createTags(tags []string, category primitive.ObjectID) ([]string, error) {
+	var created []string
+	for _, name := range tags {
+		if im.tags[name] {
+			continue
+		}
+		if !im.opts.DryRun {
+			err := mongodb.AddTag(mongodb.Tag{Name: name, Category: category})
+			if util.HaveErrorCode(err, codes.AlreadyExists) {
+				im.tags[name] = true
+				continue
+			}
+			if err != nil {
+				return created, err
+			}
+		}
+		im.tags[name] = true
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// cleanText trims s and drops its control characters.
+func cleanText(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, "")))
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n]))
+}
+
+func containsAll(list []string, items []string) bool {
+	for _, item := range items {
+		if !contains(list, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExportWeb returns the web data of viewer as a Netscape bookmark file. Each
+// one is put in the folder of its first tag and keeps all tags in TAGS.
+func ExportWeb(ctx context.Context, viewer mongodb.Viewer) ([]byte, error) {
+	if err := requireLogin(viewer); err != nil {
+		return nil, err
+	}
+	var items []bookmarks.Bookmark
+	err := mongodb.EachWebData(ctx, bson.M{"owner": viewer.Name}, viewer, func(data mongodb.WebData) error {
+		item := bookmarks.Bookmark{
+			URL:         data.Url,
+			Title:       data.Name,
+			Description: data.Description,
+			Tags:        data.Tags,
+			Created:     data.Created,
+			Updated:     data.Updated,
+		}
+		if len(data.Tags) > 0 {
+			item.Folders = data.Tags[:1]
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := bookmarks.WriteNetscape(&buf, items); err != nil {
+		return nil, util.Errorf("write bookmark file failed").WithCause(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package datasys
+
+import (
+	"html"
+	"server/mongodb"
+	"server/util"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+)
+
+const snippetLen = 160
+
+// SearchHit is a full text search result with highlighted fields.
+type SearchHit struct {
+	mongodb.ScoredWebData
+	// Highlights holds html escaped snippets of the matching fields, with
+	// matched terms wrapped in <mark>.
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchResult is one page of search hits and the total number of matches.
+type SearchResult struct {
+	Items []SearchHit `json:"items"`
+	Total int64       `json:"total"`
+}
+
+// SearchText ranks the web data visible to viewer by how well Name,
+// Description and Url match text. tagQuery narrows the result, see ListWeb.
+func SearchText(viewer mongodb.Viewer, text, tagQuery string, limit, offset int) (SearchResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return SearchResult{}, util.Errorf("empty search text").WithCode(codes.InvalidArgument)
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 0 || limit > maxPageLimit || offset < 0 {
+		return SearchResult{}, util.Errorf("limit must be between 1 and %d", maxPageLimit).WithCode(codes.InvalidArgument)
+	}
+	filter, err := tagFilter(tagQuery)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	datas, total, err := mongodb.SearchWebData(text, filter, viewer, limit, offset)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	terms := searchTerms(text)
+	result := SearchResult{Items: make([]SearchHit, 0, len(datas)), Total: total}
+	for _, data := range datas {
+		hit := SearchHit{ScoredWebData: data, Highlights: map[string]string{}}
+		for field, value := range map[string]string{"Name": data.Name, "Description": data.Description, "Url": data.Url, "Content": data.Content} {
+			if snippet, ok := highlight(value, terms); ok {
+				hit.Highlights[field] = snippet
+			}
+		}
+		result.Items = append(result.Items, hit)
+	}
+	return result, nil
+}
+
+// sameLenLower lowers text keeping byte offsets, so they match text.
+// Invalid utf-8 is kept as it is, strings.Map would widen it.
+func sameLenLower(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if l := unicode.ToLower(r); r != utf8.RuneError && utf8.RuneLen(l) == size {
+			b.WriteRune(l)
+		} else {
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// searchTerms returns the words of a mongodb $search string, leaving out
+// negated ones.
+func searchTerms(text string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ReplaceAll(text, `"`, " ")) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		terms = append(terms, sameLenLower(word))
+	}
+	return terms
+}
+
+// highlight cuts a snippet of text around the first term and marks every
+// term in it.
+func highlight(text string, terms []string) (string, bool) {
+	lower := sameLenLower(text)
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start := first - snippetLen/4
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := start + snippetLen
+	if end > len(text) {
+		end = len(text)
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) && len(term) > len(matched) {
+				matched = term
+			}
+		}
+		if matched == "" || i+len(matched) > end {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(html.EscapeString(text[i : i+size]))
+			i += size
+			continue
+		}
+		b.WriteString("<mark>" + html.EscapeString(text[i:i+len(matched)]) + "</mark>")
+		i += len(matched)
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,95 @@
+package datasys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSameLenLower(t *testing.T) {
+	for text, want := range map[string]string{
+		"Go Generics": "go generics",
+		"ÄÖÜ Straße":  "äöü straße",
+		"数据库 DB":      "数据库 db",
+		// the lower case of the Kelvin sign and İ are shorter, they are kept
+		"\u212a Kelvin": "\u212a kelvin",
+		"\u0130stanbul": "\u0130stanbul",
+		"bad \xff UTF8": "bad \xff utf8",
+		"\ufffd A":      "\ufffd a",
+	} {
+		got := sameLenLower(text)
+		if got != want {
+			t.Errorf("sameLenLower(%q) = %q, want %q", text, got, want)
+		}
+		if len(got) != len(text) {
+			t.Errorf("sameLenLower(%q) has %d bytes, want %d", text, len(got), len(text))
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms(`Go "Type Theory" -rust 数据库`)
+	want := []string{"go", "type", "theory", "数据库"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("searchTerms = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tt := range []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Go Generics", []string{"generics"}, "Go <mark>Generics</mark>"},
+		{"go, Go and GO", []string{"go"}, "<mark>go</mark>, <mark>Go</mark> and <mark>GO</mark>"},
+		// the longest term wins where terms overlap
+		{"golang", []string{"go", "golang"}, "<mark>golang</mark>"},
+		{"<b>Go</b> & more", []string{"go"}, "&lt;b&gt;<mark>Go</mark>&lt;/b&gt; &amp; more"},
+		{"数据库设计入门", []string{"设计"}, "数据库<mark>设计</mark>入门"},
+		{"Straße ÄRGER", []string{"ärger"}, "Straße <mark>ÄRGER</mark>"},
+		// invalid utf-8 keeps the offsets of the matches
+		{"\xff\xfeGo", []string{"go"}, "\xff\xfe<mark>Go</mark>"},
+	} {
+		got, ok := highlight(tt.text, tt.terms)
+		if !ok || got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, %v, want %q", tt.text, tt.terms, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := highlight("Go Generics", []string{"rust"}); ok || got != "" {
+		t.Errorf("highlight without match = %q, %v", got, ok)
+	}
+}
+
+// TestHighlightSnippet checks long text is cut around the first match at
+// rune boundaries.
+func TestHighlightSnippet(t *testing.T) {
+	text := strings.Repeat("数据", 100) + "Match" + strings.Repeat("库", 200)
+	got, ok := highlight(text, []string{"match"})
+	if !ok {
+		t.Fatal("no match")
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("snippet %q is cut inside a rune", got)
+	}
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "<mark>Match</mark>") {
+		t.Errorf("snippet = %q", got)
+	}
+	plain := strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(got)
+	if len(plain) < snippetLen || len(plain) > snippetLen+utf8.UTFMax {
+		t.Errorf("snippet has %d bytes of text, want about %d", len(plain), snippetLen)
+	}
+	// the snippet starts at the rune before snippetLen/4 bytes
+	if i := strings.Index(plain, "Match"); i < snippetLen/4 || i >= snippetLen/4+utf8.UTFMax {
+		t.Errorf("match at byte %d of the snippet, want about %d", i, snippetLen/4)
+	}
+
+	// a match crossing the end of the snippet is not marked
+	text = "Go " + strings.Repeat("x", snippetLen) + "go"
+	got, _ = highlight(text, []string{"go"})
+	if strings.Count(got, "<mark>") != 1 || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet = %q", got)
+	}
+}
@@ -1,55 +1,55 @@
 package datasys
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"server/api"
 	"server/mongodb"
-	"server/usersys"
 	"server/util"
+	"server/validate"
 	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"google.golang.org/grpc/codes"
-	// "github.com/sirupsen/logrus"
 )
 
+// webDataOf returns the web data of a request, the fields the server owns
+// are left empty.
+func webDataOf(req api.WebRequest) mongodb.WebData {
+	return mongodb.WebData{
+		ID:          req.ID,
+		Name:        req.Name,
+		Url:         req.Url,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Visibility:  req.Visibility,
+	}
+}
+
 func HandleAddWeb(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	// _, role, err := util.GetUser(w, r)
-	// if err != nil {
-	// 	logrus.Error(util.Errorf("get user failed").WithCause(err))
-	// 	return
-	// }
-	// if role < util.RoleManager {
-	// 	w.WriteHeader(http.StatusForbidden)
-	// 	return
-	// }
-
-	webJson := r.Body
-	decoder := json.NewDecoder(webJson)
-	var webData mongodb.WebData
-	if err := decoder.Decode(&webData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	var req api.WebRequest
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
+	// the id is assigned by the server
+	req.ID = 0
 
-	if _, err := mongodb.AddWebData(webData); err != nil {
-		if util.HaveErrorCode(err, codes.AlreadyExists) {
-			w.WriteHeader(http.StatusConflict)
-			fmt.Fprint(w, err.Error())
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if _, err := AddWeb(viewer, webDataOf(req)); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
@@ -57,24 +57,146 @@ func HandleAddWeb(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "success")
 }
 
+func HandleListWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	pageRequest, err := parsePageRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	page, err := ListWeb(viewer, r.URL.Query().Get("q"), pageRequest)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(page))
+}
+
+func HandleListBrokenWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	pageRequest, err := parsePageRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	page, err := ListBrokenWeb(viewer, pageRequest)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(page))
+}
+
 func HandleSearchWeb(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	tagsString := mux.Vars(r)["tags"]
-	tags := strings.Split(tagsString, ",")
+	// a tag query, plain comma separated tags are an AND query
+	query := mux.Vars(r)["tags"]
+
+	pageRequest, err := parsePageRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	page, err := ListWeb(viewer, query, pageRequest)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(page))
+}
+
+func HandleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var limit, offset int
+	for name, value := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if s := query.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				util.WriteError(w, r, util.Errorf("invalid %s %s", name, s).WithCause(err).WithCode(codes.InvalidArgument))
+				return
+			}
+			*value = n
+		}
+	}
 
-	webDatas, err := mongodb.GetWebDataByTags(tags)
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	result, err := SearchText(viewer, query.Get("q"), query.Get("tags"), limit, offset)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, util.EncodeJson(webDatas))
+	fmt.Fprint(w, util.EncodeJson(result))
+}
+
+// parsePageRequest reads the limit, cursor, sort and order query values.
+func parsePageRequest(r *http.Request) (mongodb.PageRequest, error) {
+	query := r.URL.Query()
+	req := mongodb.PageRequest{
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		req.Desc = true
+	default:
+		return req, util.Errorf("invalid order %s", order).WithCode(codes.InvalidArgument)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return req, util.Errorf("invalid limit %s", limit).WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		req.Limit = n
+	}
+	return req, nil
 }
 
 func HandleDeleteWeb(w http.ResponseWriter, r *http.Request) {
@@ -83,28 +205,21 @@ func HandleDeleteWeb(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// _, role, err := util.GetUser(w, r)
-	// if err != nil {
-	// 	logrus.Error(util.Errorf("get user failed").WithCause(err))
-	// 	return
-	// }
-	// if role < util.RoleManager {
-	// 	w.WriteHeader(http.StatusForbidden)
-	// 	return
-	// }
-
 	idString := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
 		return
 	}
 
-	err = mongodb.DeleteWebData(id)
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	err = DeleteWeb(viewer, id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
@@ -112,131 +227,408 @@ func HandleDeleteWeb(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "success")
 }
 
-func HandlePatchWeb(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPatch {
+func HandleRefetchWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	// _, role, err := util.GetUser(w, r)
-	// if err != nil {
-	// 	logrus.Error(util.Errorf("get user failed").WithCause(err))
-	// 	return
-	// }
-	// if role < util.RoleManager {
-	// 	w.WriteHeader(http.StatusForbidden)
-	// 	return
-	// }
-
 	idString := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
 		return
 	}
 
-	webJson := r.Body
-	decoder := json.NewDecoder(webJson)
-	var webData mongodb.WebData
-	if err := decoder.Decode(&webData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if err := RefetchWeb(viewer, id); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	webData.ID = id
 
-	err = mongodb.UpdateWebData(webData)
+	// the page is fetched in the background
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "success")
+}
+
+func HandlePreviewWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	preview, err := PreviewWeb(r.Context(), viewer, r.URL.Query().Get("url"))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "success")
+	fmt.Fprint(w, util.EncodeJson(preview))
 }
 
-func HandleAddTag(w http.ResponseWriter, r *http.Request) {
+// HandleClipWeb suggests how to save the page of a browser extension.
+func HandleClipWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ClipRequest
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	clip, err := ClipWeb(r.Context(), viewer, req)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(clip))
+}
+
+func HandleSaveClip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var save ClipSave
+	if err := util.DecodeBody(w, r, &save); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	id, err := SaveClip(viewer, save)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(map[string]int{"id": id}))
+}
+
+func HandleFindDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	duplicates, err := FindDuplicates(viewer)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(duplicates))
+}
+
+// MergeRequest merges the web data Merge into Keep.
+type MergeRequest struct {
+	Keep  int   `json:"keep"`
+	Merge []int `json:"merge"`
+}
+
+func HandleMergeDuplicates(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	_, _, err := util.GetUser(w, r)
+	var req MergeRequest
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	webData, err := MergeWeb(viewer, req.Keep, req.Merge)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(webData))
+}
+
+func HandleImportWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
 	if err != nil {
-		logrus.Error(util.Errorf("get user failed").WithCause(err))
+		util.WriteError(w, r, err)
+		return
+	}
+	// before reading a file that would be rejected anyway
+	if err := requireLogin(viewer); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	// if role < util.RoleManager {
-	// 	w.WriteHeader(http.StatusForbidden)
-	// 	return
-	// }
 
-	tagJson := r.Body
-	decoder := json.NewDecoder(tagJson)
-	var tagData mongodb.Tag
-	if err := decoder.Decode(&tagData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	// the file is the raw body or the "file" field of a multipart form
+	body := http.MaxBytesReader(w, r.Body, *maxImportSize)
+	var file io.Reader = body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = body
+		part, _, err := r.FormFile("file")
+		if err != nil {
+			util.WriteError(w, r, util.Errorf("invalid import form").WithCause(err).WithCode(codes.InvalidArgument))
+			return
+		}
+		defer part.Close()
+		file = part
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "netscape"
+	}
+	items, err := ParseImport(format, file)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
-	if err := mongodb.AddTag(tagData); err != nil {
-		if util.HaveErrorCode(err, codes.AlreadyExists) {
-			w.WriteHeader(http.StatusConflict)
-			fmt.Fprint(w, err.Error())
+	opts := ImportOptions{Visibility: query.Get("visibility")}
+	if dryRun := query.Get("dryRun"); dryRun != "" {
+		if opts.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			util.WriteError(w, r, util.Errorf("invalid dryRun %s", dryRun).WithCause(err).WithCode(codes.InvalidArgument))
 			return
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	}
+	report, err := ImportWeb(viewer, items, opts)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "success")
+	fmt.Fprint(w, util.EncodeJson(report))
 }
 
-func HandleGetAllTags(w http.ResponseWriter, r *http.Request) {
+func HandleExportWeb(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	filter := bson.M{"category": bson.M{"$exists": false}}
-	tags, err := mongodb.GetAllTags(filter)
+	if format := r.URL.Query().Get("format"); format != "" && format != "netscape" {
+		util.WriteError(w, r, util.Errorf("unknown export format %s", format).WithCode(codes.InvalidArgument))
+		return
+	}
+	viewer, err := viewerFromRequest(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
+		return
+	}
+	file, err := ExportWeb(r.Context(), viewer)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="bookmarks.html"`)
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, util.EncodeJson(tags))
+	w.Write(file)
 }
 
-func HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+func HandleWebIcon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	_, role, err := util.GetUser(w, r)
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idString)
 	if err != nil {
-		logrus.Error(util.Errorf("get user failed").WithCause(err))
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
 		return
 	}
-	if role < util.RoleManager {
-		w.WriteHeader(http.StatusForbidden)
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	icon, err := GetWebIcon(viewer, id)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
-	name := mux.Vars(r)["name"]
+	w.Header().Set("Content-Type", icon.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("Last-Modified", icon.FetchedAt.UTC().Format(http.TimeFormat))
+	// svg icons may carry scripts
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(icon.Data)
+}
+
+func HandleTakeSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
+		return
+	}
 
-	err = mongodb.DeleteTag(name)
+	viewer, err := viewerFromRequest(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
+		return
+	}
+	snapshot, err := TakeSnapshot(viewer, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	// the snapshot is taken in the background
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(w, util.EncodeJson(snapshot))
+}
+
+func HandleGetSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	snapshots, err := GetSnapshots(viewer, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(snapshots))
+}
+
+func HandleServeSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
+		return
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	page, err := OpenSnapshot(r.Context(), viewer, id, mux.Vars(r)["snapshot"])
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	defer page.Close()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// snapshots are foreign pages served from our origin, they may only
+	// show their inlined assets and never run scripts
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; font-src data:; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, page)
+}
+
+func HandlePatchWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		util.WriteError(w, r, util.Errorf("invalid web id %s", idString).WithCause(err).WithCode(codes.InvalidArgument))
+		return
+	}
+
+	var req api.WebRequest
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if req.ID != 0 && req.ID != id {
+		util.WriteError(w, r, validate.Error(util.FieldError{Field: "ID", Message: "does not match the path"}))
+		return
+	}
+	req.ID = id
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	err = UpdateWeb(viewer, webDataOf(req))
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
@@ -244,33 +636,115 @@ func HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "success")
 }
 
-func HandleReorderTag(w http.ResponseWriter, r *http.Request) {
-	user := usersys.GetUserFromCookie(w, r)
-	if user == nil {
+// viewerFromRequest returns the token or session user, or an anonymous viewer.
+func viewerFromRequest(r *http.Request) (mongodb.Viewer, error) {
+	user, role, err := util.LookupUser(r)
+	if err != nil {
+		return mongodb.Viewer{}, err
+	}
+	return mongodb.Viewer{Name: user, Role: role}, nil
+}
+
+func HandleAddTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req api.TagRequest
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if err := tagRequestRules.Validate(req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	tagData := mongodb.Tag{Name: req.Name}
+	if req.Category != "" {
+		tagData.Category, _ = primitive.ObjectIDFromHex(req.Category)
+	}
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if err := AddTag(viewer, tagData); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "success")
+}
+
+func HandleGetAllTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	tags, err := GetTags()
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(tags))
+}
+
+func HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	err = DeleteTag(viewer, name)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "success")
+}
+
+func HandleReorderTag(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 	name := mux.Vars(r)["name"]
-	tagJson := r.Body
-	decoder := json.NewDecoder(tagJson)
-	var tagData mongodb.Tag
-	if err := decoder.Decode(&tagData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	var req api.TagUpdate
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	if err := tagUpdateRules.Validate(req); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	tagData.Name = name
-	err := mongodb.UpdateTag(name, tagData)
+	err = UpdateTag(viewer, name, tagPatch(req))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	w.WriteHeader(http.StatusNotImplemented)
+	fmt.Fprintf(w, "success")
 }
 
 func HandleGetAllCategories(w http.ResponseWriter, r *http.Request) {
@@ -279,10 +753,10 @@ func HandleGetAllCategories(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	categories, err := mongodb.GetAllCategories()
+	categories, err := GetCategories()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, util.EncodeJson(categories))
@@ -296,19 +770,20 @@ func HandleUpdateCategory(w http.ResponseWriter, r *http.Request) {
 
 	id := mux.Vars(r)["id"]
 
-	categoryJson := r.Body
-	decoder := json.NewDecoder(categoryJson)
-	var categoryData mongodb.Category
-	if err := decoder.Decode(&categoryData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	var req api.CategoryUpdate
+	if err := util.DecodeBody(w, r, &req); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
-	err := mongodb.UpdateCategory(id, categoryData)
+	viewer, err := viewerFromRequest(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	err = UpdateCategory(viewer, id, mongodb.Category{Name: req.Name})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
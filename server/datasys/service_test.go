@@ -0,0 +1,123 @@
+package datasys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"server/api"
+	"server/mongodb"
+	"server/util"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+// TestServerOwnedFields checks the fields the server owns are unknown to
+// the request bodies, before anything is looked up.
+func TestServerOwnedFields(t *testing.T) {
+	for _, tt := range []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+		field   string
+	}{
+		{HandleAddWeb, http.MethodPost, `{"Url":"https://go.dev","Owner":"admin"}`, "Owner"},
+		{HandleAddWeb, http.MethodPost, `{"Url":"https://go.dev","Fetch":{"Status":"done"}}`, "Fetch"},
+		{HandleAddWeb, http.MethodPost, `{"Url":"https://go.dev","Canonical":"go.dev"}`, "Canonical"},
+		{HandlePatchWeb, http.MethodPatch, `{"Url":"https://go.dev","Icon":"x.png"}`, "Icon"},
+		{HandlePatchWeb, http.MethodPatch, `{"Url":"https://go.dev","Link":{}}`, "Link"},
+		{HandlePatchWeb, http.MethodPatch, `{"Url":"https://go.dev","Enrich":"done"}`, "Enrich"},
+		{HandleAddTag, http.MethodPost, `{"Name":"go","Ref":7}`, "Ref"},
+		{HandleUpdateCategory, http.MethodPatch, `{"name":"dev","tags":[]}`, "tags"},
+	} {
+		r := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+		r = mux.SetURLVars(r, map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+		tt.handler(w, r)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field": "`+tt.field+`"`) {
+			t.Errorf("%s: status %d, body %s, want field %s rejected", tt.body, w.Code, w.Body, tt.field)
+		}
+	}
+}
+
+func TestPatchWebID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"ID":2,"Url":"https://go.dev"}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+	HandlePatchWeb(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field": "ID"`) {
+		t.Errorf("status %d, body %s, want the id rejected", w.Code, w.Body)
+	}
+}
+
+func TestTagUpdate(t *testing.T) {
+	category := primitive.NewObjectID()
+	order := 3
+	for _, tt := range []struct {
+		body string
+		want mongodb.TagPatch
+	}{
+		{`{}`, mongodb.TagPatch{}},
+		{`{"Order":3}`, mongodb.TagPatch{Order: &order}},
+		{`{"Category":"` + category.Hex() + `"}`, mongodb.TagPatch{Category: &category}},
+		{`{"Category":null}`, mongodb.TagPatch{Category: &primitive.NilObjectID}},
+		{`{"Category":""}`, mongodb.TagPatch{Category: &primitive.NilObjectID}},
+	} {
+		update, err := decodeTagUpdate(tt.body)
+		if err == nil {
+			err = tagUpdateRules.Validate(update)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.body, err)
+			continue
+		}
+		if got := tagPatch(update); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: patch = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+
+	for body, field := range map[string]string{
+		`{"Ref":0}`:          "Ref",
+		`{"Order":-1}`:       "Order",
+		`{"Category":"dev"}`: "Category",
+		`{"Category":1}`:     "Category",
+	} {
+		update, err := decodeTagUpdate(body)
+		if err == nil {
+			err = tagUpdateRules.Validate(update)
+		}
+		if err == nil {
+			err = tagPatchRules.Validate(tagPatch(update))
+		}
+		if !util.HaveErrorCode(err, codes.InvalidArgument) {
+			t.Errorf("%s: error %v, want InvalidArgument", body, err)
+			continue
+		}
+		if fields := util.Fields(err); len(fields) != 1 || fields[0].Field != field {
+			t.Errorf("%s: fields %+v, want %s", body, fields, field)
+		}
+	}
+}
+
+func decodeTagUpdate(body string) (api.TagUpdate, error) {
+	var update api.TagUpdate
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	err := util.DecodeBody(httptest.NewRecorder(), r, &update)
+	return update, err
+}
+
+func TestTagNames(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"Dev Tools": true, "C++/C#": true, "-draft": true, "and": true, "标签": true,
+		"a,b": false, "a|b": false, "(a)": false, "a&b": false, "!a": false, `"a"`: false,
+	} {
+		tagErr := tagRules.Validate(mongodb.Tag{Name: name})
+		webErr := addWebRules.Validate(mongodb.WebData{Url: "https://go.dev", Tags: []string{name}})
+		if (tagErr == nil) != ok || (webErr == nil) != ok {
+			t.Errorf("tag name %q: tag %v, web data %v, want ok %v", name, tagErr, webErr, ok)
+		}
+	}
+}
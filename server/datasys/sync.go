@@ -0,0 +1,414 @@
+package datasys
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"server/bookmarks"
+	"server/mongodb"
+	"server/urlnorm"
+	"server/util"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+// Bookmark sync serves the web data a user owns as an XBEL document and
+// applies uploaded documents back. A bookmark is put in the folder of its
+// first tag, in the folder of the tag's category if it has one. The
+// document a user got last is kept as the base of a three way merge: what
+// only the client changed is applied, what both changed differently is a
+// conflict the server side wins. Clients syncing one user from several
+// devices lock and download before they upload, as Floccus does, or send
+// If-Match.
+
+// SyncConflict is an uploaded change that was not applied.
+type SyncConflict struct {
+	ID     int    `json:"id,omitempty"`
+	Url    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// SyncReport tells what an upload changed, by web data ID.
+type SyncReport struct {
+	ETag      string         `json:"etag"`
+	Created   []int          `json:"created"`
+	Updated   []int          `json:"updated"`
+	Deleted   []int          `json:"deleted"`
+	Conflicts []SyncConflict `json:"conflicts"`
+	// Rejected are bookmarks no web data can be saved for, like
+	// bookmarklets.
+	Rejected []SyncConflict `json:"rejected"`
+}
+
+// syncLocks serializes the syncs of a user.
+var syncLocks sync.Map
+
+func lockSync(owner string) func() {
+	mu, _ := syncLocks.LoadOrStore(owner, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// syncData is the server side of a sync.
+type syncData struct {
+	items      map[int]mongodb.WebData
+	byURL      map[string]int // canonical url to ID
+	categories map[primitive.ObjectID]string
+	// tagCategory is the category of each tag, zero for none
+	tagCategory map[string]primitive.ObjectID
+}
+
+func loadSyncData(ctx context.Context, viewer mongodb.Viewer) (*syncData, error) {
+	data := &syncData{
+		items:       map[int]mongodb.WebData{},
+		byURL:       map[string]int{},
+		categories:  map[primitive.ObjectID]string{},
+		tagCategory: map[string]primitive.ObjectID{},
+	}
+	categories, err := mongodb.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		data.categories[c.Id] = c.Name
+	}
+	tags, err := mongodb.GetAllTags(bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		data.tagCategory[t.Name] = t.Category
+	}
+	err = mongodb.EachWebData(ctx, bson.M{"owner": viewer.Name}, viewer, func(d mongodb.WebData) error {
+		data.items[d.ID] = d
+		if d.Canonical != "" {
+			data.byURL[d.Canonical] = d.ID
+		}
+		return nil
+	})
+	return data, err
+}
+
+// item returns web data as a bookmark of the document.
+func (s *syncData) item(d mongodb.WebData) mongodb.SyncItem {
+	item := mongodb.SyncItem{ID: d.ID, Url: d.Url, Name: d.Name, Description: d.Description}
+	if len(d.Tags) > 0 {
+		if name, ok := s.categories[s.tagCategory[d.Tags[0]]]; ok {
+			item.Path = append(item.Path, name)
+		}
+		item.Path = append(item.Path, d.Tags[0])
+	}
+	return item
+}
+
+// document returns the items of the web data by ID and the XBEL document.
+func (s *syncData) document() ([]mongodb.SyncItem, []byte, error) {
+	ids := make([]int, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	items := make([]mongodb.SyncItem, 0, len(ids))
+	marks := make([]bookmarks.Bookmark, 0, len(ids))
+	for _, id := range ids {
+		item := s.item(s.items[id])
+		items = append(items, item)
+		marks = append(marks, bookmarks.Bookmark{
+			ID:          item.ID,
+			URL:         item.Url,
+			Title:       item.Name,
+			Description: item.Description,
+			Folders:     item.Path,
+			Created:     s.items[id].Created,
+			Updated:     s.items[id].Updated,
+		})
+	}
+	var buf bytes.Buffer
+	if err := bookmarks.WriteXBEL(&buf, marks); err != nil {
+		return nil, nil, util.Errorf("write xbel document failed").WithCause(err)
+	}
+	return items, buf.Bytes(), nil
+}
+
+// tagsOf returns the tags of a folder path and the category of its
+// outermost folder, which is no tag.
+func (s *syncData) tagsOf(path []string) ([]string, primitive.ObjectID) {
+	var category primitive.ObjectID
+	if len(path) > 0 {
+		for id, name := range s.categories {
+			if strings.EqualFold(name, strings.TrimSpace(path[0])) {
+				category = id
+				path = path[1:]
+				break
+			}
+		}
+	}
+	var tags []string
+	for _, name := range path {
+		name = truncate(cleanText(name), maxTagNameLen)
+		if name != "" && !contains(tags, name) {
+			tags = append(tags, name)
+		}
+	}
+	return tags, category
+}
+
+func syncETag(document []byte) string {
+	sum := sha256.Sum256(document)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// syncDocument returns the XBEL document of viewer and its ETag, keep
+// saves it as the base of the next upload.
+func syncDocument(ctx context.Context, viewer mongodb.Viewer, keep bool) ([]byte, string, error) {
+	if err := requireLogin(viewer); err != nil {
+		return nil, "", err
+	}
+	defer lockSync(viewer.Name)()
+	data, err := loadSyncData(ctx, viewer)
+	if err != nil {
+		return nil, "", err
+	}
+	items, document, err := data.document()
+	if err != nil {
+		return nil, "", err
+	}
+	etag := syncETag(document)
+	if keep {
+		if err := mongodb.SaveSyncState(mongodb.SyncState{Owner: viewer.Name, ETag: etag, Items: items}); err != nil {
+			return nil, "", err
+		}
+	}
+	return document, etag, nil
+}
+
+// PutSyncDocument applies an uploaded XBEL document. With ifMatch set, it
+// fails with codes.FailedPrecondition unless the document on the server
+// still has that ETag.
+func PutSyncDocument(ctx context.Context, viewer mongodb.Viewer, r io.Reader, ifMatch string) (SyncReport, error) {
+	report := SyncReport{Created: []int{}, Updated: []int{}, Deleted: []int{}, Conflicts: []SyncConflict{}, Rejected: []SyncConflict{}}
+	if err := requireLogin(viewer); err != nil {
+		return report, err
+	}
+	marks, err := bookmarks.ParseXBEL(r)
+	if err != nil {
+		return report, util.Errorf("invalid xbel document").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+
+	defer lockSync(viewer.Name)()
+	data, err := loadSyncData(ctx, viewer)
+	if err != nil {
+		return report, err
+	}
+	if ifMatch != "" && ifMatch != "*" {
+		_, document, err := data.document()
+		if err != nil {
+			return report, err
+		}
+		if etag := syncETag(document); ifMatch != etag {
+			return report, util.Errorf("the document changed, its etag is %s", etag).WithCode(codes.FailedPrecondition)
+		}
+	}
+	base := map[int]mongodb.SyncItem{}
+	state, err := mongodb.GetSyncState(viewer.Name)
+	if err != nil && !util.HaveErrorCode(err, codes.NotFound) {
+		return report, err
+	}
+	for _, item := range state.Items {
+		base[item.ID] = item
+	}
+	client := make([]mongodb.SyncItem, 0, len(marks))
+	for _, b := range marks {
+		client = append(client, mongodb.SyncItem{ID: b.ID, Url: b.URL, Name: cleanText(b.Title), Description: strings.TrimSpace(b.Description), Path: b.Folders})
+	}
+
+	changes := planSync(base, client, data)
+	report.Conflicts = append(report.Conflicts, changes.conflicts...)
+	for _, c := range changes.list {
+		if err := data.apply(viewer, c, &report); err != nil {
+			return report, err
+		}
+	}
+
+	// the server side is the base of the next upload
+	if data, err = loadSyncData(ctx, viewer); err != nil {
+		return report, err
+	}
+	items, document, err := data.document()
+	if err != nil {
+		return report, err
+	}
+	report.ETag = syncETag(document)
+	return report, mongodb.SaveSyncState(mongodb.SyncState{Owner: viewer.Name, ETag: report.ETag, Items: items})
+}
+
+// kinds of syncChange
+const (
+	syncCreate = "create"
+	syncUpdate = "update"
+	syncDelete = "delete"
+)
+
+type syncChange struct {
+	kind string
+	// item is the client side, or the base of a delete
+	item mongodb.SyncItem
+	// basePath is the folder path the client moved an update from
+	basePath []string
+}
+
+type syncPlan struct {
+	list      []syncChange
+	conflicts []SyncConflict
+}
+
+// planSync merges the client items with the server data, base is what the
+// client got last.
+func planSync(base map[int]mongodb.SyncItem, client []mongodb.SyncItem, data *syncData) syncPlan {
+	var plan syncPlan
+	conflict := func(item mongodb.SyncItem, reason string) {
+		plan.conflicts = append(plan.conflicts, SyncConflict{ID: item.ID, Url: item.Url, Reason: reason})
+	}
+	seen := map[int]bool{}
+	newURLs := map[string]bool{}
+	for _, c := range client {
+		b, inBase := base[c.ID]
+		if c.ID == 0 || !inBase || seen[c.ID] {
+			// new on the client, its id is no web data ID
+			canonical, err := urlnorm.Normalize(c.Url)
+			if err == nil {
+				if _, saved := data.byURL[canonical]; saved || newURLs[canonical] {
+					continue
+				}
+				newURLs[canonical] = true
+			}
+			c.ID = 0
+			plan.list = append(plan.list, syncChange{kind: syncCreate, item: c})
+			continue
+		}
+		seen[c.ID] = true
+		if sameSyncItem(c, b) {
+			continue
+		}
+		d, onServer := data.items[c.ID]
+		if !onServer {
+			conflict(c, "deleted on the server")
+			continue
+		}
+		s := data.item(d)
+		if sameSyncItem(s, c) {
+			continue
+		}
+		if !sameSyncItem(s, b) {
+			conflict(c, "changed on the server too")
+			continue
+		}
+		plan.list = append(plan.list, syncChange{kind: syncUpdate, item: c, basePath: b.Path})
+	}
+	ids := make([]int, 0, len(base))
+	for id := range base {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		d, onServer := data.items[id]
+		if !onServer {
+			continue
+		}
+		if !sameSyncItem(data.item(d), base[id]) {
+			conflict(base[id], "changed on the server, deleted on the client")
+			continue
+		}
+		plan.list = append(plan.list, syncChange{kind: syncDelete, item: base[id]})
+	}
+	return plan
+}
+
+func sameSyncItem(a, b mongodb.SyncItem) bool {
+	if a.Url != b.Url || a.Name != b.Name || a.Description != b.Description || len(a.Path) != len(b.Path) {
+		return false
+	}
+	for i := range a.Path {
+		if a.Path[i] != b.Path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply makes a planned change, changes rejected by the checks of the api
+// are reported.
+func (s *syncData) apply(viewer mongodb.Viewer, c syncChange, report *SyncReport) error {
+	var err error
+	switch c.kind {
+	case syncCreate:
+		tags, category := s.tagsOf(c.item.Path)
+		if err = s.createTags(tags, category); err != nil {
+			return err
+		}
+		var id int
+		id, err = AddWeb(viewer, mongodb.WebData{Name: truncate(c.item.Name, maxNameLen), Url: c.item.Url, Description: c.item.Description, Tags: tags})
+		if err == nil {
+			report.Created = append(report.Created, id)
+		}
+	case syncUpdate:
+		d := s.items[c.item.ID]
+		tags, category := s.tagsOf(c.item.Path)
+		if err = s.createTags(tags, category); err != nil {
+			return err
+		}
+		// moving between folders replaces the tags of the folders, the
+		// tags no folder shows are kept
+		old, _ := s.tagsOf(c.basePath)
+		for _, tag := range d.Tags {
+			if !contains(old, tag) && !contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		d.Url, d.Description, d.Tags = c.item.Url, c.item.Description, tags
+		if c.item.Name != "" {
+			d.Name = truncate(c.item.Name, maxNameLen)
+		}
+		if err = UpdateWeb(viewer, d); err == nil {
+			report.Updated = append(report.Updated, d.ID)
+		}
+	case syncDelete:
+		if err = DeleteWeb(viewer, c.item.ID); err == nil {
+			report.Deleted = append(report.Deleted, c.item.ID)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	switch util.Code(err) {
+	case codes.InvalidArgument, codes.AlreadyExists, codes.NotFound, codes.PermissionDenied:
+		report.Rejected = append(report.Rejected, SyncConflict{ID: c.item.ID, Url: c.item.Url, Reason: util.Message(err)})
+		return nil
+	}
+	return err
+}
+
+// createTags creates the missing tags in category.
+func (s *syncData) createTags(tags []string, category primitive.ObjectID) error {
+	for _, name := range tags {
+		if _, ok := s.tagCategory[name]; ok {
+			continue
+		}
+		err := mongodb.AddTag(mongodb.Tag{Name: name, Category: category})
+		if err != nil && !util.HaveErrorCode(err, codes.AlreadyExists) {
+			return err
+		}
+		s.tagCategory[name] = category
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package datasys
+
+import (
+	"reflect"
+	"server/mongodb"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPlanSync(t *testing.T) {
+	dev := primitive.NewObjectID()
+	data := &syncData{
+		items:       map[int]mongodb.WebData{},
+		byURL:       map[string]int{},
+		categories:  map[primitive.ObjectID]string{dev: "Dev"},
+		tagCategory: map[string]primitive.ObjectID{"go": dev, "news": {}},
+	}
+	server := []mongodb.WebData{
+		{ID: 1, Url: "https://go.dev/", Canonical: "https://go.dev", Name: "Go", Tags: []string{"go"}},
+		{ID: 2, Url: "https://example.com/", Canonical: "https://example.com", Name: "Example"},
+		{ID: 3, Url: "https://news.example/", Canonical: "https://news.example", Name: "News", Tags: []string{"news"}},
+		{ID: 4, Url: "https://old.example/", Canonical: "https://old.example", Name: "Old"},
+		{ID: 5, Url: "https://both.example/", Canonical: "https://both.example", Name: "Both, server"},
+	}
+	base := map[int]mongodb.SyncItem{}
+	for _, d := range server {
+		data.items[d.ID] = d
+		data.byURL[d.Canonical] = d.ID
+		base[d.ID] = data.item(d)
+	}
+	if want := []string{"Dev", "go"}; !reflect.DeepEqual(base[1].Path, want) {
+		t.Fatalf("path of a tag with category = %v, want %v", base[1].Path, want)
+	}
+	// changed on the server since the client got it
+	base[5] = mongodb.SyncItem{ID: 5, Url: "https://both.example/", Name: "Both"}
+	base[6] = mongodb.SyncItem{ID: 6, Url: "https://gone.example/", Name: "Gone"}
+	data.items[3] = mongodb.WebData{ID: 3, Url: "https://news.example/", Name: "News, renamed", Tags: []string{"news"}}
+
+	client := []mongodb.SyncItem{
+		base[1], // unchanged
+		{ID: 2, Url: "https://example.com/", Name: "Example", Path: []string{"Dev", "web"}}, // moved
+		// 3 deleted on the client, but renamed on the server
+		// 4 deleted on the client
+		{ID: 5, Url: "https://both.example/", Name: "Both, client"},  // changed on both sides
+		{ID: 6, Url: "https://gone.example/", Name: "Gone, renamed"}, // deleted on the server
+		{ID: 99, Url: "https://new.example/", Name: "New"},           // new
+		{ID: 100, Url: "https://go.dev", Name: "Go again"},           // saved already
+		{Url: "https://new.example", Name: "New twice"},
+	}
+	plan := planSync(base, client, data)
+
+	want := []syncChange{
+		{kind: syncUpdate, item: client[1], basePath: nil},
+		{kind: syncCreate, item: mongodb.SyncItem{Url: "https://new.example/", Name: "New"}},
+		{kind: syncDelete, item: base[4]},
+	}
+	if !reflect.DeepEqual(plan.list, want) {
+		t.Errorf("changes =\n%+v\nwant\n%+v", plan.list, want)
+	}
+	wantConflicts := []SyncConflict{
+		{ID: 5, Url: "https://both.example/", Reason: "changed on the server too"},
+		{ID: 6, Url: "https://gone.example/", Reason: "deleted on the server"},
+		{ID: 3, Url: "https://news.example/", Reason: "changed on the server, deleted on the client"},
+	}
+	if !reflect.DeepEqual(plan.conflicts, wantConflicts) {
+		t.Errorf("conflicts =\n%+v\nwant\n%+v", plan.conflicts, wantConflicts)
+	}
+
+	tags, category := data.tagsOf([]string{"dev", "web", "go"})
+	if !reflect.DeepEqual(tags, []string{"web", "go"}) || category != dev {
+		t.Errorf("tagsOf = %v, %v", tags, category)
+	}
+}
@@ -0,0 +1,200 @@
+package datasys
+
+import (
+	"encoding/json"
+	"server/api"
+	"server/config"
+	"server/mongodb"
+	"server/tagquery"
+	"server/util"
+	"server/validate"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	urlSchemes = &config.Current.Web.URLSchemes
+	createTags = &config.Current.Web.CreateTags
+)
+
+const (
+	maxNameLen        = 300
+	maxURLLen         = 2048
+	maxDescriptionLen = 5000
+	maxTags           = 100
+	maxTagNameLen     = 64
+	maxCategoryLen    = 100
+)
+
+// allowedScheme reads -web.urlSchemes when checking, after flags are parsed.
+func allowedScheme(value any) string {
+	schemes := strings.Split(strings.ToLower(*urlSchemes), ",")
+	for i := range schemes {
+		schemes[i] = strings.TrimSpace(schemes[i])
+	}
+	return validate.URL(schemes...)(value)
+}
+
+type webField = validate.Field[mongodb.WebData]
+
+var (
+	webNameField = webField{Name: "Name", Value: func(d mongodb.WebData) any { return d.Name },
+		Checks: []validate.Check{validate.MaxLen(maxNameLen), validate.Printable()}}
+	webURLField = webField{Name: "Url", Value: func(d mongodb.WebData) any { return d.Url },
+		Checks: []validate.Check{validate.Required(), validate.MaxLen(maxURLLen), validate.Printable(), allowedScheme}}
+	webDescriptionField = webField{Name: "Description", Value: func(d mongodb.WebData) any { return d.Description },
+		Checks: []validate.Check{validate.MaxLen(maxDescriptionLen)}}
+	webTagsField = webField{Name: "Tags", Value: func(d mongodb.WebData) any { return d.Tags },
+		Checks: []validate.Check{validate.MaxItems(maxTags), validate.Unique(),
+			validate.Each(validate.Required(), validate.MaxLen(maxTagNameLen), validate.Printable(), tagName)}}
+	webVisibilityField = webField{Name: "Visibility", Value: func(d mongodb.WebData) any { return d.Visibility },
+		Checks: []validate.Check{validate.OneOf(mongodb.VisibilityPrivate, mongodb.VisibilityTeam, mongodb.VisibilityPublic)}}
+)
+
+// addWebRules leave Name optional, the crawler fills it from the page title.
+var addWebRules = validate.Rules[mongodb.WebData]{
+	webNameField, webURLField, webDescriptionField, webTagsField, webVisibilityField,
+}
+
+var updateWebRules = validate.Rules[mongodb.WebData]{
+	{Name: "Name", Value: webNameField.Value, Checks: append([]validate.Check{validate.Required()}, webNameField.Checks...)},
+	webURLField, webDescriptionField, webTagsField, webVisibilityField,
+}
+
+var tagRules = validate.Rules[mongodb.Tag]{
+	{Name: "Name", Value: func(t mongodb.Tag) any { return t.Name },
+		Checks: []validate.Check{validate.Required(), validate.MaxLen(maxTagNameLen), validate.Printable(), tagName}},
+}
+
+// tagName rejects the operators of tag queries in tag names, clients quote
+// the names of a query instead.
+func tagName(value any) string {
+	if s, ok := value.(string); ok && strings.ContainsAny(s, tagquery.Operators) {
+		return "must not contain any of " + tagquery.Operators
+	}
+	return ""
+}
+
+// tagRequestRules check what the tag rules can not see of the request.
+var tagRequestRules = validate.Rules[api.TagRequest]{
+	{Name: "Category", Value: func(t api.TagRequest) any { return t.Category }, Checks: []validate.Check{objectID}},
+}
+
+// tagPatchRules check a tag update of any front-end.
+var tagPatchRules = validate.Rules[mongodb.TagPatch]{
+	{Name: "Order", Value: func(p mongodb.TagPatch) any {
+		if p.Order == nil {
+			return nil
+		}
+		return *p.Order
+	}, Checks: []validate.Check{validate.Min(0)}},
+}
+
+// tagUpdateRules check what tagPatch can not read of the request.
+var tagUpdateRules = validate.Rules[api.TagUpdate]{
+	{Name: "Category", Value: func(t api.TagUpdate) any {
+		id, err := tagCategory(t)
+		if err != nil {
+			return err
+		}
+		return id
+	}, Checks: []validate.Check{objectID}},
+}
+
+// objectID rejects strings that are not an object id, and the errors of
+// reading one.
+func objectID(value any) string {
+	switch v := value.(type) {
+	case error:
+		return "must be a string or null"
+	case string:
+		if v == "" {
+			return ""
+		}
+		if _, err := primitive.ObjectIDFromHex(v); err != nil {
+			return "is not a category id"
+		}
+	}
+	return ""
+}
+
+// tagCategory returns the category id sent, "" for null or a missing field.
+func tagCategory(t api.TagUpdate) (string, error) {
+	if t.Category == nil {
+		return "", nil
+	}
+	var id *string
+	if err := json.Unmarshal(t.Category, &id); err != nil || id == nil {
+		return "", err
+	}
+	return *id, nil
+}
+
+// tagPatch returns the patch of checked t.
+func tagPatch(t api.TagUpdate) mongodb.TagPatch {
+	patch := mongodb.TagPatch{Order: t.Order}
+	if t.Category != nil {
+		id, _ := tagCategory(t)
+		category, _ := primitive.ObjectIDFromHex(id)
+		patch.Category = &category
+	}
+	return patch
+}
+
+var categoryRules = validate.Rules[mongodb.Category]{
+	{Name: "Name", Value: func(c mongodb.Category) any { return c.Name },
+		Checks: []validate.Check{validate.Required(), validate.MaxLen(maxCategoryLen), validate.Printable()}},
+}
+
+// checkTags checks the tags not in known exist, creating them with
+// -web.createTags.
+func checkTags(tags []string, known []string) error {
+	var unknown []string
+	for _, tag := range tags {
+		if !contains(known, tag) {
+			unknown = append(unknown, tag)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	existing, err := mongodb.GetAllTags(bson.M{"name": bson.M{"$in": unknown}})
+	if err != nil {
+		return err
+	}
+	var fields []util.FieldError
+	for _, tag := range unknown {
+		if containsTag(existing, tag) {
+			continue
+		}
+		if !*createTags {
+			fields = append(fields, util.FieldError{Field: "Tags", Message: "has unknown tag " + tag})
+			continue
+		}
+		if err := mongodb.AddTag(mongodb.Tag{Name: tag}); err != nil && !util.HaveErrorCode(err, codes.AlreadyExists) {
+			return err
+		}
+	}
+	return validate.Error(fields...)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(tags []mongodb.Tag, name string) bool {
+	for _, tag := range tags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"server/backup"
 	"server/datasys"
+	"server/graphqlsys"
 	"server/usersys"
 )
 
@@ -17,15 +19,34 @@ func NewService(router *mux.Router) {
 	router.HandleFunc(pathPerfix+"/login", usersys.HandleLogin).Methods(http.MethodPost)
 	router.HandleFunc(pathPerfix+"/logout", usersys.HandleLogout).Methods(http.MethodPost)
 	router.HandleFunc(pathPerfix+"/auth", usersys.HandleGetAuth).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/token", usersys.HandleCreateToken).Methods(http.MethodPost)
 	router.HandleFunc(pathPerfix+"/user", usersys.HandleGetUsers).Methods(http.MethodGet)
 	router.HandleFunc(pathPerfix+"/user", usersys.HandleAddUser).Methods(http.MethodPost)
 	router.HandleFunc(pathPerfix+"/user/{id}", usersys.HandleRemoveUser).Methods(http.MethodDelete)
+	router.HandleFunc(pathPerfix+"/setup", usersys.HandleGetSetup).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/setup", usersys.HandleSetup).Methods(http.MethodPost)
 
 	// web data
 	router.HandleFunc(pathPerfix+"/web", datasys.HandleAddWeb).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web", datasys.HandleListWeb).Methods(http.MethodGet)
+	// before /web/{tags}, which would match them too
+	router.HandleFunc(pathPerfix+"/web/preview", datasys.HandlePreviewWeb).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/broken", datasys.HandleListBrokenWeb).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/duplicates", datasys.HandleFindDuplicates).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/duplicates/merge", datasys.HandleMergeDuplicates).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web/import", datasys.HandleImportWeb).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web/export", datasys.HandleExportWeb).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/clip", datasys.HandleClipWeb).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web/clip/save", datasys.HandleSaveClip).Methods(http.MethodPost)
 	router.HandleFunc(pathPerfix+"/web/{tags}", datasys.HandleSearchWeb).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/search", datasys.HandleSearch).Methods(http.MethodGet)
 	router.HandleFunc(pathPerfix+"/web/{id}", datasys.HandleDeleteWeb).Methods(http.MethodDelete)
 	router.HandleFunc(pathPerfix+"/web/{id}", datasys.HandlePatchWeb).Methods(http.MethodPatch)
+	router.HandleFunc(pathPerfix+"/web/{id}/fetch", datasys.HandleRefetchWeb).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web/{id}/icon", datasys.HandleWebIcon).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/{id}/snapshots", datasys.HandleTakeSnapshot).Methods(http.MethodPost)
+	router.HandleFunc(pathPerfix+"/web/{id}/snapshots", datasys.HandleGetSnapshots).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/web/{id}/snapshots/{snapshot}", datasys.HandleServeSnapshot).Methods(http.MethodGet)
 
 	//tag data
 	router.HandleFunc(pathPerfix+"/tag", datasys.HandleAddTag).Methods(http.MethodPost)
@@ -36,4 +57,14 @@ func NewService(router *mux.Router) {
 	// category data
 	router.HandleFunc(pathPerfix+"/categories", datasys.HandleGetAllCategories).Methods(http.MethodGet)
 	router.HandleFunc(pathPerfix+"/categories/{id}", datasys.HandleUpdateCategory).Methods(http.MethodPatch)
+
+	// bookmark sync, WebDAV methods are no mux methods
+	router.PathPrefix(datasys.DavPrefix).HandlerFunc(datasys.HandleDav)
+
+	// admin
+	router.HandleFunc(pathPerfix+"/admin/backup", backup.HandleBackup).Methods(http.MethodGet)
+	router.HandleFunc(pathPerfix+"/admin/restore", backup.HandleRestore).Methods(http.MethodPost)
+
+	// graphql
+	router.HandleFunc(pathPerfix+"/graphql", graphqlsys.HandleGraphQL).Methods(http.MethodPost)
 }
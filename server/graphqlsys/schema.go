@@ -0,0 +1,395 @@
+package graphqlsys
+
+import (
+	"server/datasys"
+	"server/mongodb"
+	"server/util"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var pageArgs = graphql.FieldConfigArgument{
+	"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+	"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+}
+
+// withPageArgs adds first and offset to args.
+func withPageArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	for name, arg := range pageArgs {
+		args[name] = arg
+	}
+	return args
+}
+
+// window returns the offset and first arguments, first capped to
+// maxPageSize. ok is false for a negative offset, which selects nothing.
+func window(p graphql.ResolveParams) (offset, first int, ok bool) {
+	first, _ = p.Args["first"].(int)
+	offset, _ = p.Args["offset"].(int)
+	if first <= 0 || first > maxPageSize {
+		first = maxPageSize
+	}
+	return offset, first, offset >= 0
+}
+
+// page cuts the first/offset window out of items.
+func page[T any](p graphql.ResolveParams, items []T) []T {
+	offset, first, ok := window(p)
+	if !ok || offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if len(items) > first {
+		items = items[:first]
+	}
+	return items
+}
+
+// searchWeb reads the first/offset window of the web data having all tags,
+// the database skips and limits so only the window is read.
+func searchWeb(p graphql.ResolveParams, tags []string) ([]mongodb.WebData, error) {
+	offset, first, ok := window(p)
+	if !ok {
+		return []mongodb.WebData{}, nil
+	}
+	return datasys.SearchWeb(viewerFromContext(p.Context), tags, offset, first)
+}
+
+// loggedIn guards a resolver with a login check.
+func loggedIn(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if viewerFromContext(p.Context).Name == "" {
+			return nil, util.Errorf("login required").WithCode(codes.Unauthenticated)
+		}
+		return resolve(p)
+	}
+}
+
+// managerOnly guards a resolver with a manager role check.
+func managerOnly(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+		if viewerFromContext(p.Context).Role < util.RoleManager {
+			return nil, util.Errorf("manager role required").WithCode(codes.PermissionDenied)
+		}
+		return resolve(p)
+	})
+}
+
+var visibilityEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "Visibility",
+	Values: graphql.EnumValueConfigMap{
+		"PRIVATE": &graphql.EnumValueConfig{Value: mongodb.VisibilityPrivate},
+		"TEAM":    &graphql.EnumValueConfig{Value: mongodb.VisibilityTeam},
+		"PUBLIC":  &graphql.EnumValueConfig{Value: mongodb.VisibilityPublic},
+	},
+})
+
+var webDataType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "WebData",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: webField(func(d mongodb.WebData) any { return d.ID })},
+		"name":        &graphql.Field{Type: graphql.String, Resolve: webField(func(d mongodb.WebData) any { return d.Name })},
+		"url":         &graphql.Field{Type: graphql.String, Resolve: webField(func(d mongodb.WebData) any { return d.Url })},
+		"tags":        &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: webField(func(d mongodb.WebData) any { return d.Tags })},
+		"description": &graphql.Field{Type: graphql.String, Resolve: webField(func(d mongodb.WebData) any { return d.Description })},
+		"visibility":  &graphql.Field{Type: visibilityEnum, Resolve: webField(func(d mongodb.WebData) any { return d.Visibility })},
+		// owners are only shown to logged-in users
+		"owner": &graphql.Field{Type: graphql.String, Resolve: loggedIn(webField(func(d mongodb.WebData) any { return d.Owner }))},
+	},
+})
+
+func webField(get func(mongodb.WebData) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(mongodb.WebData)), nil
+	}
+}
+
+var tagType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Tag",
+	Fields: graphql.Fields{
+		"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: tagField(func(t mongodb.Tag) any { return t.Name })},
+		"ref":   &graphql.Field{Type: graphql.Int, Resolve: tagField(func(t mongodb.Tag) any { return t.Ref })},
+		"order": &graphql.Field{Type: graphql.Int, Resolve: tagField(func(t mongodb.Tag) any { return t.Order })},
+		"web": &graphql.Field{
+			Type: graphql.NewList(webDataType),
+			Args: withPageArgs(graphql.FieldConfigArgument{}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tag := p.Source.(mongodb.Tag)
+				return searchWeb(p, []string{tag.Name})
+			},
+		},
+	},
+})
+
+func tagField(get func(mongodb.Tag) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(mongodb.Tag)), nil
+	}
+}
+
+var categoryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Category",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(mongodb.Category).Id.Hex(), nil
+			},
+		},
+		"name": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(mongodb.Category).Name, nil
+			},
+		},
+		"tags": &graphql.Field{
+			Type: graphql.NewList(tagType),
+			Args: withPageArgs(graphql.FieldConfigArgument{}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				category := p.Source.(mongodb.Category)
+				tags, err := mongodb.GetAllTags(bson.M{"category": category.Id})
+				if err != nil {
+					return nil, err
+				}
+				return page(p, tags), nil
+			},
+		},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"role": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"me": &graphql.Field{
+			Type: userType,
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				viewer := viewerFromContext(p.Context)
+				return map[string]any{"name": viewer.Name, "role": int(viewer.Role)}, nil
+			}),
+		},
+		"categories": &graphql.Field{
+			Type: graphql.NewList(categoryType),
+			Args: withPageArgs(graphql.FieldConfigArgument{}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				categories, err := datasys.GetCategories()
+				if err != nil {
+					return nil, err
+				}
+				return page(p, categories), nil
+			},
+		},
+		"category": &graphql.Field{
+			Type: categoryType,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := objectID(p.Args["id"].(string))
+				if err != nil {
+					return nil, err
+				}
+				return mongodb.GetCategoryByID(id)
+			},
+		},
+		"tags": &graphql.Field{
+			Type: graphql.NewList(tagType),
+			Args: withPageArgs(graphql.FieldConfigArgument{
+				"uncategorized": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+			}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				filter := bson.M{}
+				if p.Args["uncategorized"].(bool) {
+					filter = bson.M{"category": bson.M{"$exists": false}}
+				}
+				tags, err := mongodb.GetAllTags(filter)
+				if err != nil {
+					return nil, err
+				}
+				return page(p, tags), nil
+			},
+		},
+		"tag": &graphql.Field{
+			Type: tagType,
+			Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return mongodb.GetTagByName(p.Args["name"].(string))
+			},
+		},
+		"web": &graphql.Field{
+			Type: graphql.NewList(webDataType),
+			Args: withPageArgs(graphql.FieldConfigArgument{
+				"tags": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return searchWeb(p, stringList(p.Args["tags"]))
+			},
+		},
+	},
+})
+
+var webInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "WebDataInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"url":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"tags":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"visibility":  &graphql.InputObjectFieldConfig{Type: visibilityEnum},
+	},
+})
+
+func webFromInput(input map[string]any) mongodb.WebData {
+	data := mongodb.WebData{Tags: stringList(input["tags"])}
+	data.Name, _ = input["name"].(string)
+	data.Url, _ = input["url"].(string)
+	data.Description, _ = input["description"].(string)
+	data.Visibility, _ = input["visibility"].(string)
+	return data
+}
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"addWeb": &graphql.Field{
+			Type: graphql.Int,
+			Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(webInputType)}},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				return datasys.AddWeb(viewerFromContext(p.Context), webFromInput(p.Args["input"].(map[string]any)))
+			}),
+		},
+		"updateWeb": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(webInputType)},
+			},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				data := webFromInput(p.Args["input"].(map[string]any))
+				data.ID = p.Args["id"].(int)
+				err := datasys.UpdateWeb(viewerFromContext(p.Context), data)
+				return err == nil, err
+			}),
+		},
+		"deleteWeb": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				err := datasys.DeleteWeb(viewerFromContext(p.Context), p.Args["id"].(int))
+				return err == nil, err
+			}),
+		},
+		"addTag": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				err := datasys.AddTag(viewerFromContext(p.Context), mongodb.Tag{Name: p.Args["name"].(string)})
+				return err == nil, err
+			}),
+		},
+		"updateTag": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"name":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"order":    &graphql.ArgumentConfig{Type: graphql.Int},
+				"category": &graphql.ArgumentConfig{Type: graphql.ID},
+			},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				// only the arguments given change, category "" moves the
+				// tag out of its category
+				patch := mongodb.TagPatch{}
+				if order, ok := p.Args["order"].(int); ok {
+					patch.Order = &order
+				}
+				if category, ok := p.Args["category"].(string); ok {
+					id := primitive.NilObjectID
+					if category != "" {
+						var err error
+						if id, err = objectID(category); err != nil {
+							return nil, err
+						}
+					}
+					patch.Category = &id
+				}
+				err := datasys.UpdateTag(viewerFromContext(p.Context), p.Args["name"].(string), patch)
+				return err == nil, err
+			}),
+		},
+		"deleteTag": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: managerOnly(func(p graphql.ResolveParams) (interface{}, error) {
+				err := datasys.DeleteTag(viewerFromContext(p.Context), p.Args["name"].(string))
+				return err == nil, err
+			}),
+		},
+		"updateCategory": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: loggedIn(func(p graphql.ResolveParams) (interface{}, error) {
+				err := datasys.UpdateCategory(viewerFromContext(p.Context), p.Args["id"].(string), mongodb.Category{Name: p.Args["name"].(string)})
+				return err == nil, err
+			}),
+		},
+	},
+})
+
+var schema graphql.Schema
+
+func init() {
+	// added here since categoryType refers to tagType
+	tagType.AddFieldConfig("category", &graphql.Field{
+		Type: categoryType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			tag := p.Source.(mongodb.Tag)
+			if tag.Category.IsZero() {
+				return nil, nil
+			}
+			return mongodb.GetCategoryByID(tag.Category)
+		},
+	})
+
+	var err error
+	schema, err = graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+	if err != nil {
+		panic(util.Errorf("build graphql schema failed").WithCause(err))
+	}
+}
+
+func objectID(hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return id, util.Errorf("invalid id %s", hex).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	return id, nil
+}
+
+func stringList(v any) []string {
+	list, _ := v.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, s := range list {
+		if str, ok := s.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
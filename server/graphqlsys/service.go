@@ -0,0 +1,318 @@
+package graphqlsys
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/mongodb"
+	"server/util"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"google.golang.org/grpc/codes"
+)
+
+var maxDepth = &config.Current.Graphql.MaxDepth
+var maxComplexity = &config.Current.Graphql.MaxComplexity
+
+type viewerKey struct{}
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+func HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request graphqlRequest
+	if err := util.DecodeBody(w, r, &request); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := checkLimits(request); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	user, role, err := util.LookupUser(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+	viewer := mongodb.Viewer{Name: user, Role: role}
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        context.WithValue(r.Context(), viewerKey{}, viewer),
+	})
+	for i, e := range result.Errors {
+		result.Errors[i] = formatError(e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(result))
+}
+
+func viewerFromContext(ctx context.Context) mongodb.Viewer {
+	viewer, _ := ctx.Value(viewerKey{}).(mongodb.Viewer)
+	return viewer
+}
+
+// formatError hides util.Error causes from clients, like util.WriteError.
+func formatError(e gqlerrors.FormattedError) gqlerrors.FormattedError {
+	located, ok := e.OriginalError().(*gqlerrors.Error)
+	if !ok || located.OriginalError == nil {
+		return e
+	}
+	err, ok := located.OriginalError.(*util.Error)
+	if !ok {
+		return e
+	}
+	code := util.Code(err)
+	e.Message = util.Message(err)
+	if util.HTTPStatus(code) >= http.StatusInternalServerError {
+		util.Errorf("graphql resolve failed").WithCause(err).Log()
+		e.Message = code.String()
+	}
+	e.Extensions = map[string]interface{}{"code": code.String()}
+	return e
+}
+
+// checkLimits rejects queries nested deeper than maxDepth or estimated to
+// resolve more than maxComplexity fields. List fields multiply the cost of
+// their selection by their page size. __schema and __type selections are
+// checked against the introspection limits.
+func checkLimits(request graphqlRequest) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(request.Query)})})
+	if err != nil {
+		// graphql.Do reports syntax errors
+		return nil
+	}
+	l := limiter{fragments: map[string]*ast.FragmentDefinition{}, vars: request.Variables}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			l.fragments[f.Name.Value] = f
+		}
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		root := schema.QueryType()
+		if op.Operation == ast.OperationTypeMutation {
+			root = schema.MutationType()
+		}
+		data, introspection := splitIntrospection(op.SelectionSet)
+		depth, cost := l.measure(data, root, 1, map[string]bool{})
+		if err := checkLimit("query", depth, cost, *maxDepth, *maxComplexity); err != nil {
+			return err
+		}
+		depth, cost = l.measure(introspection, root, 1, map[string]bool{})
+		if err := checkLimit("introspection", depth, cost, maxIntrospectionDepth, maxIntrospectionComplexity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The introspection query of graphql tools nests deeper than data queries
+// need to, so introspection has limits of its own. Its lists are bounded by
+// the schema, nesting them is what these limits stop.
+const (
+	maxIntrospectionDepth      = 16
+	maxIntrospectionComplexity = 100000
+)
+
+// splitIntrospection splits the root selections into data and
+// introspection ones.
+func splitIntrospection(set *ast.SelectionSet) (data, introspection *ast.SelectionSet) {
+	data, introspection = &ast.SelectionSet{}, &ast.SelectionSet{}
+	for _, selection := range set.Selections {
+		if f, ok := selection.(*ast.Field); ok && (f.Name.Value == "__schema" || f.Name.Value == "__type") {
+			introspection.Selections = append(introspection.Selections, selection)
+		} else {
+			data.Selections = append(data.Selections, selection)
+		}
+	}
+	return data, introspection
+}
+
+func checkLimit(what string, depth, cost, maxDepth, maxCost int) error {
+	if depth > maxDepth {
+		return util.Errorf("%s depth %d exceeds limit %d", what, depth, maxDepth).WithCode(codes.InvalidArgument)
+	}
+	if cost > maxCost {
+		return util.Errorf("%s complexity %d exceeds limit %d", what, cost, maxCost).WithCode(codes.InvalidArgument)
+	}
+	return nil
+}
+
+type limiter struct {
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]any
+}
+
+func (l limiter) measure(set *ast.SelectionSet, parent *graphql.Object, depth int, spreading map[string]bool) (maxDepth, cost int) {
+	maxDepth = depth
+	if set == nil || parent == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			d, c = l.measureField(s, parent, depth, spreading)
+		case *ast.InlineFragment:
+			d, c = l.measure(s.SelectionSet, l.condition(s.TypeCondition, parent), depth, spreading)
+		case *ast.FragmentSpread:
+			f, ok := l.fragments[s.Name.Value]
+			if !ok || spreading[s.Name.Value] {
+				continue
+			}
+			spreading[s.Name.Value] = true
+			d, c = l.measure(f.SelectionSet, l.condition(f.TypeCondition, parent), depth, spreading)
+			delete(spreading, s.Name.Value)
+		}
+		if d > maxDepth {
+			maxDepth = d
+		}
+		cost += c
+	}
+	return
+}
+
+func (l limiter) measureField(field *ast.Field, parent *graphql.Object, depth int, spreading map[string]bool) (int, int) {
+	def, ok := fieldDefinition(parent, field.Name.Value)
+	if !ok {
+		// unknown fields are left to graphql.Do
+		return depth, 0
+	}
+	if field.SelectionSet == nil {
+		return depth, 1
+	}
+	fieldType := def.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	multiplier := 1
+	if list, ok := fieldType.(*graphql.List); ok {
+		fieldType = list.OfType
+		multiplier = l.pageSize(field)
+		if strings.HasPrefix(parent.Name(), "__") {
+			// introspection lists are not paged, the schema bounds them
+			multiplier = introspectionListSize(field.Name.Value)
+		}
+	}
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	object, _ := fieldType.(*graphql.Object)
+	d, c := l.measure(field.SelectionSet, object, depth+1, spreading)
+	return d, 1 + multiplier*c
+}
+
+// fieldDefinition finds field name of parent, the introspection fields
+// included.
+func fieldDefinition(parent *graphql.Object, name string) (*graphql.FieldDefinition, bool) {
+	if def, ok := parent.Fields()[name]; ok {
+		return def, true
+	}
+	switch name {
+	case "__schema":
+		return graphql.SchemaMetaFieldDef, true
+	case "__type":
+		return graphql.TypeMetaFieldDef, true
+	case "__typename":
+		return graphql.TypeNameMetaFieldDef, true
+	}
+	return nil, false
+}
+
+var listSizes struct {
+	sync.Once
+	sizes map[string]int
+}
+
+// introspectionListSize is the longest list introspection field name
+// returns in the schema.
+func introspectionListSize(name string) int {
+	listSizes.Do(func() {
+		sizes := map[string]int{
+			"types":         len(schema.TypeMap()),
+			"possibleTypes": len(schema.TypeMap()),
+			"directives":    len(schema.Directives()),
+		}
+		grow := func(name string, size int) {
+			if size > sizes[name] {
+				sizes[name] = size
+			}
+		}
+		for _, d := range schema.Directives() {
+			grow("args", len(d.Args))
+		}
+		for _, t := range schema.TypeMap() {
+			switch t := t.(type) {
+			case *graphql.Object:
+				grow("fields", len(t.Fields()))
+				grow("interfaces", len(t.Interfaces()))
+				for _, f := range t.Fields() {
+					grow("args", len(f.Args))
+				}
+			case *graphql.Interface:
+				grow("fields", len(t.Fields()))
+			case *graphql.InputObject:
+				grow("inputFields", len(t.Fields()))
+			case *graphql.Enum:
+				grow("enumValues", len(t.Values()))
+			}
+		}
+		listSizes.sizes = sizes
+	})
+	return listSizes.sizes[name]
+}
+
+// pageSize is the first argument of a list field.
+func (l limiter) pageSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 && n <= maxPageSize {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := l.vars[v.Name.Value].(float64); ok && n > 0 && n <= maxPageSize {
+				return int(n)
+			}
+		}
+		return maxPageSize
+	}
+	return defaultPageSize
+}
+
+func (l limiter) condition(named *ast.Named, parent *graphql.Object) *graphql.Object {
+	if named == nil {
+		return parent
+	}
+	object, _ := schema.Type(named.Name.Value).(*graphql.Object)
+	return object
+}
@@ -0,0 +1,165 @@
+package graphqlsys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"server/config"
+	"server/mongodb"
+	"server/util"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCheckLimits(t *testing.T) {
+	nested := "{ __type(name: \"Query\") { " + strings.Repeat("fields { type { ", 6) + "name" + strings.Repeat(" } }", 6) + " } }"
+	for _, c := range []struct {
+		query string
+		ok    bool
+	}{
+		{`{ me { name } }`, true},
+		{`{ categories { tags { name } } }`, true},
+		{`{ categories(first: 100) { tags(first: 100) { name } } }`, false},
+		{`query($n: Int) { categories(first: $n) { tags(first: $n) { name } } }`, false},
+		{`{ tags { web { name } } }`, true},
+		{`{ tags(first: 100) { web(first: 100) { name } } }`, false},
+		{`{ ...a } fragment a on Query { tags { ...b } } fragment b on Tag { web { ...a } }`, true},
+		{testutil.IntrospectionQuery, true},
+		{`{ __schema { types { name } } }`, true},
+		{nested, false},
+		{`{ broken`, true},
+	} {
+		err := checkLimits(graphqlRequest{Query: c.query, Variables: map[string]any{"n": float64(100)}})
+		if (err == nil) != c.ok {
+			t.Errorf("checkLimits(%s) = %v, want ok %v", c.query, err, c.ok)
+		}
+	}
+
+	old := *maxDepth
+	*maxDepth = 2
+	defer func() { *maxDepth = old }()
+	if err := checkLimits(graphqlRequest{Query: `{ categories { tags { name } } }`}); err == nil {
+		t.Error("checkLimits accepted a query of depth 3")
+	}
+}
+
+func TestPage(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4}
+	for _, c := range []struct {
+		first, offset int
+		want          []int
+	}{
+		{2, 0, []int{0, 1}},
+		{2, 4, []int{4}},
+		{10, 1, []int{1, 2, 3, 4}},
+		{0, 3, []int{3, 4}},
+		{2, 5, []int{}},
+		{2, -1, []int{}},
+	} {
+		got := page(graphql.ResolveParams{Args: map[string]any{"first": c.first, "offset": c.offset}}, items)
+		if len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) {
+			t.Errorf("page(first %d, offset %d) = %v, want %v", c.first, c.offset, got, c.want)
+		}
+	}
+}
+
+func run(viewer mongodb.Viewer, query string) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: query,
+		Context:       context.WithValue(context.Background(), viewerKey{}, viewer),
+	})
+}
+
+func TestAuthorization(t *testing.T) {
+	player := mongodb.Viewer{Name: "bob", Role: util.RolePlayer}
+	for _, c := range []struct {
+		viewer mongodb.Viewer
+		query  string
+		code   string
+	}{
+		{mongodb.Viewer{}, `{ me { name } }`, "Unauthenticated"},
+		{mongodb.Viewer{}, `mutation { addTag(name: "go") }`, "Unauthenticated"},
+		{mongodb.Viewer{}, `mutation { deleteTag(name: "go") }`, "Unauthenticated"},
+		{player, `mutation { deleteTag(name: "go") }`, "PermissionDenied"},
+	} {
+		result := run(c.viewer, c.query)
+		if len(result.Errors) != 1 {
+			t.Errorf("%s as %q: errors %v", c.query, c.viewer.Name, result.Errors)
+			continue
+		}
+		if code := formatError(result.Errors[0]).Extensions["code"]; code != c.code {
+			t.Errorf("%s as %q: code %v, want %s", c.query, c.viewer.Name, code, c.code)
+		}
+	}
+
+	result := run(player, `{ me { name role } }`)
+	if len(result.Errors) > 0 || !strings.Contains(util.EncodeJson(result.Data), `"bob"`) {
+		t.Errorf("me as bob = %+v", result)
+	}
+
+	// owners are hidden from anonymous viewers
+	owner := webDataType.Fields()["owner"].Resolve
+	data := mongodb.WebData{Owner: "alice"}
+	anonymous := context.WithValue(context.Background(), viewerKey{}, mongodb.Viewer{})
+	if _, err := owner(graphql.ResolveParams{Source: data, Context: anonymous}); err == nil {
+		t.Error("owner resolved for an anonymous viewer")
+	}
+	loggedIn := context.WithValue(context.Background(), viewerKey{}, player)
+	if got, err := owner(graphql.ResolveParams{Source: data, Context: loggedIn}); err != nil || got != "alice" {
+		t.Errorf("owner = %v, %v", got, err)
+	}
+}
+
+func TestHandleGraphQLBody(t *testing.T) {
+	for _, c := range []struct{ body, want string }{
+		{`{"query":"{ me { name } }","extra":1}`, "unknown field"},
+		{`{"query":"{ me { name ` + strings.Repeat(" name", int(config.Current.HTTP.MaxBodySize)/5) + ` } }"}`, "larger than"},
+	} {
+		w := httptest.NewRecorder()
+		HandleGraphQL(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(c.body)))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), c.want) {
+			t.Errorf("body %.40s: status %d, body %.200s, want %q", c.body, w.Code, w.Body, c.want)
+		}
+	}
+}
+
+// TestWebWindow checks web reads only the requested window from the database.
+func TestWebWindow(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("web", func(mt *mtest.T) {
+		old := mongodb.WebDatadb
+		defer func() { mongodb.WebDatadb = old }()
+		mongodb.WebDatadb = mt.Coll
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{Key: "_id", Value: 31}}))
+		result := run(mongodb.Viewer{}, `{ web(tags: ["go"], first: 500, offset: 30) { id } }`)
+		if len(result.Errors) > 0 {
+			t.Fatal(result.Errors)
+		}
+		events := mt.GetAllStartedEvents()
+		if len(events) != 1 {
+			t.Fatalf("%d commands sent, want 1", len(events))
+		}
+		command := events[0].Command
+		if skip := command.Lookup("skip").AsInt64(); skip != 30 {
+			t.Errorf("skip %d, want 30", skip)
+		}
+		if limit := command.Lookup("limit").AsInt64(); limit != maxPageSize {
+			t.Errorf("limit %d, want %d", limit, maxPageSize)
+		}
+
+		// a negative offset selects nothing without reading
+		result = run(mongodb.Viewer{}, `{ web(tags: ["go"], offset: -1) { id } }`)
+		if len(result.Errors) > 0 || len(mt.GetAllStartedEvents()) != 1 {
+			t.Errorf("negative offset = %+v, read the database", result)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package grpcsys
+
+import (
+	"context"
+	"server/datasys"
+	"server/grpcsys/pb"
+	"server/mongodb"
+	"server/usersys"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+type authService struct {
+	pb.UnimplementedAuthServiceServer
+}
+
+func (authService) Register(ctx context.Context, req *pb.AuthRequest) (*pb.AuthResponse, error) {
+	if err := usersys.Register(req.Username, req.Password); err != nil {
+		return nil, err
+	}
+	return authService{}.Login(ctx, req)
+}
+
+func (authService) Login(ctx context.Context, req *pb.AuthRequest) (*pb.AuthResponse, error) {
+	user, err := usersys.Login(req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthResponse{
+		Token: util.NewToken(user.Name, user.Role),
+		User:  &pb.User{Name: user.Name, Role: pb.Role(user.Role)},
+	}, nil
+}
+
+func (authService) GetAuth(ctx context.Context, req *pb.GetAuthRequest) (*pb.User, error) {
+	viewer := viewerFromContext(ctx)
+	if viewer.Name == "" {
+		return nil, util.Errorf("login required").WithCode(codes.Unauthenticated)
+	}
+	return &pb.User{Name: viewer.Name, Role: pb.Role(viewer.Role)}, nil
+}
+
+type webService struct {
+	pb.UnimplementedWebServiceServer
+}
+
+func (webService) AddWeb(ctx context.Context, req *pb.AddWebRequest) (*pb.AddWebResponse, error) {
+	id, err := datasys.AddWeb(viewerFromContext(ctx), fromPbWeb(req.Web))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddWebResponse{Id: int32(id)}, nil
+}
+
+func (webService) UpdateWeb(ctx context.Context, req *pb.UpdateWebRequest) (*pb.Empty, error) {
+	if err := datasys.UpdateWeb(viewerFromContext(ctx), fromPbWeb(req.Web)); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (webService) DeleteWeb(ctx context.Context, req *pb.DeleteWebRequest) (*pb.Empty, error) {
+	if err := datasys.DeleteWeb(viewerFromContext(ctx), int(req.Id)); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (webService) SearchWeb(req *pb.SearchWebRequest, stream pb.WebService_SearchWebServer) error {
+	ctx := stream.Context()
+	return datasys.EachSearchWeb(ctx, viewerFromContext(ctx), req.Tags, func(data mongodb.WebData) error {
+		return stream.Send(toPbWeb(data))
+	})
+}
+
+type tagService struct {
+	pb.UnimplementedTagServiceServer
+}
+
+func (tagService) AddTag(ctx context.Context, req *pb.Tag) (*pb.Empty, error) {
+	tag, err := fromPbTag(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := datasys.AddTag(viewerFromContext(ctx), tag); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+// UpdateTag sets the order and category of the tag, proto3 cannot tell an
+// unset field so both are written. Ref is ignored.
+func (tagService) UpdateTag(ctx context.Context, req *pb.Tag) (*pb.Empty, error) {
+	tag, err := fromPbTag(req)
+	if err != nil {
+		return nil, err
+	}
+	patch := mongodb.TagPatch{Order: &tag.Order, Category: &tag.Category}
+	if err := datasys.UpdateTag(viewerFromContext(ctx), req.Name, patch); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (tagService) DeleteTag(ctx context.Context, req *pb.DeleteTagRequest) (*pb.Empty, error) {
+	if err := datasys.DeleteTag(viewerFromContext(ctx), req.Name); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (tagService) ListTags(req *pb.ListTagsRequest, stream pb.TagService_ListTagsServer) error {
+	tags, err := datasys.GetTags()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := stream.Send(toPbTag(tag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type categoryService struct {
+	pb.UnimplementedCategoryServiceServer
+}
+
+func (categoryService) ListCategories(req *pb.ListCategoriesRequest, stream pb.CategoryService_ListCategoriesServer) error {
+	categories, err := datasys.GetCategories()
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		if err := stream.Send(toPbCategory(category)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (categoryService) UpdateCategory(ctx context.Context, req *pb.Category) (*pb.Empty, error) {
+	if err := datasys.UpdateCategory(viewerFromContext(ctx), req.Id, mongodb.Category{Name: req.Name}); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+var visibilities = map[pb.Visibility]string{
+	pb.Visibility_VISIBILITY_UNSPECIFIED: "",
+	pb.Visibility_VISIBILITY_PRIVATE:     mongodb.VisibilityPrivate,
+	pb.Visibility_VISIBILITY_TEAM:        mongodb.VisibilityTeam,
+	pb.Visibility_VISIBILITY_PUBLIC:      mongodb.VisibilityPublic,
+}
+
+func toPbWeb(data mongodb.WebData) *pb.WebData {
+	web := &pb.WebData{
+		Id:          int32(data.ID),
+		Name:        data.Name,
+		Url:         data.Url,
+		Tags:        data.Tags,
+		Description: data.Description,
+		Owner:       data.Owner,
+	}
+	for v, name := range visibilities {
+		if name == data.Visibility {
+			web.Visibility = v
+		}
+	}
+	return web
+}
+
+func fromPbWeb(web *pb.WebData) mongodb.WebData {
+	return mongodb.WebData{
+		ID:          int(web.GetId()),
+		Name:        web.GetName(),
+		Url:         web.GetUrl(),
+		Tags:        web.GetTags(),
+		Description: web.GetDescription(),
+		Visibility:  visibilities[web.GetVisibility()],
+	}
+}
+
+func toPbTag(tag mongodb.Tag) *pb.Tag {
+	t := &pb.Tag{Name: tag.Name, Ref: int32(tag.Ref), Order: int32(tag.Order)}
+	if !tag.Category.IsZero() {
+		t.Category = tag.Category.Hex()
+	}
+	return t
+}
+
+func fromPbTag(t *pb.Tag) (mongodb.Tag, error) {
+	tag := mongodb.Tag{Name: t.Name, Order: int(t.Order)}
+	if t.Category != "" {
+		id, err := primitive.ObjectIDFromHex(t.Category)
+		if err != nil {
+			return tag, util.Errorf("invalid category %s", t.Category).WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		tag.Category = id
+	}
+	return tag, nil
+}
+
+func toPbCategory(category mongodb.Category) *pb.Category {
+	c := &pb.Category{Id: category.Id.Hex(), Name: category.Name}
+	for _, tag := range category.Tags {
+		c.Tags = append(c.Tags, toPbTag(tag))
+	}
+	return c
+}
@@ -0,0 +1,804 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: webstorage.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AuthService_Register_FullMethodName = "/webstorage.v1.AuthService/Register"
+	AuthService_Login_FullMethodName    = "/webstorage.v1.AuthService/Login"
+	AuthService_GetAuth_FullMethodName  = "/webstorage.v1.AuthService/GetAuth"
+)
+
+// AuthServiceClient is the client API for AuthService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthServiceClient interface {
+	Register(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	Login(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	GetAuth(ctx context.Context, in *GetAuthRequest, opts ...grpc.CallOption) (*User, error)
+}
+
+type authServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthServiceClient(cc grpc.ClientConnInterface) AuthServiceClient {
+	return &authServiceClient{cc}
+}
+
+func (c *authServiceClient) Register(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, AuthService_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) Login(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, AuthService_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) GetAuth(ctx context.Context, in *GetAuthRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, AuthService_GetAuth_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthServiceServer is the server API for AuthService service.
+// All implementations must embed UnimplementedAuthServiceServer
+// for forward compatibility
+type AuthServiceServer interface {
+	Register(context.Context, *AuthRequest) (*AuthResponse, error)
+	Login(context.Context, *AuthRequest) (*AuthResponse, error)
+	GetAuth(context.Context, *GetAuthRequest) (*User, error)
+	mustEmbedUnimplementedAuthServiceServer()
+}
+
+// UnimplementedAuthServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthServiceServer struct {
+}
+
+func (UnimplementedAuthServiceServer) Register(context.Context, *AuthRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedAuthServiceServer) Login(context.Context, *AuthRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedAuthServiceServer) GetAuth(context.Context, *GetAuthRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAuth not implemented")
+}
+func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
+
+// UnsafeAuthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthServiceServer will
+// result in compilation errors.
+type UnsafeAuthServiceServer interface {
+	mustEmbedUnimplementedAuthServiceServer()
+}
+
+func RegisterAuthServiceServer(s grpc.ServiceRegistrar, srv AuthServiceServer) {
+	s.RegisterService(&AuthService_ServiceDesc, srv)
+}
+
+func _AuthService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).Register(ctx, req.(*AuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).Login(ctx, req.(*AuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_GetAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).GetAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_GetAuth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).GetAuth(ctx, req.(*GetAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuthService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webstorage.v1.AuthService",
+	HandlerType: (*AuthServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _AuthService_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _AuthService_Login_Handler,
+		},
+		{
+			MethodName: "GetAuth",
+			Handler:    _AuthService_GetAuth_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "webstorage.proto",
+}
+
+const (
+	WebService_AddWeb_FullMethodName    = "/webstorage.v1.WebService/AddWeb"
+	WebService_UpdateWeb_FullMethodName = "/webstorage.v1.WebService/UpdateWeb"
+	WebService_DeleteWeb_FullMethodName = "/webstorage.v1.WebService/DeleteWeb"
+	WebService_SearchWeb_FullMethodName = "/webstorage.v1.WebService/SearchWeb"
+)
+
+// WebServiceClient is the client API for WebService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WebServiceClient interface {
+	AddWeb(ctx context.Context, in *AddWebRequest, opts ...grpc.CallOption) (*AddWebResponse, error)
+	UpdateWeb(ctx context.Context, in *UpdateWebRequest, opts ...grpc.CallOption) (*Empty, error)
+	DeleteWeb(ctx context.Context, in *DeleteWebRequest, opts ...grpc.CallOption) (*Empty, error)
+	// SearchWeb streams the web data having all tags.
+	SearchWeb(ctx context.Context, in *SearchWebRequest, opts ...grpc.CallOption) (WebService_SearchWebClient, error)
+}
+
+type webServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWebServiceClient(cc grpc.ClientConnInterface) WebServiceClient {
+	return &webServiceClient{cc}
+}
+
+func (c *webServiceClient) AddWeb(ctx context.Context, in *AddWebRequest, opts ...grpc.CallOption) (*AddWebResponse, error) {
+	out := new(AddWebResponse)
+	err := c.cc.Invoke(ctx, WebService_AddWeb_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webServiceClient) UpdateWeb(ctx context.Context, in *UpdateWebRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, WebService_UpdateWeb_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webServiceClient) DeleteWeb(ctx context.Context, in *DeleteWebRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, WebService_DeleteWeb_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webServiceClient) SearchWeb(ctx context.Context, in *SearchWebRequest, opts ...grpc.CallOption) (WebService_SearchWebClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WebService_ServiceDesc.Streams[0], WebService_SearchWeb_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &webServiceSearchWebClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WebService_SearchWebClient interface {
+	Recv() (*WebData, error)
+	grpc.ClientStream
+}
+
+type webServiceSearchWebClient struct {
+	grpc.ClientStream
+}
+
+func (x *webServiceSearchWebClient) Recv() (*WebData, error) {
+	m := new(WebData)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WebServiceServer is the server API for WebService service.
+// All implementations must embed UnimplementedWebServiceServer
+// for forward compatibility
+type WebServiceServer interface {
+	AddWeb(context.Context, *AddWebRequest) (*AddWebResponse, error)
+	UpdateWeb(context.Context, *UpdateWebRequest) (*Empty, error)
+	DeleteWeb(context.Context, *DeleteWebRequest) (*Empty, error)
+	// SearchWeb streams the web data having all tags.
+	SearchWeb(*SearchWebRequest, WebService_SearchWebServer) error
+	mustEmbedUnimplementedWebServiceServer()
+}
+
+// UnimplementedWebServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWebServiceServer struct {
+}
+
+func (UnimplementedWebServiceServer) AddWeb(context.Context, *AddWebRequest) (*AddWebResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddWeb not implemented")
+}
+func (UnimplementedWebServiceServer) UpdateWeb(context.Context, *UpdateWebRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateWeb not implemented")
+}
+func (UnimplementedWebServiceServer) DeleteWeb(context.Context, *DeleteWebRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWeb not implemented")
+}
+func (UnimplementedWebServiceServer) SearchWeb(*SearchWebRequest, WebService_SearchWebServer) error {
+	return status.Errorf(codes.Unimplemented, "method SearchWeb not implemented")
+}
+func (UnimplementedWebServiceServer) mustEmbedUnimplementedWebServiceServer() {}
+
+// UnsafeWebServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WebServiceServer will
+// result in compilation errors.
+type UnsafeWebServiceServer interface {
+	mustEmbedUnimplementedWebServiceServer()
+}
+
+func RegisterWebServiceServer(s grpc.ServiceRegistrar, srv WebServiceServer) {
+	s.RegisterService(&WebService_ServiceDesc, srv)
+}
+
+func _WebService_AddWeb_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddWebRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebServiceServer).AddWeb(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebService_AddWeb_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebServiceServer).AddWeb(ctx, req.(*AddWebRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebService_UpdateWeb_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateWebRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebServiceServer).UpdateWeb(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebService_UpdateWeb_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebServiceServer).UpdateWeb(ctx, req.(*UpdateWebRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebService_DeleteWeb_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebServiceServer).DeleteWeb(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebService_DeleteWeb_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebServiceServer).DeleteWeb(ctx, req.(*DeleteWebRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebService_SearchWeb_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchWebRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WebServiceServer).SearchWeb(m, &webServiceSearchWebServer{stream})
+}
+
+type WebService_SearchWebServer interface {
+	Send(*WebData) error
+	grpc.ServerStream
+}
+
+type webServiceSearchWebServer struct {
+	grpc.ServerStream
+}
+
+func (x *webServiceSearchWebServer) Send(m *WebData) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WebService_ServiceDesc is the grpc.ServiceDesc for WebService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WebService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webstorage.v1.WebService",
+	HandlerType: (*WebServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddWeb",
+			Handler:    _WebService_AddWeb_Handler,
+		},
+		{
+			MethodName: "UpdateWeb",
+			Handler:    _WebService_UpdateWeb_Handler,
+		},
+		{
+			MethodName: "DeleteWeb",
+			Handler:    _WebService_DeleteWeb_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SearchWeb",
+			Handler:       _WebService_SearchWeb_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "webstorage.proto",
+}
+
+const (
+	TagService_AddTag_FullMethodName    = "/webstorage.v1.TagService/AddTag"
+	TagService_UpdateTag_FullMethodName = "/webstorage.v1.TagService/UpdateTag"
+	TagService_DeleteTag_FullMethodName = "/webstorage.v1.TagService/DeleteTag"
+	TagService_ListTags_FullMethodName  = "/webstorage.v1.TagService/ListTags"
+)
+
+// TagServiceClient is the client API for TagService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TagServiceClient interface {
+	AddTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*Empty, error)
+	// UpdateTag sets the order and category of the tag, ref is ignored.
+	UpdateTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*Empty, error)
+	DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*Empty, error)
+	// ListTags streams the tags without category, ordered by order.
+	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (TagService_ListTagsClient, error)
+}
+
+type tagServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTagServiceClient(cc grpc.ClientConnInterface) TagServiceClient {
+	return &tagServiceClient{cc}
+}
+
+func (c *tagServiceClient) AddTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, TagService_AddTag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tagServiceClient) UpdateTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, TagService_UpdateTag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tagServiceClient) DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, TagService_DeleteTag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tagServiceClient) ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (TagService_ListTagsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TagService_ServiceDesc.Streams[0], TagService_ListTags_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tagServiceListTagsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TagService_ListTagsClient interface {
+	Recv() (*Tag, error)
+	grpc.ClientStream
+}
+
+type tagServiceListTagsClient struct {
+	grpc.ClientStream
+}
+
+func (x *tagServiceListTagsClient) Recv() (*Tag, error) {
+	m := new(Tag)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TagServiceServer is the server API for TagService service.
+// All implementations must embed UnimplementedTagServiceServer
+// for forward compatibility
+type TagServiceServer interface {
+	AddTag(context.Context, *Tag) (*Empty, error)
+	// UpdateTag sets the order and category of the tag, ref is ignored.
+	UpdateTag(context.Context, *Tag) (*Empty, error)
+	DeleteTag(context.Context, *DeleteTagRequest) (*Empty, error)
+	// ListTags streams the tags without category, ordered by order.
+	ListTags(*ListTagsRequest, TagService_ListTagsServer) error
+	mustEmbedUnimplementedTagServiceServer()
+}
+
+// UnimplementedTagServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTagServiceServer struct {
+}
+
+func (UnimplementedTagServiceServer) AddTag(context.Context, *Tag) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddTag not implemented")
+}
+func (UnimplementedTagServiceServer) UpdateTag(context.Context, *Tag) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateTag not implemented")
+}
+func (UnimplementedTagServiceServer) DeleteTag(context.Context, *DeleteTagRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTag not implemented")
+}
+func (UnimplementedTagServiceServer) ListTags(*ListTagsRequest, TagService_ListTagsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedTagServiceServer) mustEmbedUnimplementedTagServiceServer() {}
+
+// UnsafeTagServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TagServiceServer will
+// result in compilation errors.
+type UnsafeTagServiceServer interface {
+	mustEmbedUnimplementedTagServiceServer()
+}
+
+func RegisterTagServiceServer(s grpc.ServiceRegistrar, srv TagServiceServer) {
+	s.RegisterService(&TagService_ServiceDesc, srv)
+}
+
+func _TagService_AddTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Tag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TagServiceServer).AddTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TagService_AddTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TagServiceServer).AddTag(ctx, req.(*Tag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TagService_UpdateTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Tag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TagServiceServer).UpdateTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TagService_UpdateTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TagServiceServer).UpdateTag(ctx, req.(*Tag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TagService_DeleteTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TagServiceServer).DeleteTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TagService_DeleteTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TagServiceServer).DeleteTag(ctx, req.(*DeleteTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TagService_ListTags_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTagsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TagServiceServer).ListTags(m, &tagServiceListTagsServer{stream})
+}
+
+type TagService_ListTagsServer interface {
+	Send(*Tag) error
+	grpc.ServerStream
+}
+
+type tagServiceListTagsServer struct {
+	grpc.ServerStream
+}
+
+func (x *tagServiceListTagsServer) Send(m *Tag) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TagService_ServiceDesc is the grpc.ServiceDesc for TagService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TagService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webstorage.v1.TagService",
+	HandlerType: (*TagServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddTag",
+			Handler:    _TagService_AddTag_Handler,
+		},
+		{
+			MethodName: "UpdateTag",
+			Handler:    _TagService_UpdateTag_Handler,
+		},
+		{
+			MethodName: "DeleteTag",
+			Handler:    _TagService_DeleteTag_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListTags",
+			Handler:       _TagService_ListTags_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "webstorage.proto",
+}
+
+const (
+	CategoryService_ListCategories_FullMethodName = "/webstorage.v1.CategoryService/ListCategories"
+	CategoryService_UpdateCategory_FullMethodName = "/webstorage.v1.CategoryService/UpdateCategory"
+)
+
+// CategoryServiceClient is the client API for CategoryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CategoryServiceClient interface {
+	// ListCategories streams every category with its tags.
+	ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (CategoryService_ListCategoriesClient, error)
+	UpdateCategory(ctx context.Context, in *Category, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type categoryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCategoryServiceClient(cc grpc.ClientConnInterface) CategoryServiceClient {
+	return &categoryServiceClient{cc}
+}
+
+func (c *categoryServiceClient) ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (CategoryService_ListCategoriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CategoryService_ServiceDesc.Streams[0], CategoryService_ListCategories_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &categoryServiceListCategoriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CategoryService_ListCategoriesClient interface {
+	Recv() (*Category, error)
+	grpc.ClientStream
+}
+
+type categoryServiceListCategoriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *categoryServiceListCategoriesClient) Recv() (*Category, error) {
+	m := new(Category)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *categoryServiceClient) UpdateCategory(ctx context.Context, in *Category, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, CategoryService_UpdateCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CategoryServiceServer is the server API for CategoryService service.
+// All implementations must embed UnimplementedCategoryServiceServer
+// for forward compatibility
+type CategoryServiceServer interface {
+	// ListCategories streams every category with its tags.
+	ListCategories(*ListCategoriesRequest, CategoryService_ListCategoriesServer) error
+	UpdateCategory(context.Context, *Category) (*Empty, error)
+	mustEmbedUnimplementedCategoryServiceServer()
+}
+
+// UnimplementedCategoryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCategoryServiceServer struct {
+}
+
+func (UnimplementedCategoryServiceServer) ListCategories(*ListCategoriesRequest, CategoryService_ListCategoriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListCategories not implemented")
+}
+func (UnimplementedCategoryServiceServer) UpdateCategory(context.Context, *Category) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCategory not implemented")
+}
+func (UnimplementedCategoryServiceServer) mustEmbedUnimplementedCategoryServiceServer() {}
+
+// UnsafeCategoryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CategoryServiceServer will
+// result in compilation errors.
+type UnsafeCategoryServiceServer interface {
+	mustEmbedUnimplementedCategoryServiceServer()
+}
+
+func RegisterCategoryServiceServer(s grpc.ServiceRegistrar, srv CategoryServiceServer) {
+	s.RegisterService(&CategoryService_ServiceDesc, srv)
+}
+
+func _CategoryService_ListCategories_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListCategoriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CategoryServiceServer).ListCategories(m, &categoryServiceListCategoriesServer{stream})
+}
+
+type CategoryService_ListCategoriesServer interface {
+	Send(*Category) error
+	grpc.ServerStream
+}
+
+type categoryServiceListCategoriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *categoryServiceListCategoriesServer) Send(m *Category) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CategoryService_UpdateCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Category)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CategoryServiceServer).UpdateCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CategoryService_UpdateCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CategoryServiceServer).UpdateCategory(ctx, req.(*Category))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CategoryService_ServiceDesc is the grpc.ServiceDesc for CategoryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CategoryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webstorage.v1.CategoryService",
+	HandlerType: (*CategoryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "UpdateCategory",
+			Handler:    _CategoryService_UpdateCategory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListCategories",
+			Handler:       _CategoryService_ListCategories_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "webstorage.proto",
+}
@@ -0,0 +1,146 @@
+package grpcsys
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=server --go-grpc_out=.. --go-grpc_opt=module=server webstorage.proto
+
+import (
+	"context"
+	"net"
+	"server/config"
+	"server/grpcsys/pb"
+	"server/mongodb"
+	"server/util"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type viewerKey struct{}
+
+// NewServer returns a grpc server with every service registered.
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptor),
+		grpc.ChainStreamInterceptor(streamInterceptor),
+	)...)
+	pb.RegisterAuthServiceServer(s, &authService{})
+	pb.RegisterWebServiceServer(s, &webService{})
+	pb.RegisterTagServiceServer(s, &tagService{})
+	pb.RegisterCategoryServiceServer(s, &categoryService{})
+	return s
+}
+
+// Serve listens on the host and port of c, with tls when c has a
+// certificate.
+func Serve(c config.Grpc) error {
+	opts, err := serverOptions(c)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(c.Host, c.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return util.Errorf("listen grpc on %s failed", addr).WithCause(err)
+	}
+	if len(opts) == 0 && !loopback(c.Host) {
+		logrus.Warnf("grpc on %s is plaintext, bearer tokens can be read on the network unless a tls terminator is in front", addr)
+	}
+	return NewServer(opts...).Serve(lis)
+}
+
+// serverOptions returns the tls credentials of c, none without a certificate.
+func serverOptions(c config.Grpc) ([]grpc.ServerOption, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, util.Errorf("grpc.certFile and grpc.keyFile are set together").WithCode(codes.InvalidArgument)
+	}
+	creds, err := credentials.NewServerTLSFromFile(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, util.Errorf("load grpc.certFile and grpc.keyFile failed").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	return []grpc.ServerOption{grpc.Creds(creds)}, nil
+}
+
+// loopback reports whether host only accepts calls from this machine.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authenticate puts the bearer token user into ctx. Calls without a token
+// are anonymous, calls with a bad token fail.
+func authenticate(ctx context.Context) (context.Context, error) {
+	viewer := mongodb.Viewer{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, ok := util.BearerToken(values[0])
+		if !ok {
+			return ctx, util.Errorf("authorization must be a bearer token").WithCode(codes.Unauthenticated)
+		}
+		user, role, err := util.ParseToken(token)
+		if err != nil {
+			return ctx, err
+		}
+		viewer = mongodb.Viewer{Name: user, Role: role}
+	}
+	return context.WithValue(ctx, viewerKey{}, viewer), nil
+}
+
+func viewerFromContext(ctx context.Context) mongodb.Viewer {
+	viewer, _ := ctx.Value(viewerKey{}).(mongodb.Viewer)
+	return viewer
+}
+
+// toStatus converts a util.Error like util.WriteError does for http.
+func toStatus(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := util.Code(err)
+	msg := util.Message(err)
+	entry := logrus.WithFields(logrus.Fields{"method": method, "code": code.String()})
+	if util.HTTPStatus(code) >= 500 {
+		entry.Error(err.Error())
+		msg = code.String()
+	} else {
+		entry.Info(err.Error())
+	}
+	return status.Error(code, msg)
+}
+
+func unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, toStatus(info.FullMethod, err)
+	}
+	resp, err := handler(ctx, req)
+	return resp, toStatus(info.FullMethod, err)
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return toStatus(info.FullMethod, err)
+	}
+	return toStatus(info.FullMethod, handler(srv, &authedStream{ss, ctx}))
+}
@@ -0,0 +1,240 @@
+package grpcsys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"server/config"
+	"server/grpcsys/pb"
+	"server/mongodb"
+	"server/usersys"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves NewServer over an in-memory listener. Users live in memory,
+// the mongodb collections are left to the test.
+func dial(t *testing.T) *grpc.ClientConn {
+	config.Current.User.Test = true
+	t.Cleanup(func() { config.Current.User.Test = false })
+	if err := util.InitAuth([]byte(strings.Repeat("k", util.MinSecretSize)), usersys.Role); err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuth(t *testing.T) {
+	conn := dial(t)
+	auth := pb.NewAuthServiceClient(conn)
+
+	resp, err := auth.Register(context.Background(), &pb.AuthRequest{Username: "alice", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := auth.GetAuth(withToken(resp.Token), &pb.GetAuthRequest{})
+	if err != nil || user.Name != "alice" {
+		t.Errorf("GetAuth with the token = %v, %v", user, err)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"anonymous": context.Background(),
+		"bad token": withToken(resp.Token + "x"),
+		"basic":     metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic abc"),
+	} {
+		if _, err := auth.GetAuth(ctx, &pb.GetAuthRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("GetAuth %s: %v, want Unauthenticated", name, err)
+		}
+	}
+	if _, err := auth.Login(context.Background(), &pb.AuthRequest{Username: "alice", Password: "wrong"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Login with a wrong password: %v", err)
+	}
+}
+
+func TestAnonymousWrites(t *testing.T) {
+	conn := dial(t)
+	ctx := context.Background()
+	if _, err := pb.NewCategoryServiceClient(conn).UpdateCategory(ctx, &pb.Category{Id: "6543210fedcba98765432100", Name: "x"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("anonymous UpdateCategory: %v, want Unauthenticated", err)
+	}
+	if _, err := pb.NewTagServiceClient(conn).UpdateTag(ctx, &pb.Tag{Name: "go", Order: 1}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("anonymous UpdateTag: %v, want Unauthenticated", err)
+	}
+	if _, err := pb.NewWebServiceClient(conn).AddWeb(ctx, &pb.AddWebRequest{Web: &pb.WebData{Url: "https://go.dev"}}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("anonymous AddWeb: %v, want Unauthenticated", err)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	conn := dial(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stream", func(mt *mtest.T) {
+		old := mongodb.Tagdb
+		mongodb.Tagdb = mt.Coll
+		defer func() { mongodb.Tagdb = old }()
+
+		ns := mt.DB.Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+			bson.D{{"name", "go"}, {"ref", 2}, {"order", 0}},
+			bson.D{{"name", "web"}, {"ref", 0}, {"order", 1}},
+		))
+		stream, err := pb.NewTagServiceClient(conn).ListTags(context.Background(), &pb.ListTagsRequest{})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		var names []string
+		for {
+			tag, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				mt.Fatal(err)
+			}
+			names = append(names, tag.Name)
+		}
+		if strings.Join(names, ",") != "go,web" {
+			mt.Errorf("ListTags streamed %v", names)
+		}
+	})
+
+	// streams check tokens too
+	stream, err := pb.NewTagServiceClient(conn).ListTags(withToken("forged.token"), &pb.ListTagsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListTags with a forged token: %v, want Unauthenticated", err)
+	}
+}
+
+// writeCert writes a self-signed certificate of 127.0.0.1 and its key.
+func writeCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile, pool
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, pool := writeCert(t)
+	for name, c := range map[string]config.Grpc{
+		"cert only": {CertFile: certFile},
+		"key only":  {KeyFile: keyFile},
+		"missing":   {CertFile: certFile + ".missing", KeyFile: keyFile},
+	} {
+		if _, err := serverOptions(c); util.Code(err) != codes.InvalidArgument {
+			t.Errorf("serverOptions %s: %v, want InvalidArgument", name, err)
+		}
+	}
+
+	opts, err := serverOptions(config.Grpc{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(opts...)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	// an anonymous GetAuth reaches the service as Unauthenticated
+	call := func(creds credentials.TransportCredentials) error {
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = pb.NewAuthServiceClient(conn).GetAuth(ctx, &pb.GetAuthRequest{})
+		return err
+	}
+	if err := call(credentials.NewTLS(&tls.Config{RootCAs: pool})); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call over tls: %v", err)
+	}
+	if err := call(insecure.NewCredentials()); status.Code(err) != codes.Unavailable {
+		t.Errorf("plaintext call to the tls server: %v, want Unavailable", err)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost": true, "127.0.0.1": true, "::1": true,
+		"": false, "0.0.0.0": false, "192.168.1.2": false, "example.com": false,
+	} {
+		if got := loopback(host); got != want {
+			t.Errorf("loopback(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package linkcheck periodically checks that the urls of web data are still
+// alive and flags the ones that keep failing.
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"server/config"
+	"server/mongodb"
+	"server/outbound"
+	"server/util"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	interval      = &config.Current.Linkcheck.Interval
+	workers       = &config.Current.Linkcheck.Workers
+	hostInterval  = &config.Current.Linkcheck.HostInterval
+	timeout       = &config.Current.Linkcheck.Timeout
+	failThreshold = &config.Current.Linkcheck.FailThreshold
+	userAgent     = &config.Current.Linkcheck.UserAgent
+)
+
+// Start checks the links due every interval/10, so each link is checked
+// about once per interval, also across restarts.
+func Start() {
+	if *interval <= 0 {
+		logrus.Info("link check disabled")
+		return
+	}
+	policy, err := outbound.DefaultPolicy()
+	if err != nil {
+		logrus.Fatalf("link check: %v", err)
+	}
+	checker := NewChecker(outbound.NewClient(*timeout, policy))
+	go func() {
+		ticker := time.NewTicker(*interval / 10)
+		defer ticker.Stop()
+		for {
+			checker.CheckDue(context.Background())
+			<-ticker.C
+		}
+	}()
+}
+
+// Checker checks links with limited concurrency, and waits hostInterval
+// between the requests to one host.
+type Checker struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time // by host
+}
+
+func NewChecker(client *http.Client) *Checker {
+	return &Checker{client: client, nextSlot: map[string]time.Time{}}
+}
+
+// CheckDue checks every link not checked within interval.
+func (c *Checker) CheckDue(ctx context.Context) {
+	jobs := make(chan mongodb.WebData)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for data := range jobs {
+				c.checkWebData(ctx, data)
+			}
+		}()
+	}
+
+	c.mu.Lock()
+	// the slots of the last run passed long ago
+	c.nextSlot = map[string]time.Time{}
+	c.mu.Unlock()
+
+	start := time.Now()
+	count := 0
+	err := mongodb.EachLinkToCheck(ctx, start.Add(-*interval), func(data mongodb.WebData) error {
+		count++
+		jobs <- data
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		logrus.Errorf("link check err: %v", err)
+	}
+	if count > 0 {
+		logrus.Infof("checked %d links in %v", count, time.Since(start).Round(time.Second))
+	}
+}
+
+func (c *Checker) checkWebData(ctx context.Context, data mongodb.WebData) {
+	check := c.Check(ctx, data.Url)
+	failures := 0
+	if data.Link != nil {
+		failures = data.Link.Failures
+	}
+	if failed(check) {
+		failures++
+	} else if check.Status != 0 {
+		failures = 0
+	}
+	broken := failures >= *failThreshold
+	if err := mongodb.RecordLinkCheck(data.ID, data.Url, check, failures, broken); err != nil {
+		util.Errorf("record link check of WebData %d failed", data.ID).WithCause(err).Log()
+	}
+}
+
+// failed reports whether check counts towards flagging a link broken. Pages
+// asking to log in or to slow down are alive.
+func failed(check mongodb.LinkCheck) bool {
+	switch check.Status {
+	case 0:
+		return check.Error != "" && !strings.HasPrefix(check.Error, blockedPrefix)
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return check.Status >= 400
+}
+
+// blockedPrefix marks checks of urls the outbound policy does not let us
+// reach, they are neither alive nor dead.
+const blockedPrefix = "not checked: "
+
+// Check requests rawURL with HEAD, falling back to GET for servers that do
+// not handle HEAD.
+func (c *Checker) Check(ctx context.Context, rawURL string) mongodb.LinkCheck {
+	c.waitHost(ctx, rawURL)
+	start := time.Now()
+	check := mongodb.LinkCheck{CheckedAt: start}
+
+	resp, err := c.request(ctx, http.MethodHead, rawURL)
+	if (err != nil && !util.HaveErrorCode(err, codes.PermissionDenied)) || (err == nil && resp.StatusCode >= 400) {
+		start = time.Now()
+		resp, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = util.Message(err)
+		if util.HaveErrorCode(err, codes.PermissionDenied) {
+			check.Error = blockedPrefix + check.Error
+		}
+		return check
+	}
+	check.Status = resp.StatusCode
+	if resp.URL != rawURL {
+		check.RedirectURL = resp.URL
+	}
+	return check
+}
+
+func (c *Checker) request(ctx context.Context, method, rawURL string) (*outbound.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	return outbound.Fetch(ctx, c.client, outbound.Request{
+		Method:    method,
+		URL:       rawURL,
+		UserAgent: *userAgent,
+		// only the status matters
+		MaxSize: 1,
+	})
+}
+
+// waitHost blocks until hostInterval passed since the last check of the host
+// of rawURL.
+func (c *Checker) waitHost(ctx context.Context, rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextSlot[host] = slot.Add(*hostInterval)
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(slot)):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,81 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/mongodb"
+)
+
+func TestCheck(t *testing.T) {
+	old := *hostInterval
+	*hostInterval = 0
+	defer func() { *hostInterval = old }()
+
+	var heads int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads++
+		}
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	checker := NewChecker(server.Client())
+	for path, want := range map[string]mongodb.LinkCheck{
+		"/ok":      {Status: http.StatusOK},
+		"/no-head": {Status: http.StatusOK},
+		"/old":     {Status: http.StatusOK, RedirectURL: server.URL + "/ok"},
+		"/gone":    {Status: http.StatusNotFound},
+	} {
+		check := checker.Check(context.Background(), server.URL+path)
+		if check.Status != want.Status || check.RedirectURL != want.RedirectURL || check.Error != "" {
+			t.Errorf("Check(%s) = %+v, want %+v", path, check, want)
+		}
+		if failed(check) != (want.Status >= 400) {
+			t.Errorf("failed(%+v) = %v", check, failed(check))
+		}
+	}
+	if heads == 0 {
+		t.Error("checks did not use HEAD")
+	}
+
+	server.Close()
+	check := checker.Check(context.Background(), server.URL+"/ok")
+	if check.Status != 0 || check.Error == "" || !failed(check) {
+		t.Errorf("Check of a closed server = %+v, want a failed check", check)
+	}
+}
+
+func TestCheckWaitsForHost(t *testing.T) {
+	old := *hostInterval
+	*hostInterval = 100 * time.Millisecond
+	defer func() { *hostInterval = old }()
+
+	checker := NewChecker(http.DefaultClient)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		checker.waitHost(context.Background(), "https://example.com/page")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 checks of one host took %v, want at least 2 intervals", elapsed)
+	}
+
+	start = time.Now()
+	checker.waitHost(context.Background(), "https://other.example.com/")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("check of another host waited %v", elapsed)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"server/datasys"
+	"server/mongodb"
+	"server/usersys"
+	"server/util"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+// runMigrate creates the indexes and migrates old documents, which
+// connecting the database already did.
+//
+//	server migrate
+func runMigrate(args []string) error {
+	logrus.Info("database migrated")
+	return nil
+}
+
+// runCreateAdmin creates an admin, or makes an existing user admin.
+//
+//	server create-admin [-password pw] name
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	password := fs.String("password", "", "password, read from stdin when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return util.Errorf("usage: create-admin [-password pw] name")
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	created, err := usersys.CreateAdmin(fs.Arg(0), pw)
+	if err != nil {
+		return err
+	}
+	if created {
+		logrus.Infof("admin %s created", fs.Arg(0))
+	} else {
+		logrus.Infof("user %s is admin now, its password is reset", fs.Arg(0))
+	}
+	return nil
+}
+
+// runResetPassword sets the password of a user.
+//
+//	server reset-password [-password pw] name
+func runResetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	password := fs.String("password", "", "password, read from stdin when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return util.Errorf("usage: reset-password [-password pw] name")
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := usersys.ResetPassword(fs.Arg(0), pw); err != nil {
+		return err
+	}
+	logrus.Infof("password of %s reset", fs.Arg(0))
+	return nil
+}
+
+// runListUsers prints the users and their roles.
+//
+//	server list-users
+func runListUsers(args []string) error {
+	users, err := mongodb.GetAllUsers()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tROLE")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Id.Hex(), u.Name, util.RoleLevel(u.Role))
+	}
+	return tw.Flush()
+}
+
+// seedTags and seedWebData are sample data for a new instance.
+var (
+	seedTags    = []string{"go", "mongodb", "react", "docs"}
+	seedWebData = []mongodb.WebData{
+		{Name: "The Go Programming Language", Url: "https://go.dev/", Tags: []string{"go"}},
+		{Name: "Effective Go", Url: "https://go.dev/doc/effective_go", Tags: []string{"go", "docs"}},
+		{Name: "MongoDB Documentation", Url: "https://www.mongodb.com/docs/", Tags: []string{"mongodb", "docs"}},
+		{Name: "React", Url: "https://react.dev/", Tags: []string{"react"}},
+	}
+)
+
+// runSeed adds the sample tags and web data. What is already saved is
+// skipped, so seeding twice changes nothing.
+//
+//	server seed -owner name
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	owner := fs.String("owner", "", "user owning the sample web data")
+	fs.Parse(args)
+	if *owner == "" {
+		return util.Errorf("usage: seed -owner name")
+	}
+	if _, err := mongodb.GetUserByName(*owner); err != nil {
+		return err
+	}
+	viewer := mongodb.Viewer{Name: *owner, Role: util.RoleManager}
+	for _, tag := range seedTags {
+		err := datasys.AddTag(viewer, mongodb.Tag{Name: tag})
+		if err != nil && !util.HaveErrorCode(err, codes.AlreadyExists) {
+			return err
+		}
+	}
+	added := 0
+	for _, webData := range seedWebData {
+		_, err := datasys.AddWeb(viewer, webData)
+		if util.HaveErrorCode(err, codes.AlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		added++
+	}
+	// pages are fetched when the server starts
+	logrus.Infof("seeded %d web data", added)
+	return nil
+}
+
+// readPassword returns password, or a line of stdin when it is empty. A
+// flag stays in the shell history, stdin does not.
+func readPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", util.Errorf("read password failed").WithCause(err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", util.Errorf("empty password").WithCode(codes.InvalidArgument)
+	}
+	return password, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"server/archive"
+	"server/backup"
+	"server/util"
+)
+
+// runBackup writes an archive of the instance to a file or stdout.
+//
+//	server backup [-secrets] [-o file]
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	secrets := fs.Bool("secrets", false, "include user passwords")
+	output := fs.String("o", "", "archive file, stdout when empty")
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if err := backup.Write(context.Background(), bw, *secrets); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// runRestore restores an archive file, or stdin for "-".
+//
+//	server restore [-mode merge|replace] [-keep user] file
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	mode := fs.String("mode", backup.ModeMerge, "merge or replace")
+	keep := fs.String("keep", "", "user replace mode keeps although the archive lacks it")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return util.Errorf("usage: restore [-mode merge|replace] [-keep user] file")
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	a, err := backup.Read(bufio.NewReader(r))
+	if err != nil {
+		return err
+	}
+	// restoring deletes snapshots of replaced web data
+	if err := archive.Init(); err != nil {
+		return err
+	}
+	report, err := backup.Restore(context.Background(), a, backup.RestoreOptions{Mode: *mode, Keep: *keep})
+	if err != nil {
+		return err
+	}
+	fmt.Println(util.EncodeJson(report))
+	return nil
+}
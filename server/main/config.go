@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"server/config"
+	"server/util"
+	"server/validate"
+	"strconv"
+	"strings"
+)
+
+// configFields are the flags of config.Current, set by main.
+var configFields config.Fields
+
+// configRules are checked at startup, the names are the flags.
+var configRules = validate.Rules[config.Config]{
+	{Name: "port", Value: func(c config.Config) any { return c.Port }, Checks: []validate.Check{validate.Required(), port()}},
+	{Name: "grpc.port", Value: func(c config.Config) any { return c.Grpc.Port }, Checks: []validate.Check{port()}},
+	{Name: "cors.origins", Value: func(c config.Config) any { return c.CORS.Origins }, Checks: []validate.Check{validate.Each(origin())}},
+	{Name: "http.maxBodySize", Value: func(c config.Config) any { return c.HTTP.MaxBodySize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "token.expire", Value: func(c config.Config) any { return c.Token.Expire }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "mongodb.uri", Value: func(c config.Config) any { return c.Mongodb.URI }, Checks: []validate.Check{mongodbURI()}},
+	{Name: "mongodb.name", Value: func(c config.Config) any { return c.Mongodb.Name }, Checks: []validate.Check{validate.Required()}},
+	{Name: "mongodb.authMechanism", Value: func(c config.Config) any { return c.Mongodb.AuthMechanism }, Checks: []validate.Check{validate.OneOf("SCRAM-SHA-256", "SCRAM-SHA-1", "MONGODB-X509", "MONGODB-AWS", "PLAIN", "GSSAPI")}},
+	{Name: "mongodb.maxPoolSize", Value: func(c config.Config) any { return c.Mongodb.MaxPoolSize }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "mongodb.minPoolSize", Value: func(c config.Config) any { return c.Mongodb.MinPoolSize }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "mongodb.timeout", Value: func(c config.Config) any { return c.Mongodb.Timeout }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "mongodb.connectTimeout", Value: func(c config.Config) any { return c.Mongodb.ConnectTimeout }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "mongodb.connectRetry", Value: func(c config.Config) any { return c.Mongodb.ConnectRetry }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "web.urlSchemes", Value: func(c config.Config) any { return c.Web.URLSchemes }, Checks: []validate.Check{validate.Required()}},
+	{Name: "web.maxImportSize", Value: func(c config.Config) any { return c.Web.MaxImportSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "dav.file", Value: func(c config.Config) any { return c.Dav.File }, Checks: []validate.Check{validate.Required()}},
+	{Name: "dav.maxSize", Value: func(c config.Config) any { return c.Dav.MaxSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "crawler.workers", Value: func(c config.Config) any { return c.Crawler.Workers }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "crawler.timeout", Value: func(c config.Config) any { return c.Crawler.Timeout }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "crawler.maxBodySize", Value: func(c config.Config) any { return c.Crawler.MaxBodySize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "crawler.maxTextSize", Value: func(c config.Config) any { return c.Crawler.MaxTextSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "crawler.maxIconSize", Value: func(c config.Config) any { return c.Crawler.MaxIconSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "crawler.iconTTL", Value: func(c config.Config) any { return c.Crawler.IconTTL }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "outbound.maxRedirects", Value: func(c config.Config) any { return c.Outbound.MaxRedirects }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "linkcheck.interval", Value: func(c config.Config) any { return c.Linkcheck.Interval }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "linkcheck.workers", Value: func(c config.Config) any { return c.Linkcheck.Workers }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "linkcheck.hostInterval", Value: func(c config.Config) any { return c.Linkcheck.HostInterval }, Checks: []validate.Check{validate.Min(0)}},
+	{Name: "linkcheck.timeout", Value: func(c config.Config) any { return c.Linkcheck.Timeout }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "linkcheck.failThreshold", Value: func(c config.Config) any { return c.Linkcheck.FailThreshold }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.store", Value: func(c config.Config) any { return c.Archive.Store }, Checks: []validate.Check{validate.Required(), validate.OneOf("file")}},
+	{Name: "archive.dir", Value: func(c config.Config) any { return c.Archive.Dir }, Checks: []validate.Check{validate.Required()}},
+	{Name: "archive.maxSize", Value: func(c config.Config) any { return c.Archive.MaxSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.timeout", Value: func(c config.Config) any { return c.Archive.Timeout }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.workers", Value: func(c config.Config) any { return c.Archive.Workers }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.maxPending", Value: func(c config.Config) any { return c.Archive.MaxPending }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.maxPerUser", Value: func(c config.Config) any { return c.Archive.MaxPerUser }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "archive.maxBytesPerUser", Value: func(c config.Config) any { return c.Archive.MaxBytesPerUser }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "backup.maxRestoreSize", Value: func(c config.Config) any { return c.Backup.MaxRestoreSize }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "graphql.maxDepth", Value: func(c config.Config) any { return c.Graphql.MaxDepth }, Checks: []validate.Check{validate.Positive()}},
+	{Name: "graphql.maxComplexity", Value: func(c config.Config) any { return c.Graphql.MaxComplexity }, Checks: []validate.Check{validate.Positive()}},
+}
+
+func port() validate.Check {
+	return func(value any) string {
+		s, _ := value.(string)
+		if s == "" {
+			return ""
+		}
+		if n, err := strconv.Atoi(s); err != nil || n < 1 || n > 65535 {
+			return "must be a port number"
+		}
+		return ""
+	}
+}
+
+// origin accepts * and urls of a scheme and host only.
+func origin() validate.Check {
+	url := validate.URL("http", "https")
+	return func(value any) string {
+		if value == "*" {
+			return ""
+		}
+		return url(value)
+	}
+}
+
+// mongodbURI checks the scheme only, url.Parse rejects the host lists of
+// replica sets.
+func mongodbURI() validate.Check {
+	return func(value any) string {
+		s, _ := value.(string)
+		if s == "" || strings.HasPrefix(s, "mongodb://") || strings.HasPrefix(s, "mongodb+srv://") {
+			return ""
+		}
+		return "must start with mongodb:// or mongodb+srv://"
+	}
+}
+
+// tokenSecret reads token.secret or token.secretFile, serve needs one.
+func tokenSecret(c config.Token) ([]byte, error) {
+	if c.SecretFile == "" {
+		if c.Secret == "" {
+			return nil, util.Errorf("token.secret or token.secretFile must be set, e.g. to the output of openssl rand -base64 32")
+		}
+		return []byte(c.Secret), nil
+	}
+	if c.Secret != "" {
+		return nil, util.Errorf("both token.secret and token.secretFile are set")
+	}
+	data, err := os.ReadFile(c.SecretFile)
+	if err != nil {
+		return nil, util.Errorf("read token.secretFile failed").WithCause(err)
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+// runConfig prints the effective config with secrets redacted, then tells
+// whether it is valid.
+//
+//	server config print [-format yaml|toml]
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return util.Errorf("usage: config print [-format yaml|toml]")
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	format := fs.String("format", "yaml", "yaml or toml")
+	fs.Parse(args[1:])
+	if err := config.Print(os.Stdout, configFields, *format); err != nil {
+		return err
+	}
+	return configRules.Validate(config.Current)
+}
@@ -2,26 +2,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"server/apidoc"
+	"server/archive"
+	"server/config"
+	"server/crawler"
 	"server/gateway"
+	"server/grpcsys"
+	"server/linkcheck"
 	"server/mongodb"
 	"server/usersys"
+	"server/util"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	ifInit        = flag.Bool("init", false, "if init server")
-	enableSwagger = flag.Bool("swagger", false, "if serve swagger")
-	debugMode     = flag.Bool("debug", false, "if print debug log")
-	mongo         = flag.Bool("mongodb", true, "use mongodb")
-	flagPort      = flag.String("port", "8071", "server port. Default as 8081.")
-)
-
 var router = mux.NewRouter()
 
 func init() {
@@ -29,7 +31,13 @@ func init() {
 }
 
 func main() {
-	flag.Parse()
+	flag.Usage = usage
+	fields, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	configFields = fields
 
 	// logger
 	logrus.SetReportCaller(true)
@@ -37,28 +45,125 @@ func main() {
 	customFormatter.FullTimestamp = true
 	customFormatter.TimestampFormat = "2006-01-02 15:04:05"
 	logrus.SetFormatter(customFormatter)
-	if *debugMode {
+	if config.Current.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
-	mongodb.NewDatabase()
+	name, args := "serve", []string{}
+	if flag.NArg() > 0 {
+		name, args = flag.Arg(0), flag.Args()[1:]
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", name)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// config print shows an invalid config too, and needs no database
+	if name == "config" {
+		if err := runConfig(args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := configRules.Validate(config.Current); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := mongodb.NewDatabase(); err != nil {
+		log.Fatal(err)
+	}
 	logrus.Info("database connected")
 
+	if err := command(args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// commands of the server binary, they share the flags given before the
+// command name. Only serve starts the server.
+var commands = map[string]func(args []string) error{
+	"serve":          runServe,
+	"migrate":        runMigrate,
+	"create-admin":   runCreateAdmin,
+	"reset-password": runResetPassword,
+	"list-users":     runListUsers,
+	"seed":           runSeed,
+	"backup":         runBackup,
+	"restore":        runRestore,
+	"config":         runConfig,
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: %s [flags] [command] [arguments]
+
+commands:
+  serve                                  start the server, the default
+  migrate                                create indexes and migrate old data
+  create-admin [-password pw] name       create an admin or make a user admin
+  reset-password [-password pw] name     set the password of a user
+  list-users                             print the users and their roles
+  seed -owner name                       add sample tags and web data
+  backup [-secrets] [-o file]            write an archive of the instance
+  restore [-mode m] [-keep user] file    restore an archive
+  config print [-format yaml|toml]       print the effective config, secrets redacted
+
+Every flag may also be set in the -config file, or in the environment like
+crawler.maxBodySize in WEBSTORAGE_CRAWLER_MAX_BODY_SIZE. Flags override the
+environment, the environment overrides the file.
+
+flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// runServe starts the server.
+//
+//	server [flags] serve
+func runServe(args []string) error {
+	if len(args) > 0 {
+		return util.Errorf("serve takes no arguments, flags go before the command")
+	}
+	secret, err := tokenSecret(config.Current.Token)
+	if err != nil {
+		return err
+	}
+	if err := util.InitAuth(secret, usersys.Role); err != nil {
+		return err
+	}
 	usersys.Init()
+	crawler.Start()
+	linkcheck.Start()
+	if err := archive.Init(); err != nil {
+		return err
+	}
 
 	// network
 	gateway.NewService(router)
+	if config.Current.Swagger {
+		apidoc.NewService(router)
+		logrus.Info("serve api docs at /swagger/")
+	}
+
+	if c := config.Current.Grpc; c.Port != "" {
+		go func() {
+			log.Println("gRPC server started at " + net.JoinHostPort(c.Host, c.Port))
+			if err := grpcsys.Serve(c); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
 	// 启动服务
-	log.Println("Server started at http://localhost:" + *flagPort + "/")
+	port := config.Current.Port
+	log.Println("Server started at http://localhost:" + port + "/")
 	c := cors.New(cors.Options{
-		// AllowedOrigins:   []string{"*"},
-		AllowedOrigins:   []string{"http://localhost:8080", "http://localhost:3001"},
+		AllowedOrigins:   config.Current.CORS.Origins,
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPatch},
+		AllowedHeaders:   []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization"},
 		AllowCredentials: true,
 	})
-	handler := c.Handler(router)
-	if err := http.ListenAndServe(":"+*flagPort, handler); err != nil {
-		log.Fatal(err)
-	}
+	handler := util.RequestID(c.Handler(router))
+	return http.ListenAndServe(":"+port, handler)
 }
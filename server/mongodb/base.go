@@ -2,36 +2,204 @@ package mongodb
 
 import (
 	"context"
-	"flag"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"server/config"
 	"server/util"
+	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"google.golang.org/grpc/codes"
 )
 
-var mongodbAddr = flag.String("mongodb.addr", "localhost:27017", "mongodb addr, default localhost:27017")
-var mongodbName = flag.String("mongodb.name", "webStorage", "mongodb name, default webStorage")
+var mongodbName = &config.Current.Mongodb.Name
 var db *mongo.Database
 
-const dbTimeoutTime = 5 * time.Second
+// dbTimeout bounds every database operation.
+var dbTimeout = &config.Current.Mongodb.Timeout
 
-func NewDatabase() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+*mongodbAddr))
+const (
+	minConnectBackoff = time.Second
+	maxConnectBackoff = 30 * time.Second
+)
+
+// NewDatabase connects to mongodb and prepares the collections. An
+// unreachable server is retried with backoff for mongodb.connectRetry, a
+// wrong config is not.
+func NewDatabase() error {
+	c := config.Current.Mongodb
+	opts, err := clientOptions(c)
 	if err != nil {
-		panic(util.Errorf("init mongodb error").WithCause(err))
+		return err
 	}
-	err = client.Ping(ctx, readpref.Primary())
+	client, err := connect(opts, c.ConnectTimeout, c.ConnectRetry)
 	if err != nil {
-		panic(util.Errorf("ping mongodb error").WithCause(err))
+		return err
 	}
-
 	db = client.Database(*mongodbName)
 
 	InitMongoDB()
+	return nil
+}
+
+func connect(opts *options.ClientOptions, timeout, retry time.Duration) (*mongo.Client, error) {
+	deadline := time.Now().Add(retry)
+	backoff := minConnectBackoff
+	for {
+		client, err := tryConnect(opts, timeout)
+		if err == nil {
+			return client, nil
+		}
+		if !time.Now().Add(backoff).Before(deadline) {
+			return nil, err
+		}
+		logrus.Warnf("connect mongodb %s err: %v, retry in %s", strings.Join(opts.Hosts, ","), err, backoff)
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff)
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxConnectBackoff {
+		backoff = maxConnectBackoff
+	}
+	return backoff
+}
+
+func tryConnect(opts *options.ClientOptions, timeout time.Duration) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, util.Errorf("init mongodb error").WithCause(err).WithCode(codes.Unavailable)
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, util.Errorf("ping mongodb error").WithCause(err).WithCode(codes.Unavailable)
+	}
+	return client, nil
+}
+
+// clientOptions builds the driver options of c. The structured options
+// override what the uri says.
+func clientOptions(c config.Mongodb) (*options.ClientOptions, error) {
+	uri := c.URI
+	if uri == "" {
+		if c.Addr == "" {
+			return nil, util.Errorf("neither mongodb.uri nor mongodb.addr is set").WithCode(codes.InvalidArgument)
+		}
+		uri = "mongodb://" + c.Addr
+	}
+	opts := options.Client().ApplyURI(uri)
+	if err := opts.Validate(); err != nil {
+		// the uri may hold a password, it is not logged
+		return nil, util.Errorf("invalid mongodb.uri").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+
+	if err := applyAuth(opts, c); err != nil {
+		return nil, err
+	}
+	if c.ReplicaSet != "" {
+		opts.SetReplicaSet(c.ReplicaSet)
+	}
+	if c.TLS || c.TLSCAFile != "" || c.TLSCertFile != "" || c.TLSInsecure {
+		tlsConfig, err := tlsConfig(opts.TLSConfig, c)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	if c.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(c.MaxPoolSize))
+	}
+	if c.MinPoolSize > 0 {
+		opts.SetMinPoolSize(uint64(c.MinPoolSize))
+	}
+	opts.SetConnectTimeout(c.ConnectTimeout)
+	opts.SetServerSelectionTimeout(c.ConnectTimeout)
+	if err := opts.Validate(); err != nil {
+		return nil, util.Errorf("invalid mongodb options").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	return opts, nil
+}
+
+// applyAuth sets the credential of c over the one of the uri.
+func applyAuth(opts *options.ClientOptions, c config.Mongodb) error {
+	password := c.Password
+	if c.PasswordFile != "" {
+		if password != "" {
+			return util.Errorf("both mongodb.password and mongodb.passwordFile are set").WithCode(codes.InvalidArgument)
+		}
+		data, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return util.Errorf("read mongodb.passwordFile failed").WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	if opts.Auth == nil && c.User == "" && c.AuthMechanism == "" {
+		if password != "" || c.AuthSource != "" {
+			return util.Errorf("mongodb.user is not set").WithCode(codes.InvalidArgument)
+		}
+		return nil
+	}
+
+	cred := options.Credential{}
+	if opts.Auth != nil {
+		cred = *opts.Auth
+	}
+	if c.User != "" {
+		cred.Username = c.User
+		cred.Password = password
+		cred.PasswordSet = password != ""
+	}
+	if c.AuthSource != "" {
+		cred.AuthSource = c.AuthSource
+	}
+	if c.AuthMechanism != "" {
+		cred.AuthMechanism = c.AuthMechanism
+	}
+	opts.SetAuth(cred)
+	return nil
+}
+
+// tlsConfig extends base, the tls config of the uri if any, by c.
+func tlsConfig(base *tls.Config, c config.Mongodb) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, util.Errorf("read mongodb.tlsCAFile failed").WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, util.Errorf("no certificate in mongodb.tlsCAFile").WithCode(codes.InvalidArgument)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.TLSCertFile != "" {
+		pem, err := os.ReadFile(c.TLSCertFile)
+		if err != nil {
+			return nil, util.Errorf("read mongodb.tlsCertFile failed").WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		cert, err := tls.X509KeyPair(pem, pem)
+		if err != nil {
+			return nil, util.Errorf("invalid mongodb.tlsCertFile").WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.TLSInsecure {
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg, nil
 }
 
 var dbDatas []dbData
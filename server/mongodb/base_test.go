@@ -0,0 +1,100 @@
+package mongodb
+
+import (
+	"os"
+	"path/filepath"
+	"server/config"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	c := config.Default().Mongodb
+	opts, err := clientOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Hosts) != 1 || opts.Hosts[0] != "localhost:27017" || opts.Auth != nil {
+		t.Errorf("default options: hosts %v, auth %+v", opts.Hosts, opts.Auth)
+	}
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c.Addr = "db1:27017,db2:27017"
+	c.User = "app"
+	c.PasswordFile = passwordFile
+	c.AuthSource = "admin"
+	c.ReplicaSet = "rs0"
+	c.MaxPoolSize = 20
+	opts, err = clientOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Hosts) != 2 {
+		t.Errorf("hosts = %v, want db1 and db2", opts.Hosts)
+	}
+	if opts.Auth == nil || opts.Auth.Username != "app" || opts.Auth.Password != "s3cret" || opts.Auth.AuthSource != "admin" {
+		t.Errorf("auth = %+v", opts.Auth)
+	}
+	if opts.ReplicaSet == nil || *opts.ReplicaSet != "rs0" || opts.MaxPoolSize == nil || *opts.MaxPoolSize != 20 {
+		t.Errorf("replica set %v, max pool size %v", opts.ReplicaSet, opts.MaxPoolSize)
+	}
+}
+
+func TestClientOptionsURI(t *testing.T) {
+	c := config.Default().Mongodb
+	c.URI = "mongodb://app:pw@db1:27017,db2:27017/?authSource=admin&replicaSet=rs0&tls=true"
+	opts, err := clientOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Auth == nil || opts.Auth.Username != "app" || opts.Auth.Password != "pw" || opts.Auth.AuthSource != "admin" {
+		t.Errorf("auth of the uri = %+v", opts.Auth)
+	}
+	if opts.TLSConfig == nil {
+		t.Error("tls of the uri is off")
+	}
+
+	// the structured options win
+	c.AuthSource = "users"
+	opts, err = clientOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Auth.Username != "app" || opts.Auth.AuthSource != "users" {
+		t.Errorf("auth = %+v, want authSource users", opts.Auth)
+	}
+}
+
+func TestClientOptionsRejects(t *testing.T) {
+	for name, change := range map[string]func(c *config.Mongodb){
+		"no host":           func(c *config.Mongodb) { c.Addr = "" },
+		"bad uri":           func(c *config.Mongodb) { c.URI = "mongodb://a:b:c@/?bogus==" },
+		"password only":     func(c *config.Mongodb) { c.Password = "pw" },
+		"two passwords":     func(c *config.Mongodb) { c.User, c.Password, c.PasswordFile = "app", "pw", "password" },
+		"no password file":  func(c *config.Mongodb) { c.User, c.PasswordFile = "app", filepath.Join(t.TempDir(), "none") },
+		"no ca file":        func(c *config.Mongodb) { c.TLSCAFile = filepath.Join(t.TempDir(), "none") },
+		"pool out of order": func(c *config.Mongodb) { c.MaxPoolSize, c.MinPoolSize = 1, 2 },
+	} {
+		c := config.Default().Mongodb
+		change(&c)
+		if _, err := clientOptions(c); err == nil {
+			t.Errorf("%s: clientOptions accepted %+v", name, c)
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	backoff := minConnectBackoff
+	for i := 0; i < 10; i++ {
+		backoff = nextBackoff(backoff)
+	}
+	if backoff != maxConnectBackoff {
+		t.Errorf("backoff = %s, want the max %s", backoff, maxConnectBackoff)
+	}
+	if got := nextBackoff(2 * time.Second); got != 4*time.Second {
+		t.Errorf("nextBackoff(2s) = %s", got)
+	}
+}
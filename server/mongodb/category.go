@@ -2,7 +2,6 @@ package mongodb
 
 import (
 	"context"
-	"log"
 	"server/util"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -25,9 +24,9 @@ func init() {
 
 func (Category) initTable() {
 	CategoryDb = db.Collection("Category")
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	if count, _ := CategoryDb.CountDocuments(ctx, bson.M{}); count != 4 {
+	if count, _ := CategoryDb.CountDocuments(ctx, bson.M{}); count == 0 {
 		cate1, cate2, cate3, cate4 :=
 			Category{Name: "Category1"},
 			Category{Name: "Category2"},
@@ -37,22 +36,24 @@ func (Category) initTable() {
 	}
 }
 
-func AddCategory(data Category) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+// AddCategory inserts data without its tags and returns its ID.
+func AddCategory(data Category) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	_, err := CategoryDb.InsertOne(ctx, data)
+	data.Tags = nil
+	res, err := CategoryDb.InsertOne(ctx, data)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
-			return util.Errorf("add Category %s failed to exec.", data.Name).WithCause(err).WithCode(codes.AlreadyExists)
+			return primitive.NilObjectID, util.Errorf("add Category %s failed to exec.", data.Name).WithCause(err).WithCode(codes.AlreadyExists)
 		}
-		return util.Errorf("add Category %s failed to exec.", data.Name).WithCause(err)
+		return primitive.NilObjectID, util.Errorf("add Category %s failed to exec.", data.Name).WithCause(err)
 	}
 
-	return nil
+	return res.InsertedID.(primitive.ObjectID), nil
 }
 
 func GetAllCategories() ([]Category, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	// Create a pipeline for aggregation
 	pipeline := mongo.Pipeline{
@@ -67,7 +68,6 @@ func GetAllCategories() ([]Category, error) {
 	// Perform the aggregation
 	cursor, err := CategoryDb.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Fatal(err)
 		return nil, util.Errorf("find all components failed").WithCause(err)
 	}
 	defer cursor.Close(context.Background())
@@ -79,13 +79,41 @@ func GetAllCategories() ([]Category, error) {
 	return datas, nil
 }
 
+func GetCategoryByID(id primitive.ObjectID) (Category, error) {
+	result := Category{}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	err := CategoryDb.FindOne(ctx, bson.M{"_id": id}).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get %s Category failed", id.Hex()).WithCause(err).WithCode(codes.NotFound)
+		}
+		return result, util.Errorf("get %s Category failed", id.Hex()).WithCause(err)
+	}
+	return result, nil
+}
+
+func GetCategoryByName(name string) (Category, error) {
+	result := Category{}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	err := CategoryDb.FindOne(ctx, bson.M{"name": name}).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get %s Category failed", name).WithCause(err).WithCode(codes.NotFound)
+		}
+		return result, util.Errorf("get %s Category failed", name).WithCause(err)
+	}
+	return result, nil
+}
+
 func UpdateCategory(id string, data Category) error {
 	update := bson.D{{"$set", data}}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	_id, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return util.Errorf("update %s Tag failed", id).WithCause(err)
+		return util.Errorf("update %s Tag failed", id).WithCause(err).WithCode(codes.InvalidArgument)
 	}
 	_, err = CategoryDb.UpdateByID(ctx, _id, update)
 	if err != nil {
@@ -95,7 +123,7 @@ func UpdateCategory(id string, data Category) error {
 }
 
 func DeleteCategory(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	_, err := CategoryDb.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
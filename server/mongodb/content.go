@@ -0,0 +1,136 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// status of the page content fetch and enrichment of web data
+const (
+	FetchPending  = "pending" // queued, not fetched yet
+	FetchDone     = "done"
+	FetchFailed   = "failed"
+	FetchBlocked  = "blocked"  // disallowed by robots.txt
+	FetchDisabled = "disabled" // not fetched, the crawler has no workers
+)
+
+// withoutContent leaves the possibly large page content out of query results.
+var withoutContent = bson.M{"content": 0}
+
+type FetchInfo struct {
+	Status    string
+	Error     string    `bson:",omitempty"`
+	FetchedAt time.Time `bson:"fetchedAt,omitempty"`
+	// Size is the byte length of the stored content.
+	Size int `bson:",omitempty"`
+}
+
+// SetWebDataContent stores the page content fetched from url with its fetch
+// info. Nothing is written when the url of web data id changed meanwhile.
+func SetWebDataContent(id int, url string, content string, info FetchInfo) error {
+	set := bson.M{"fetch": info}
+	if info.Status == FetchDone {
+		set["content"] = content
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := WebDatadb.UpdateOne(ctx, bson.M{"_id": id, "url": url}, bson.M{"$set": set}); err != nil {
+		return util.Errorf("set content of WebData %d failed", id).WithCause(err)
+	}
+	return nil
+}
+
+// EnrichWebData fills the empty Name and Description of web data id from the
+// page at url and sets its icon and enrich status.
+func EnrichWebData(id int, url string, name, description, icon, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	filter := bson.M{"_id": id, "url": url}
+	set := bson.M{"enrich": status}
+	if icon != "" {
+		set["icon"] = icon
+	}
+	if _, err := WebDatadb.UpdateOne(ctx, filter, bson.M{"$set": set}); err != nil {
+		return util.Errorf("enrich WebData %d failed", id).WithCause(err)
+	}
+	// only fill what is still empty, the owner may have edited meanwhile
+	for field, value := range map[string]string{"name": name, "description": description} {
+		if value == "" {
+			continue
+		}
+		emptyFilter := bson.M{"_id": id, "url": url, field: bson.M{"$in": bson.A{"", nil}}}
+		if _, err := WebDatadb.UpdateOne(ctx, emptyFilter, bson.M{"$set": bson.M{field: value}}); err != nil {
+			return util.Errorf("enrich WebData %d %s failed", id, field).WithCause(err)
+		}
+	}
+	return nil
+}
+
+// MarkWebDataFetchPending resets the fetch status of web data id, keeping the
+// old content searchable until the refetch finishes.
+func MarkWebDataFetchPending(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	pending := bson.M{"fetch": FetchInfo{Status: FetchPending}, "enrich": FetchPending}
+	res, err := WebDatadb.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": pending})
+	if err != nil {
+		return util.Errorf("mark WebData %d fetch pending failed", id).WithCause(err)
+	}
+	if res.MatchedCount == 0 {
+		return util.Errorf("WebData %d not found", id).WithCode(codes.NotFound)
+	}
+	return nil
+}
+
+// pendingFetch matches the web data never fetched or still queued.
+var pendingFetch = bson.M{"$or": bson.A{
+	bson.M{"fetch": bson.M{"$exists": false}},
+	bson.M{"fetch.status": FetchPending},
+}}
+
+// DisablePendingFetches marks the fetch and enrichment of all pending web
+// data disabled, so they do not look queued while the crawler is off.
+func DisablePendingFetches() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	disabled := bson.M{"fetch": FetchInfo{Status: FetchDisabled}, "enrich": FetchDisabled}
+	res, err := WebDatadb.UpdateMany(ctx, pendingFetch, bson.M{"$set": disabled})
+	if err != nil {
+		return 0, util.Errorf("disable pending fetch WebData failed").WithCause(err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// EachPendingFetch calls fn for the web data never fetched, still queued or
+// disabled, for example when the server stopped before the worker got to
+// them or the crawler was off.
+func EachPendingFetch(ctx context.Context, fn func(WebData) error) error {
+	filter := bson.M{"$or": bson.A{
+		pendingFetch,
+		bson.M{"fetch.status": FetchDisabled},
+	}}
+	cursor, err := WebDatadb.Find(ctx, filter, options.Find().SetProjection(withoutContent))
+	if err != nil {
+		return util.Errorf("get pending fetch WebData failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var data WebData
+		if err := cursor.Decode(&data); err != nil {
+			return util.Errorf("decode WebData failed").WithCause(err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return util.Errorf("get pending fetch WebData failed").WithCause(err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package mongodb
+
+import (
+	"context"
+	"server/urlnorm"
+	"server/util"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// Duplicates are web data of one owner sharing one canonical url.
+type Duplicates struct {
+	Owner     string    `bson:"owner" json:"owner"`
+	Canonical string    `bson:"canonical" json:"canonical"`
+	Items     []WebData `bson:"items" json:"items"`
+}
+
+// the url and then the canonical url were once unique across owners
+const (
+	oldURLIndex       = "url_1"
+	oldCanonicalIndex = "canonical_1"
+)
+
+// initCanonicalIndex fills the canonical url of web data saved before it
+// existed and makes it unique per owner, instead of the url across owners.
+// Old duplicates keep the index from being created until they are merged.
+func initCanonicalIndex() {
+	migrateCanonical()
+	for _, index := range []string{oldURLIndex, oldCanonicalIndex} {
+		if _, err := WebDatadb.Indexes().DropOne(context.Background(), index); err == nil {
+			logrus.Infof("drop index %s of webData", index)
+		}
+	}
+	_, err := WebDatadb.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}, {Key: "canonical", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"canonical": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		logrus.Errorf("create index for webData canonical err: %v, merge the duplicates of GET /v1/web/duplicates and restart", err)
+	}
+}
+
+func migrateCanonical() {
+	ctx := context.Background()
+	cursor, err := WebDatadb.Find(ctx, bson.M{"canonical": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"url": 1}))
+	if err != nil {
+		logrus.Errorf("migrate webData canonical url err: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var data WebData
+		if err := cursor.Decode(&data); err != nil {
+			logrus.Errorf("migrate webData canonical url err: %v", err)
+			return
+		}
+		canonical, err := urlnorm.Normalize(data.Url)
+		if err != nil {
+			// unparsable urls only match themselves
+			canonical = data.Url
+		}
+		if _, err := WebDatadb.UpdateOne(ctx, bson.M{"_id": data.ID}, bson.M{"$set": bson.M{"canonical": canonical}}); err != nil {
+			logrus.Errorf("migrate webData %d canonical url err: %v", data.ID, err)
+			continue
+		}
+		count++
+	}
+	if count > 0 {
+		logrus.Infof("migrate %d webData canonical url", count)
+	}
+}
+
+// GetWebDataByCanonical returns the web data of owner with the canonical
+// url. Other owners may save the same url.
+func GetWebDataByCanonical(owner, canonical string) (WebData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	var result WebData
+	err := WebDatadb.FindOne(ctx, bson.M{"owner": owner, "canonical": canonical}, options.FindOne().SetProjection(withoutContent)).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get WebData of %s failed", canonical).WithCause(err).WithCode(codes.NotFound)
+		}
+		return result, util.Errorf("get WebData of %s failed", canonical).WithCause(err)
+	}
+	return result, nil
+}
+
+// FindDuplicates returns the web data visible to viewer that share their
+// owner and canonical url with another one, oldest first within each group.
+func FindDuplicates(viewer Viewer) ([]Duplicates, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	pipeline := bson.A{
+		bson.M{"$match": withViewer(bson.M{"canonical": bson.M{"$type": "string"}}, viewer)},
+		bson.M{"$project": withoutContent},
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"owner": "$owner", "canonical": "$canonical"},
+			"items": bson.M{"$push": "$$ROOT"},
+		}},
+		bson.M{"$match": bson.M{"items.1": bson.M{"$exists": true}}},
+		bson.M{"$project": bson.M{"_id": 0, "owner": "$_id.owner", "canonical": "$_id.canonical", "items": 1}},
+		bson.M{"$sort": bson.M{"canonical": 1, "owner": 1}},
+	}
+	cursor, err := WebDatadb.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, util.Errorf("find duplicate WebData failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	duplicates := []Duplicates{}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return nil, util.Errorf("find duplicate WebData failed").WithCause(err)
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,44 @@
+package mongodb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestInitCanonicalIndex(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("drop the old indexes", func(mt *mtest.T) {
+		old := WebDatadb
+		defer func() { WebDatadb = old }()
+		WebDatadb = mt.Coll
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		ok := mtest.CreateSuccessResponse()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch), ok, ok, ok)
+		initCanonicalIndex()
+
+		var dropped []string
+		var created string
+		for _, e := range mt.GetAllStartedEvents() {
+			switch e.CommandName {
+			case "dropIndexes":
+				dropped = append(dropped, e.Command.Lookup("index").StringValue())
+			case "createIndexes":
+				created = e.Command.Lookup("indexes").String()
+			}
+		}
+		if len(dropped) != 2 || dropped[0] != oldURLIndex || dropped[1] != oldCanonicalIndex {
+			t.Errorf("dropped %v, want %s and %s", dropped, oldURLIndex, oldCanonicalIndex)
+		}
+		if created == "" {
+			t.Error("created no index")
+		}
+		for _, want := range []string{`"owner": {"$numberInt":"1"}`, `"canonical": {"$numberInt":"1"}`, `"unique": true`} {
+			if !strings.Contains(created, want) {
+				t.Errorf("created %s, want %s", created, want)
+			}
+		}
+	})
+}
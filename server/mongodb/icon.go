@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// Icon is a cached favicon, shared by the web data of the same site.
+type Icon struct {
+	URL         string `bson:"_id"`
+	ContentType string
+	Data        []byte
+	FetchedAt   time.Time `bson:"fetchedAt"`
+}
+
+var Icondb *mongo.Collection
+
+func init() {
+	registerDBData(Icon{})
+}
+
+func (Icon) initTable() {
+	Icondb = db.Collection("webIcon")
+}
+
+// SaveIcon inserts or replaces the icon cached for icon.URL.
+func SaveIcon(icon Icon) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	_, err := Icondb.ReplaceOne(ctx, bson.M{"_id": icon.URL}, icon, options.Replace().SetUpsert(true))
+	if err != nil {
+		return util.Errorf("save icon %s failed", icon.URL).WithCause(err)
+	}
+	return nil
+}
+
+func GetIcon(url string) (Icon, error) {
+	var icon Icon
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	err := Icondb.FindOne(ctx, bson.M{"_id": url}).Decode(&icon)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return icon, util.Errorf("icon %s not found", url).WithCause(err).WithCode(codes.NotFound)
+		}
+		return icon, util.Errorf("get icon %s failed", url).WithCause(err)
+	}
+	return icon, nil
+}
@@ -0,0 +1,106 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxLinkHistory is the number of link checks kept per web data.
+const maxLinkHistory = 20
+
+// LinkCheck is the result of checking whether the url of web data is alive.
+type LinkCheck struct {
+	CheckedAt time.Time `bson:"checkedAt"`
+	// Status is the http status, 0 when the request failed.
+	Status int
+	// RedirectURL is where the url ended up, when it redirected.
+	RedirectURL string `bson:"redirectUrl,omitempty"`
+	LatencyMs   int64  `bson:"latencyMs"`
+	Error       string `bson:",omitempty"`
+}
+
+// LinkStatus is the latest link check of web data and its history.
+type LinkStatus struct {
+	LinkCheck `bson:",inline"`
+	// Failures counts the failed checks in a row.
+	Failures int
+	// Broken is set once Failures reaches the threshold of the checker.
+	Broken  bool
+	History []LinkCheck `bson:",omitempty"`
+}
+
+// RecordLinkCheck stores check of web data id and appends it to the history.
+// Nothing is written when the url of web data id changed meanwhile.
+func RecordLinkCheck(id int, url string, check LinkCheck, failures int, broken bool) error {
+	update := bson.M{
+		"$set": bson.M{
+			"link.checkedAt":   check.CheckedAt,
+			"link.status":      check.Status,
+			"link.redirectUrl": check.RedirectURL,
+			"link.latencyMs":   check.LatencyMs,
+			"link.error":       check.Error,
+			"link.failures":    failures,
+			"link.broken":      broken,
+		},
+		"$push": bson.M{"link.history": bson.M{
+			"$each":  bson.A{check},
+			"$slice": -maxLinkHistory,
+		}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := WebDatadb.UpdateOne(ctx, bson.M{"_id": id, "url": url}, update); err != nil {
+		return util.Errorf("record link check of WebData %d failed", id).WithCause(err)
+	}
+	return nil
+}
+
+// ClearLinkStatus forgets the link checks of web data id, after its url
+// changed.
+func ClearLinkStatus(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := WebDatadb.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"link": ""}}); err != nil {
+		return util.Errorf("clear link status of WebData %d failed", id).WithCause(err)
+	}
+	return nil
+}
+
+// EachLinkToCheck calls fn for the web data never checked or last checked
+// before checkedBefore.
+func EachLinkToCheck(ctx context.Context, checkedBefore time.Time, fn func(WebData) error) error {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"link.checkedAt": bson.M{"$exists": false}},
+		bson.M{"link.checkedAt": bson.M{"$lt": checkedBefore}},
+	}}
+	// checks wait for rate limits, so the cursor may idle for long
+	opts := options.Find().SetProjection(bson.M{"content": 0, "link.history": 0}).SetNoCursorTimeout(true)
+	cursor, err := WebDatadb.Find(ctx, filter, opts)
+	if err != nil {
+		return util.Errorf("get WebData to check failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var data WebData
+		if err := cursor.Decode(&data); err != nil {
+			return util.Errorf("decode WebData failed").WithCause(err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return util.Errorf("get WebData to check failed").WithCause(err)
+	}
+	return nil
+}
+
+// BrokenLinkFilter matches the web data flagged broken by the link checker.
+func BrokenLinkFilter() bson.M {
+	return bson.M{"link.broken": true}
+}
@@ -0,0 +1,141 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"server/api"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// PageRequest selects one page of a sorted listing.
+type PageRequest = api.PageRequest
+
+type WebDataPage struct {
+	Items      []WebData `json:"items"`
+	Total      int64     `json:"total"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+var webDataSortKeys = map[string]string{
+	"":        "_id",
+	"id":      "_id",
+	"name":    "name",
+	"created": "created",
+	"updated": "updated",
+}
+
+// pageCursor is the sort value and ID of the last item of a page. Times are
+// unix milliseconds, the precision mongodb stores; nanoseconds would not
+// survive json numbers.
+type pageCursor struct {
+	Name *string `json:"n,omitempty"`
+	Time *int64  `json:"t,omitempty"`
+	ID   int     `json:"id"`
+}
+
+func encodeCursor(key string, data WebData) string {
+	c := pageCursor{ID: data.ID}
+	switch key {
+	case "name":
+		c.Name = &data.Name
+	case "created":
+		ms := data.Created.UnixMilli()
+		c.Time = &ms
+	case "updated":
+		ms := data.Updated.UnixMilli()
+		c.Time = &ms
+	}
+	j, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+// cursorFilter matches the items sorted after cursor.
+func cursorFilter(key string, desc bool, cursor string) (bson.M, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, util.Errorf("invalid cursor").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	var c pageCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, util.Errorf("invalid cursor").WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+	if key == "_id" {
+		return bson.M{"_id": bson.M{op: c.ID}}, nil
+	}
+
+	var value any
+	switch {
+	case key == "name" && c.Name != nil:
+		value = *c.Name
+	case key != "name" && c.Time != nil:
+		value = time.UnixMilli(*c.Time)
+	}
+	if value == nil {
+		return nil, util.Errorf("invalid cursor for sort %s", key).WithCode(codes.InvalidArgument)
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{key: bson.M{op: value}},
+		bson.M{key: value, "_id": bson.M{op: c.ID}},
+	}}, nil
+}
+
+// ListWebData returns one page of the web data visible to viewer matching
+// filter.
+func ListWebData(filter bson.M, viewer Viewer, req PageRequest) (WebDataPage, error) {
+	key, ok := webDataSortKeys[req.Sort]
+	if !ok {
+		return WebDataPage{}, util.Errorf("invalid sort %s", req.Sort).WithCode(codes.InvalidArgument)
+	}
+	filter = withViewer(filter, viewer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	total, err := WebDatadb.CountDocuments(ctx, filter)
+	if err != nil {
+		return WebDataPage{}, util.Errorf("count WebData failed").WithCause(err)
+	}
+
+	find := filter
+	if req.Cursor != "" {
+		after, err := cursorFilter(key, req.Desc, req.Cursor)
+		if err != nil {
+			return WebDataPage{}, err
+		}
+		find = bson.M{"$and": bson.A{filter, after}}
+	}
+	dir := 1
+	if req.Desc {
+		dir = -1
+	}
+	sort := bson.D{{Key: key, Value: dir}}
+	if key != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: dir})
+	}
+	// one more than asked to know if there is a next page
+	opts := options.Find().SetSort(sort).SetLimit(int64(req.Limit) + 1).SetProjection(withoutContent)
+	cursor, err := WebDatadb.Find(ctx, find, opts)
+	if err != nil {
+		return WebDataPage{}, util.Errorf("list WebData failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	page := WebDataPage{Items: []WebData{}, Total: total}
+	if err := cursor.All(ctx, &page.Items); err != nil {
+		return WebDataPage{}, util.Errorf("list WebData failed").WithCause(err)
+	}
+	if len(page.Items) > req.Limit {
+		page.Items = page.Items[:req.Limit]
+		page.NextCursor = encodeCursor(key, page.Items[req.Limit-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,106 @@
+package mongodb
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc/codes"
+)
+
+// cursorValue returns the sort value of the filter of cursor.
+func cursorValue(t *testing.T, key string, desc bool, cursor string) any {
+	t.Helper()
+	filter, err := cursorFilter(key, desc, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter["$or"].(bson.A)[1].(bson.M)[key]
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	// mongodb keeps milliseconds, as the times of a page are read back
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		created := time.UnixMilli(base + rnd.Int63n(1e12))
+		cursor := encodeCursor("created", WebData{ID: i, Created: created})
+		got, ok := cursorValue(t, "created", true, cursor).(time.Time)
+		if !ok || !got.Equal(created) {
+			t.Fatalf("cursor of %s gives %v", created.Format(time.RFC3339Nano), got)
+		}
+	}
+
+	for _, name := range []string{"", "Go", "中文"} {
+		cursor := encodeCursor("name", WebData{ID: 1, Name: name})
+		if got := cursorValue(t, "name", false, cursor); got != name {
+			t.Errorf("cursor of name %q gives %v", name, got)
+		}
+	}
+}
+
+func TestCursorFilterRejects(t *testing.T) {
+	timeCursor := encodeCursor("updated", WebData{ID: 1, Updated: time.Now()})
+	nameCursor := encodeCursor("name", WebData{ID: 1, Name: "go"})
+	for _, tt := range []struct{ key, cursor string }{
+		{"name", timeCursor},
+		{"created", nameCursor},
+		{"created", "not base64!"},
+		{"created", "bm90IGpzb24"},
+	} {
+		if _, err := cursorFilter(tt.key, false, tt.cursor); !util.HaveErrorCode(err, codes.InvalidArgument) {
+			t.Errorf("cursorFilter(%s, %s) = %v, want InvalidArgument", tt.key, tt.cursor, err)
+		}
+	}
+}
+
+// TestListWebDataBoundary checks the next page starts at the exact time of
+// the last item, so nothing is skipped or repeated.
+func TestListWebDataBoundary(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("created desc", func(mt *mtest.T) {
+		old := WebDatadb
+		defer func() { WebDatadb = old }()
+		WebDatadb = mt.Coll
+
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		last := time.UnixMilli(1714521600123)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{"_id", 1}, {"n", 3}}),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"_id", 3}, {"created", last.Add(time.Second)}},
+				bson.D{{"_id", 2}, {"created", last}},
+				bson.D{{"_id", 1}, {"created", last}},
+			),
+		)
+		req := PageRequest{Sort: "created", Desc: true, Limit: 2}
+		page, err := ListWebData(bson.M{}, Viewer{}, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 2 || page.NextCursor == "" {
+			t.Fatalf("page of %d items, next cursor %q", len(page.Items), page.NextCursor)
+		}
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{"_id", 1}, {"n", 3}}),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{"_id", 1}, {"created", last}}),
+		)
+		req.Cursor = page.NextCursor
+		if _, err := ListWebData(bson.M{}, Viewer{}, req); err != nil {
+			t.Fatal(err)
+		}
+		events := mt.GetAllStartedEvents()
+		filter := events[len(events)-1].Command.Lookup("filter").String()
+		date := fmt.Sprintf(`{"$date":{"$numberLong":"%d"}}`, last.UnixMilli())
+		if !strings.Contains(filter, date) || !strings.Contains(filter, `"$lt": {"$numberInt":"2"}`) {
+			t.Errorf("next page filter %s, want created %s and _id before 2", filter, date)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// The functions here restore backups, they bypass the checks of the api.
+
+// ClearData deletes all web data, tags, categories and snapshot records.
+// The snapshot files are not deleted. IDs of new web data keep counting up.
+func ClearData(ctx context.Context) error {
+	for _, c := range []*mongo.Collection{WebDatadb, Tagdb, CategoryDb, Snapshotdb} {
+		if _, err := c.DeleteMany(ctx, bson.M{}); err != nil {
+			return util.Errorf("clear %s failed", c.Name()).WithCause(err)
+		}
+	}
+	return nil
+}
+
+// RestoreUser inserts user or updates the user of the same name. An empty
+// password keeps the password of an existing user, new users without one
+// can not log in until it is reset.
+func RestoreUser(ctx context.Context, user DBUser) (created bool, err error) {
+	set := bson.M{"name": user.Name, "role": user.Role, "heros": user.Heros}
+	setOnInsert := bson.M{"password": ""}
+	if user.Password != "" {
+		set["password"] = user.Password
+		setOnInsert = bson.M{}
+	}
+	update := bson.M{"$set": set}
+	if len(setOnInsert) > 0 {
+		update["$setOnInsert"] = setOnInsert
+	}
+	res, err := Userdb.UpdateOne(ctx, bson.M{"name": user.Name}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, util.Errorf("restore user %s failed", user.Name).WithCause(err)
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// DeleteUsersExcept deletes the users not named in keep.
+func DeleteUsersExcept(ctx context.Context, keep []string) (int, error) {
+	res, err := Userdb.DeleteMany(ctx, bson.M{"name": bson.M{"$nin": keep}})
+	if err != nil {
+		return 0, util.Errorf("delete users failed").WithCause(err)
+	}
+	return int(res.DeletedCount), nil
+}
+
+// InsertTag inserts tag with its order and category, without refs.
+func InsertTag(ctx context.Context, tag Tag) error {
+	tag.Ref = 0
+	if _, err := Tagdb.InsertOne(ctx, tag); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return util.Errorf("add Tag %s failed to exec.", tag.Name).WithCause(err).WithCode(codes.AlreadyExists)
+		}
+		return util.Errorf("add Tag %s failed to exec.", tag.Name).WithCause(err)
+	}
+	return nil
+}
+
+// RecountTagRefs sets the ref of every tag to the number of web data
+// having it.
+func RecountTagRefs(ctx context.Context) error {
+	cursor, err := WebDatadb.Aggregate(ctx, bson.A{
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return util.Errorf("count tag refs failed").WithCause(err)
+	}
+	var counts []struct {
+		Name  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return util.Errorf("count tag refs failed").WithCause(err)
+	}
+	if _, err := Tagdb.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"ref": 0}}); err != nil {
+		return util.Errorf("reset tag refs failed").WithCause(err)
+	}
+	for _, c := range counts {
+		if _, err := Tagdb.UpdateOne(ctx, bson.M{"name": c.Name}, bson.M{"$set": bson.M{"ref": c.Count}}); err != nil {
+			return util.Errorf("set ref of tag %s failed", c.Name).WithCause(err)
+		}
+	}
+	return nil
+}
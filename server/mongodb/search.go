@@ -0,0 +1,121 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// webDataTextIndex is renamed whenever the weights change, so the old index
+// gets replaced. A collection can only have one text index.
+const webDataTextIndex = "webData_text_content"
+
+// webDataTextWeights ranks name matches above description and url matches,
+// and those above matches in the fetched page content.
+var webDataTextWeights = bson.D{
+	{Key: "name", Value: 10},
+	{Key: "description", Value: 5},
+	{Key: "url", Value: 2},
+	{Key: "content", Value: 1},
+}
+
+// ScoredWebData is a full text search result.
+type ScoredWebData struct {
+	WebData `bson:",inline"`
+	Score   float64 `bson:"score" json:"score"`
+	// Content is the fetched page text, only used for highlighting.
+	Content string `bson:"content,omitempty" json:"-"`
+}
+
+// initTextIndex creates the text index. mongodb keeps it in sync with every
+// insert, update and delete.
+func initTextIndex() {
+	dropStaleTextIndex()
+
+	keys := bson.D{}
+	for _, w := range webDataTextWeights {
+		keys = append(keys, bson.E{Key: w.Key, Value: "text"})
+	}
+	indexModel := mongo.IndexModel{
+		Keys: keys,
+		Options: options.Index().
+			SetName(webDataTextIndex).
+			SetWeights(webDataTextWeights).
+			// no stemming or stop words. Words are split at spaces and
+			// punctuation only, so chinese text without spaces is one word,
+			// found by the whole run and not by a part of it.
+			SetDefaultLanguage("none"),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := WebDatadb.Indexes().CreateOne(ctx, indexModel); err != nil {
+		logrus.Errorf("create text index for webData err: %v", err)
+	}
+}
+
+func dropStaleTextIndex() {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	cursor, err := WebDatadb.Indexes().List(ctx)
+	if err != nil {
+		logrus.Errorf("list webData indexes err: %v", err)
+		return
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		logrus.Errorf("list webData indexes err: %v", err)
+		return
+	}
+	for _, index := range indexes {
+		name, _ := index["name"].(string)
+		// text indexes are keyed by _fts
+		key, _ := index["key"].(bson.M)
+		if _, ok := key["_fts"]; !ok || name == webDataTextIndex {
+			continue
+		}
+		if _, err := WebDatadb.Indexes().DropOne(ctx, name); err != nil {
+			logrus.Errorf("drop text index %s of webData err: %v", name, err)
+		} else {
+			logrus.Infof("drop text index %s of webData", name)
+		}
+	}
+}
+
+// SearchWebData returns the web data visible to viewer matching text and
+// filter, best match first.
+func SearchWebData(text string, filter bson.M, viewer Viewer, limit, offset int) ([]ScoredWebData, int64, error) {
+	query := bson.M{"$text": bson.M{"$search": text}}
+	if len(filter) > 0 {
+		query["$and"] = bson.A{filter}
+	}
+	query = withViewer(query, viewer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	total, err := WebDatadb.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, util.Errorf("search WebData %s failed", text).WithCause(err)
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := WebDatadb.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, util.Errorf("search WebData %s failed", text).WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	datas := []ScoredWebData{}
+	if err := cursor.All(ctx, &datas); err != nil {
+		return nil, 0, util.Errorf("search WebData %s failed", text).WithCause(err)
+	}
+	return datas, total, nil
+}
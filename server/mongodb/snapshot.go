@@ -0,0 +1,161 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// Snapshot is an archived copy of the page of web data. The html itself is
+// kept in a blob store under Key.
+type Snapshot struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebDataID int                `bson:"webDataId" json:"webDataId"`
+	// Owner is the owner of the web data, whose quota the snapshot uses.
+	Owner   string    `json:"owner"`
+	URL     string    `bson:"url" json:"url"`
+	Created time.Time `json:"created"`
+	// Status is one of the Fetch* statuses.
+	Status string `json:"status"`
+	Error  string `bson:",omitempty" json:"error,omitempty"`
+	Size   int64  `json:"size"`
+	Key    string `json:"-"`
+}
+
+var Snapshotdb *mongo.Collection
+
+func init() {
+	registerDBData(Snapshot{})
+}
+
+func (Snapshot) initTable() {
+	Snapshotdb = db.Collection("snapshot")
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	for _, keys := range []bson.D{
+		{{Key: "webDataId", Value: 1}, {Key: "created", Value: -1}},
+		{{Key: "owner", Value: 1}, {Key: "created", Value: 1}},
+	} {
+		if _, err := Snapshotdb.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys}); err != nil {
+			logrus.Errorf("create index for snapshot err: %v", err)
+		}
+	}
+}
+
+func AddSnapshot(snapshot Snapshot) (Snapshot, error) {
+	if snapshot.ID.IsZero() {
+		snapshot.ID = primitive.NewObjectID()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := Snapshotdb.InsertOne(ctx, snapshot); err != nil {
+		return snapshot, util.Errorf("add snapshot of WebData %d failed", snapshot.WebDataID).WithCause(err)
+	}
+	return snapshot, nil
+}
+
+// FinishSnapshot stores the outcome of taking snapshot id. It fails with
+// codes.NotFound when the snapshot was deleted meanwhile.
+func FinishSnapshot(id primitive.ObjectID, status, errMsg string, size int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	set := bson.M{"status": status, "error": errMsg, "size": size}
+	res, err := Snapshotdb.UpdateByID(ctx, id, bson.M{"$set": set})
+	if err != nil {
+		return util.Errorf("finish snapshot %s failed", id.Hex()).WithCause(err)
+	}
+	if res.MatchedCount == 0 {
+		return util.Errorf("snapshot %s not found", id.Hex()).WithCode(codes.NotFound)
+	}
+	return nil
+}
+
+// FailPendingSnapshot marks snapshot id failed with errMsg unless it is
+// finished, and tells whether it was still pending.
+func FailPendingSnapshot(id primitive.ObjectID, errMsg string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	set := bson.M{"status": FetchFailed, "error": errMsg, "size": 0}
+	res, err := Snapshotdb.UpdateOne(ctx, bson.M{"_id": id, "status": FetchPending}, bson.M{"$set": set})
+	if err != nil {
+		return false, util.Errorf("fail snapshot %s failed", id.Hex()).WithCause(err)
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// CountPendingSnapshots counts the snapshots of owner still being taken.
+func CountPendingSnapshots(owner string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	n, err := Snapshotdb.CountDocuments(ctx, bson.M{"owner": owner, "status": FetchPending})
+	if err != nil {
+		return 0, util.Errorf("count pending snapshots of %s failed", owner).WithCause(err)
+	}
+	return n, nil
+}
+
+// GetStaleSnapshots returns the snapshots pending since before.
+func GetStaleSnapshots(before time.Time) ([]Snapshot, error) {
+	return findSnapshots(bson.M{"status": FetchPending, "created": bson.M{"$lt": before}}, options.Find())
+}
+
+// GetSnapshots returns the snapshots of web data id, newest first.
+func GetSnapshots(webDataID int) ([]Snapshot, error) {
+	return findSnapshots(bson.M{"webDataId": webDataID}, options.Find().SetSort(bson.D{{Key: "created", Value: -1}}))
+}
+
+// GetOwnerSnapshots returns the snapshots of owner, oldest first.
+func GetOwnerSnapshots(owner string) ([]Snapshot, error) {
+	return findSnapshots(bson.M{"owner": owner}, options.Find().SetSort(bson.D{{Key: "created", Value: 1}}))
+}
+
+func findSnapshots(filter bson.M, opts *options.FindOptions) ([]Snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	cursor, err := Snapshotdb.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, util.Errorf("get snapshots failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	snapshots := []Snapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, util.Errorf("get snapshots failed").WithCause(err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot returns snapshot id of web data webDataID.
+func GetSnapshot(webDataID int, id string) (Snapshot, error) {
+	var snapshot Snapshot
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return snapshot, util.Errorf("invalid snapshot id %s", id).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	err = Snapshotdb.FindOne(ctx, bson.M{"_id": oid, "webDataId": webDataID}).Decode(&snapshot)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return snapshot, util.Errorf("snapshot %s not found", id).WithCause(err).WithCode(codes.NotFound)
+		}
+		return snapshot, util.Errorf("get snapshot %s failed", id).WithCause(err)
+	}
+	return snapshot, nil
+}
+
+func DeleteSnapshot(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	if _, err := Snapshotdb.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return util.Errorf("delete snapshot %s failed", id.Hex()).WithCause(err)
+	}
+	return nil
+}
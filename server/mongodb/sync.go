@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"context"
+	"server/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+// SyncState is the bookmark document a user's sync client got last, the
+// base its uploaded changes are compared with.
+type SyncState struct {
+	Owner   string     `bson:"_id"`
+	ETag    string     `bson:"etag"`
+	Items   []SyncItem `bson:"items"`
+	Updated time.Time  `bson:"updated"`
+}
+
+// SyncItem is a bookmark of a synced document.
+type SyncItem struct {
+	ID          int      `bson:"id"`
+	Url         string   `bson:"url"`
+	Name        string   `bson:"name"`
+	Description string   `bson:"description,omitempty"`
+	Path        []string `bson:"path,omitempty"`
+}
+
+var Syncdb *mongo.Collection
+
+func init() {
+	registerDBData(SyncState{})
+}
+
+func (SyncState) initTable() {
+	Syncdb = db.Collection("sync")
+}
+
+// GetSyncState returns the sync state of owner, codes.NotFound before the
+// first sync.
+func GetSyncState(owner string) (SyncState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	var state SyncState
+	err := Syncdb.FindOne(ctx, bson.M{"_id": owner}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return state, util.Errorf("no sync state of %s", owner).WithCode(codes.NotFound)
+	}
+	if err != nil {
+		return state, util.Errorf("get sync state of %s failed", owner).WithCause(err)
+	}
+	return state, nil
+}
+
+func SaveSyncState(state SyncState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	state.Updated = time.Now()
+	_, err := Syncdb.ReplaceOne(ctx, bson.M{"_id": state.Owner}, state, options.Replace().SetUpsert(true))
+	if err != nil {
+		return util.Errorf("save sync state of %s failed", state.Owner).WithCause(err)
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package mongodb
 
 import (
 	"context"
-	"flag"
+	"server/config"
 	"server/util"
 
 	"github.com/sirupsen/logrus"
@@ -13,7 +13,7 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
-var tagIgnoreRef = flag.Bool("mongodb.tag.ignoreRef", true, "if ref abort delete tag")
+var tagIgnoreRef = &config.Current.Mongodb.TagIgnoreRef
 
 var Tagdb *mongo.Collection
 
@@ -52,7 +52,7 @@ func AddTag(data Tag) error {
 	tags, _ := GetAllTags(bson.M{})
 	data.Ref = 0
 	data.Order = len(tags)
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	_, err := Tagdb.InsertOne(ctx, data)
 	if err != nil {
@@ -71,14 +71,14 @@ func DeleteTag(name string) error {
 	if *tagIgnoreRef {
 		filter = bson.M{"name": name}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 
 	result := Tag{}
 	err := Tagdb.FindOneAndDelete(ctx, filter).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return util.Errorf("Tag with name %s not found or Ref not 0", name)
+			return util.Errorf("Tag with name %s not found or Ref not 0", name).WithCode(codes.NotFound)
 		}
 		// 其他错误
 		return util.Errorf("delete Tag with name %s failed", name).WithCause(err)
@@ -89,7 +89,7 @@ func DeleteTag(name string) error {
 func GetTagByName(name string) (Tag, error) {
 	filter := bson.M{"name": name}
 	result := Tag{}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	err := Tagdb.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
@@ -103,7 +103,7 @@ func GetTagByName(name string) (Tag, error) {
 
 func GetAllTags(filter bson.M) ([]Tag, error) {
 	sortOptions := options.Find().SetSort(bson.D{{"order", 1}})
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	cursor, err := Tagdb.Find(ctx, filter, sortOptions)
 	if err != nil {
@@ -126,26 +126,52 @@ func IncTagRef(ctx context.Context, name string, num int) error {
 	err := Tagdb.FindOneAndUpdate(ctx, filter, update).Decode(&beforeData)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			util.Errorf("inc %s Tag failed", name).WithCause(err).WithCode(codes.NotFound)
+			return util.Errorf("inc %s Tag failed", name).WithCause(err).WithCode(codes.NotFound)
 		}
 		return util.Errorf("inc %s Tag failed", name).WithCause(err)
 	}
 	return nil
 }
 
-func UpdateTag(name string, tagData Tag) error {
-	filter := bson.M{"name": name}
-	update := bson.D{{"$set", tagData}}
-	if tagData.Category.IsZero() {
-		update = append(update, bson.E{"$unset", bson.M{"category": nil}})
-		// update = bson.D{{"$set", tagData}, {"$unset", bson.M{"category": nil}}
+// TagPatch holds the fields of a tag to change, nil fields are kept. Ref is
+// counted by the web data and never patched.
+type TagPatch struct {
+	Order *int
+	// Category moves the tag, the zero id moves it out of its category.
+	Category *primitive.ObjectID
+}
+
+func UpdateTag(name string, patch TagPatch) error {
+	set := bson.M{}
+	unset := bson.M{}
+	if patch.Order != nil {
+		set["order"] = *patch.Order
+	}
+	if patch.Category != nil {
+		if patch.Category.IsZero() {
+			unset["category"] = nil
+		} else {
+			set["category"] = *patch.Category
+		}
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{"$set", set})
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	if len(unset) > 0 {
+		update = append(update, bson.E{"$unset", unset})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	err := Tagdb.FindOneAndUpdate(ctx, filter, update).Err()
+	var err error
+	if len(update) == 0 {
+		err = Tagdb.FindOne(ctx, bson.M{"name": name}).Err()
+	} else {
+		err = Tagdb.FindOneAndUpdate(ctx, bson.M{"name": name}, update).Err()
+	}
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			util.Errorf("update %s Tag failed", name).WithCause(err).WithCode(codes.NotFound)
+			return util.Errorf("update %s Tag failed", name).WithCause(err).WithCode(codes.NotFound)
 		}
 		return util.Errorf("update %s Tag failed", name).WithCause(err)
 	}
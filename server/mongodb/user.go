@@ -12,7 +12,7 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
-var userdb *mongo.Collection
+var Userdb *mongo.Collection
 
 type DBUser struct {
 	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -33,9 +33,9 @@ func init() {
 }
 
 func (DBUser) initTable() {
-	userdb = db.Collection("user")
+	Userdb = db.Collection("user")
 	indexModel := mongo.IndexModel{Keys: bson.D{{"name", 1}}, Options: options.Index().SetUnique(true)}
-	indexName, err := userdb.Indexes().CreateOne(context.TODO(), indexModel)
+	indexName, err := Userdb.Indexes().CreateOne(context.TODO(), indexModel)
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -44,10 +44,13 @@ func (DBUser) initTable() {
 
 // 插入 user 表数据
 func AddUser(user UserPayload) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	res, err := userdb.InsertOne(ctx, user)
+	res, err := Userdb.InsertOne(ctx, user)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", util.Errorf("add user %s failed to exec.", user.Name).WithCause(err).WithCode(codes.AlreadyExists)
+		}
 		return "", util.Errorf("add user %s failed to exec.", user.Name).WithCause(err)
 	}
 	id := res.InsertedID.(primitive.ObjectID)
@@ -59,12 +62,12 @@ func AddUser(user UserPayload) (string, error) {
 func DeleteUserById(id string) error {
 	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		util.Errorf(err.Error())
+		return util.Errorf("invalid user id %s", id).WithCause(err).WithCode(codes.InvalidArgument)
 	}
 	filter := bson.M{"_id": objId}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	_, err = userdb.DeleteOne(ctx, filter)
+	_, err = Userdb.DeleteOne(ctx, filter)
 	if err != nil {
 		return util.Errorf("delete user with ID %s failed", id).WithCause(err)
 	}
@@ -72,9 +75,9 @@ func DeleteUserById(id string) error {
 }
 func DeleteUser(user DBUser) error {
 	filter := bson.M{"name": user.Name}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	result, err := userdb.DeleteOne(ctx, filter)
+	result, err := Userdb.DeleteOne(ctx, filter)
 	if err != nil {
 		return util.Errorf("delete user with ID %s failed", user.Name).WithCause(err)
 	}
@@ -89,14 +92,14 @@ func DeleteUser(user DBUser) error {
 func UpdateUser(user DBUser) error {
 	filter := bson.M{"name": user.Name}
 	update := bson.M{"$set": user}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	result, err := userdb.UpdateOne(ctx, filter, update)
+	result, err := Userdb.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return util.Errorf("update user %s failed", user.Name).WithCause(err)
 	}
-	if result.ModifiedCount == 0 {
-		return util.Errorf("update user %s failed", user.Name).WithCause(err).WithCode(codes.NotFound)
+	if result.MatchedCount == 0 {
+		return util.Errorf("update user %s failed", user.Name).WithCode(codes.NotFound)
 	}
 	return nil
 }
@@ -104,10 +107,13 @@ func UpdateUser(user DBUser) error {
 func GetUserByName(uname string) (DBUser, error) {
 	filter := bson.M{"name": uname}
 	result := DBUser{}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	err := userdb.FindOne(ctx, filter).Decode(&result)
+	err := Userdb.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get %s user failed", uname).WithCause(err).WithCode(codes.NotFound)
+		}
 		return result, util.Errorf("get %s user failed", uname).WithCause(err)
 	}
 	return result, nil
@@ -116,9 +122,9 @@ func GetUserByName(uname string) (DBUser, error) {
 // 查询 user 表数据
 func GetAllUsers() ([]DBUser, error) {
 	filter := bson.M{} // 空的过滤条件，匹配所有文档
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	cursor, err := userdb.Find(ctx, filter)
+	cursor, err := Userdb.Find(ctx, filter)
 	if err != nil {
 		return nil, util.Errorf("get all user failed").WithCause(err)
 	}
@@ -2,8 +2,10 @@ package mongodb
 
 import (
 	"context"
+	"server/config"
 	"server/util"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,15 +14,95 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+var webDataDefaultOwner = &config.Current.Mongodb.DefaultOwner
+
 var WebDatadb *mongo.Collection
 var WebDataNum int
 
+// visibility of web data
+const (
+	VisibilityPrivate = "private" // owner only
+	VisibilityTeam    = "team"    // every logged-in user
+	VisibilityPublic  = "public"  // anyone, also anonymous
+)
+
 type WebData struct {
 	ID          int `bson:"_id"`
 	Name        string
 	Url         string
+	Canonical   string `bson:"canonical,omitempty"` // normalized Url finding duplicates, Url is kept for display
 	Tags        []string
 	Description string
+	Owner       string
+	Visibility  string
+	Created     time.Time `bson:"created,omitempty"`
+	Updated     time.Time `bson:"updated,omitempty"`
+	// Fetch is the state of the page content fetch, the content itself is
+	// only stored for full text search.
+	Fetch *FetchInfo `bson:"fetch,omitempty"`
+	// Enrich is the status of filling Name, Description and Icon from the
+	// page, one of the Fetch* statuses.
+	Enrich string `bson:"enrich,omitempty"`
+	// Icon is the url of the cached favicon.
+	Icon string `bson:"icon,omitempty"`
+	// Link is written by the dead link checker.
+	Link *LinkStatus `bson:"link,omitempty"`
+}
+
+// Viewer is the caller reading or editing web data. Empty Name is anonymous.
+type Viewer struct {
+	Name string
+	Role util.RoleLevel
+}
+
+// filter limits queries to the web data the viewer may see.
+func (v Viewer) filter() bson.M {
+	if v.Name == "" {
+		return bson.M{"visibility": VisibilityPublic}
+	}
+	if v.Role >= util.RoleManager {
+		return bson.M{}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"visibility": bson.M{"$in": bson.A{VisibilityPublic, VisibilityTeam}}},
+		bson.M{"owner": v.Name},
+	}}
+}
+
+// CanSee reports whether data passes the filter of the viewer.
+func (v Viewer) CanSee(data WebData) bool {
+	switch {
+	case data.Visibility == VisibilityPublic:
+		return true
+	case v.Name == "":
+		return false
+	}
+	return data.Visibility == VisibilityTeam || v.CanEdit(data)
+}
+
+// CanEdit reports whether the viewer may patch or delete data.
+func (v Viewer) CanEdit(data WebData) bool {
+	if v.Name == "" {
+		return false
+	}
+	return v.Role >= util.RoleManager || data.Owner == v.Name
+}
+
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPrivate, VisibilityTeam, VisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// withViewer ands the visibility filter of viewer into filter.
+func withViewer(filter bson.M, viewer Viewer) bson.M {
+	vf := viewer.filter()
+	if len(vf) == 0 {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, vf}}
 }
 
 func init() {
@@ -30,23 +112,34 @@ func init() {
 func (WebData) initTable() {
 	WebDatadb = db.Collection("webData")
 
-	// 创建索引选项
-	indexOptions := options.Index().SetUnique(true)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
 
-	// 创建索引模型
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "url", Value: 1}},
-		Options: indexOptions,
+	// 迁移没有所有者的旧数据
+	res, err := WebDatadb.UpdateMany(ctx,
+		bson.M{"owner": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"owner": *webDataDefaultOwner, "visibility": VisibilityPublic}})
+	if err != nil {
+		logrus.Errorf("migrate webData owner err: %v", err)
+	} else if res.ModifiedCount > 0 {
+		logrus.Infof("migrate %d webData to owner %s", res.ModifiedCount, *webDataDefaultOwner)
 	}
-
-	// 创建唯一性索引
-	_, err := WebDatadb.Indexes().CreateOne(context.Background(), indexModel)
+	now := time.Now()
+	_, err = WebDatadb.UpdateMany(ctx,
+		bson.M{"created": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"created": now, "updated": now}})
 	if err != nil {
-		logrus.Errorf("create index for webData url name err: %v", err)
+		logrus.Errorf("migrate webData created time err: %v", err)
+	}
+	initTextIndex()
+	initCanonicalIndex()
+	for _, key := range []string{"name", "created", "updated"} {
+		_, err = WebDatadb.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: key, Value: 1}, {Key: "_id", Value: 1}}})
+		if err != nil {
+			logrus.Errorf("create index for webData %s err: %v", key, err)
+		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
-	defer cancel()
 	// 创建一个排序条件，按降序排列
 	sort := bson.D{{"_id", -1}}
 	// 设置查询选项，仅返回一条文档
@@ -67,7 +160,7 @@ func (WebData) initTable() {
 func AddWebData(data WebData) (num int, err error) {
 	// session, err := WebDatadb.Database().Client().StartSession()
 	// defer session.EndSession(context.Background())
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	// sctx := mongo.NewSessionContext(ctx, session)
 	// if err = session.StartTransaction(); err != nil {
@@ -83,6 +176,14 @@ func AddWebData(data WebData) (num int, err error) {
 
 	WebDataNum++
 	data.ID = WebDataNum
+	// imports and restores keep the times of the bookmark
+	now := time.Now()
+	if data.Created.IsZero() || data.Created.After(now) {
+		data.Created = now
+	}
+	if data.Updated.Before(data.Created) || data.Updated.After(now) {
+		data.Updated = data.Created
+	}
 	res, err := WebDatadb.InsertOne(ctx, data)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
@@ -104,7 +205,7 @@ func AddWebData(data WebData) (num int, err error) {
 func DeleteWebData(ID int) (err error) {
 	// session, err := WebDatadb.Database().Client().StartSession()
 	// defer session.EndSession(context.Background())
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	// sctx := mongo.NewSessionContext(ctx, session)
 	// if err = session.StartTransaction(); err != nil {
@@ -145,7 +246,7 @@ func DeleteWebData(ID int) (err error) {
 func UpdateWebData(data WebData) (err error) {
 	// session, err := WebDatadb.Database().Client().StartSession()
 	// defer session.EndSession(context.Background())
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	// sctx := mongo.NewSessionContext(ctx, session)
 	// if err = session.StartTransaction(); err != nil {
@@ -160,6 +261,9 @@ func UpdateWebData(data WebData) (err error) {
 	// }()
 
 	filter := bson.M{"_id": data.ID}
+	// created is omitted while zero and so kept
+	data.Created = time.Time{}
+	data.Updated = time.Now()
 	update := bson.M{"$set": data}
 	var originData WebData
 	err = WebDatadb.FindOneAndUpdate(ctx, filter, update).Decode(&originData)
@@ -206,23 +310,45 @@ func UpdateWebData(data WebData) (err error) {
 	return nil
 }
 
-func GetWebDataByName(name string) (WebData, error) {
-	filter := bson.M{"name": name}
+func GetWebDataByID(ID int, viewer Viewer) (WebData, error) {
+	filter := withViewer(bson.M{"_id": ID}, viewer)
+	result := WebData{}
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
+	defer cancel()
+	err := WebDatadb.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get WebData with ID %d failed", ID).WithCause(err).WithCode(codes.NotFound)
+		}
+		return result, util.Errorf("get WebData with ID %d failed", ID).WithCause(err)
+	}
+	return result, nil
+}
+
+func GetWebDataByName(name string, viewer Viewer) (WebData, error) {
+	filter := withViewer(bson.M{"name": name}, viewer)
 	result := WebData{}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
 	err := WebDatadb.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return result, util.Errorf("get %s WebData failed", name).WithCause(err).WithCode(codes.NotFound)
+		}
 		return result, util.Errorf("get %s WebData failed", name).WithCause(err)
 	}
 	return result, nil
 }
 
-func GetWebDataByTags(tags []string) ([]WebData, error) {
-	filter := bson.M{"tags": bson.M{"$all": tags}}
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeoutTime)
+// GetWebDataByTags returns at most limit web data visible to viewer having
+// all tags, in id order after skipping offset of them.
+func GetWebDataByTags(tags []string, viewer Viewer, offset, limit int) ([]WebData, error) {
+	filter := withViewer(bson.M{"tags": bson.M{"$all": tags}}, viewer)
+	ctx, cancel := context.WithTimeout(context.Background(), *dbTimeout)
 	defer cancel()
-	cursor, err := WebDatadb.Find(ctx, filter)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).SetLimit(int64(limit)).SetProjection(withoutContent)
+	cursor, err := WebDatadb.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, util.Errorf("get %s WebData failed", strings.Join(tags, ",")).WithCause(err)
 	}
@@ -234,3 +360,34 @@ func GetWebDataByTags(tags []string) ([]WebData, error) {
 	}
 	return datas, nil
 }
+
+// EachWebDataByTags calls fn for every web data having all tags, reading the
+// cursor lazily so large results are never held in memory.
+func EachWebDataByTags(ctx context.Context, tags []string, viewer Viewer, fn func(WebData) error) error {
+	return EachWebData(ctx, bson.M{"tags": bson.M{"$all": tags}}, viewer, fn)
+}
+
+// EachWebData calls fn for every web data visible to viewer matching filter,
+// in ID order and without page content.
+func EachWebData(ctx context.Context, filter bson.M, viewer Viewer, fn func(WebData) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetProjection(withoutContent)
+	cursor, err := WebDatadb.Find(ctx, withViewer(filter, viewer), opts)
+	if err != nil {
+		return util.Errorf("get WebData failed").WithCause(err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var data WebData
+		if err := cursor.Decode(&data); err != nil {
+			return util.Errorf("decode WebData failed").WithCause(err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return util.Errorf("get WebData failed").WithCause(err)
+	}
+	return nil
+}
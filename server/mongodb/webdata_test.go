@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"strings"
+	"testing"
+
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// viewers are the callers the visibility tests read and edit as. A team
+// member is every logged-in user, bob and carol only differ in name.
+var viewers = map[string]Viewer{
+	"anonymous":    {},
+	"another user": {Name: "bob", Role: util.RolePlayer},
+	"team member":  {Name: "carol", Role: util.RolePlayer},
+	"owner":        {Name: "alice", Role: util.RolePlayer},
+	"manager":      {Name: "dave", Role: util.RoleManager},
+}
+
+// matches evaluates the visibility filter of a viewer against data, it only
+// knows the operators Viewer.filter uses.
+func matches(t *testing.T, filter bson.M, data WebData) bool {
+	t.Helper()
+	field := func(value any, got string) bool {
+		if in, ok := value.(bson.M); ok {
+			for _, v := range in["$in"].(bson.A) {
+				if v == got {
+					return true
+				}
+			}
+			return false
+		}
+		return value == got
+	}
+	for key, value := range filter {
+		switch key {
+		case "$or":
+			found := false
+			for _, f := range value.(bson.A) {
+				found = found || matches(t, f.(bson.M), data)
+			}
+			if !found {
+				return false
+			}
+		case "visibility":
+			if !field(value, data.Visibility) {
+				return false
+			}
+		case "owner":
+			if !field(value, data.Owner) {
+				return false
+			}
+		default:
+			t.Fatalf("unknown filter key %s", key)
+		}
+	}
+	return true
+}
+
+func TestViewerVisibility(t *testing.T) {
+	type access struct{ private, team, public bool }
+	for name, tt := range map[string]struct{ see, edit access }{
+		"anonymous":    {see: access{false, false, true}, edit: access{false, false, false}},
+		"another user": {see: access{false, true, true}, edit: access{false, false, false}},
+		"team member":  {see: access{false, true, true}, edit: access{false, false, false}},
+		"owner":        {see: access{true, true, true}, edit: access{true, true, true}},
+		"manager":      {see: access{true, true, true}, edit: access{true, true, true}},
+	} {
+		viewer := viewers[name]
+		for visibility, want := range map[string][2]bool{
+			VisibilityPrivate: {tt.see.private, tt.edit.private},
+			VisibilityTeam:    {tt.see.team, tt.edit.team},
+			VisibilityPublic:  {tt.see.public, tt.edit.public},
+		} {
+			data := WebData{ID: 1, Owner: "alice", Visibility: visibility}
+			if got := matches(t, viewer.filter(), data); got != want[0] {
+				t.Errorf("%s filter on %s data = %v, want %v", name, visibility, got, want[0])
+			}
+			if got := viewer.CanSee(data); got != want[0] {
+				t.Errorf("%s CanSee %s data = %v, want %v", name, visibility, got, want[0])
+			}
+			if got := viewer.CanEdit(data); got != want[1] {
+				t.Errorf("%s CanEdit %s data = %v, want %v", name, visibility, got, want[1])
+			}
+		}
+	}
+}
+
+// TestGetWebDataByIDFilter checks reads by id send the filter of the viewer.
+func TestGetWebDataByIDFilter(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for name, want := range map[string]string{
+		"anonymous":    `{"visibility": "public"}`,
+		"another user": `{"owner": "bob"}`,
+		"manager":      "",
+	} {
+		mt.Run(name, func(mt *mtest.T) {
+			old := WebDatadb
+			defer func() { WebDatadb = old }()
+			WebDatadb = mt.Coll
+
+			ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch))
+			GetWebDataByID(1, viewers[name])
+			filter := mt.GetStartedEvent().Command.Lookup("filter").String()
+			if want == "" && strings.Contains(filter, "$and") {
+				t.Errorf("filter %s, want no visibility filter", filter)
+			}
+			if want != "" && !strings.Contains(filter, want) {
+				t.Errorf("filter %s, want %s", filter, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package outbound makes http requests to user supplied urls without letting
+// them reach the server's own network. Destinations are checked after DNS
+// resolution and on every redirect, so neither private hostnames nor
+// redirects into private ranges get through.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"server/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	allowList    = &config.Current.Outbound.Allow
+	maxRedirects = &config.Current.Outbound.MaxRedirects
+)
+
+// blockedNets are loopback, private, link-local and other ranges that are
+// not on the public internet.
+var blockedNets = parseCIDRs(
+	"0.0.0.0/8",       // this network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier grade nat
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local, cloud metadata
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // nat64 of ipv4, may embed private addresses
+	"100::/64",        // discard
+	"2001:db8::/32",   // documentation
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// BlockedError is returned when a destination is not allowed.
+type BlockedError struct {
+	Host string
+	IP   net.IP
+}
+
+func (e *BlockedError) Error() string {
+	if e.IP == nil {
+		return fmt.Sprintf("destination %s is not allowed", e.Host)
+	}
+	return fmt.Sprintf("destination %s (%s) is not allowed", e.Host, e.IP)
+}
+
+// Policy decides which destinations outbound requests may reach.
+type Policy struct {
+	hosts map[string]bool
+	nets  []*net.IPNet
+}
+
+// ParsePolicy parses a comma separated allowlist of hosts, ips and cidrs
+// that may be reached although they are in a blocked range.
+func ParsePolicy(allow string) (*Policy, error) {
+	p := &Policy{hosts: map[string]bool{}}
+	for _, entry := range strings.Split(allow, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			_, n, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed cidr %q: %w", entry, err)
+			}
+			p.nets = append(p.nets, n)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		default:
+			p.hosts[entry] = true
+		}
+	}
+	return p, nil
+}
+
+var (
+	defaultPolicy     *Policy
+	defaultPolicyErr  error
+	defaultPolicyOnce sync.Once
+)
+
+// DefaultPolicy is the policy of the -outbound.allow flag.
+func DefaultPolicy() (*Policy, error) {
+	defaultPolicyOnce.Do(func() {
+		defaultPolicy, defaultPolicyErr = ParsePolicy(*allowList)
+	})
+	return defaultPolicy, defaultPolicyErr
+}
+
+// Allowed reports whether ip may be reached.
+func (p *Policy) Allowed(ip net.IP) bool {
+	for _, n := range p.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		// also catches ipv4 mapped ipv6 addresses like ::ffff:127.0.0.1
+		ip = ip4
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dial resolves host itself and connects to an allowed address of it, so the
+// checked address is the one connected to.
+func (p *Policy) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if p.hosts[strings.ToLower(host)] {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	var lastErr error = &BlockedError{Host: host}
+	for _, ip := range ips {
+		if !p.Allowed(ip) {
+			lastErr = &BlockedError{Host: host, IP: ip}
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// ErrTooManyRedirects is returned after following maxRedirects redirects.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// NewClient returns a client only reaching destinations allowed by policy.
+// Environment proxies are ignored, they would hide the real destination.
+func NewClient(timeout time.Duration, policy *Policy) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return policy.dial(ctx, dialer, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: timeout,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > *maxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/util"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestAllowed(t *testing.T) {
+	policy, err := ParsePolicy("10.1.0.0/16, 192.168.1.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.0.0.1":         false,
+		"172.20.1.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"10.1.2.3":         true, // allowlisted cidr
+		"192.168.1.7":      true, // allowlisted ip
+	} {
+		if got := policy.Allowed(net.ParseIP(ip)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	if _, err := ParsePolicy("10.0.0.0/33"); err == nil {
+		t.Error("ParsePolicy accepted an invalid cidr")
+	}
+}
+
+func newListener(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetch(policy string, url string, req Request) (*Response, error) {
+	p, err := ParsePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = url
+	return Fetch(context.Background(), NewClient(time.Second, p), req)
+}
+
+func TestFetchBlocksLocalListeners(t *testing.T) {
+	server := newListener(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "internal")
+	})
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	for _, url := range []string{
+		server.URL,                 // 127.0.0.1
+		"http://localhost:" + port, // resolved to loopback
+		"http://[::ffff:127.0.0.1]:" + port,
+	} {
+		if _, err := fetch("", url, Request{}); !util.HaveErrorCode(err, codes.PermissionDenied) {
+			t.Errorf("fetch %s: got %v, want PermissionDenied", url, err)
+		}
+	}
+
+	resp, err := fetch("127.0.0.1", server.URL, Request{})
+	if err != nil {
+		t.Fatalf("fetch allowlisted listener: %v", err)
+	}
+	if string(resp.Body) != "internal" {
+		t.Errorf("Body = %q", resp.Body)
+	}
+
+	if _, err := fetch("", "file:///etc/passwd", Request{}); !util.HaveErrorCode(err, codes.InvalidArgument) {
+		t.Errorf("fetch file url: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestFetchChecksRedirects(t *testing.T) {
+	var hidden *httptest.Server
+	server := newListener(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			// 127.0.0.2 is loopback too, but not allowlisted
+			http.Redirect(w, r, strings.Replace(hidden.URL, "127.0.0.1", "127.0.0.2", 1), http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		}
+	})
+	hidden = newListener(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	})
+
+	if _, err := fetch("127.0.0.1", server.URL+"/private", Request{}); !util.HaveErrorCode(err, codes.PermissionDenied) {
+		t.Errorf("redirect to private address: got %v, want PermissionDenied", err)
+	}
+	if _, err := fetch("127.0.0.1", server.URL+"/loop", Request{}); !util.HaveErrorCode(err, codes.FailedPrecondition) {
+		t.Errorf("redirect loop: got %v, want FailedPrecondition", err)
+	}
+	if _, err := fetch("127.0.0.1", server.URL+"/file", Request{}); err == nil {
+		t.Error("followed a redirect to a file url")
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	server := newListener(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, strings.Repeat("a", 4096))
+		case "/video":
+			w.Header().Set("Content-Type", "video/mp4")
+			fmt.Fprint(w, "frames")
+		}
+	})
+
+	resp, err := fetch("127.0.0.1", server.URL+"/big", Request{MaxSize: 1000, ContentTypes: []string{"text/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Body) != 1000 || !resp.Truncated || resp.ContentType != "text/html" {
+		t.Errorf("got %d bytes, Truncated %v, ContentType %q", len(resp.Body), resp.Truncated, resp.ContentType)
+	}
+
+	resp, err = fetch("127.0.0.1", server.URL+"/video", Request{ContentTypes: []string{"text/html"}})
+	if !util.HaveErrorCode(err, codes.FailedPrecondition) {
+		t.Fatalf("fetch video: got %v, want FailedPrecondition", err)
+	}
+	if resp == nil || resp.ContentType != "video/mp4" || resp.Body != nil {
+		t.Errorf("want the response without body, got %+v", resp)
+	}
+}
@@ -0,0 +1,113 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"server/util"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Request is a request of URL with limits on what is read.
+type Request struct {
+	// Method is GET when empty.
+	Method    string
+	URL       string
+	UserAgent string
+	Accept    string
+	// MaxSize bytes of the body are read, longer bodies are cut.
+	MaxSize int64
+	// ContentTypes are the media types whose body is read, for example
+	// "text/html" or "image/*". Any type is read when empty.
+	ContentTypes []string
+}
+
+type Response struct {
+	URL         string // after redirects
+	StatusCode  int
+	ContentType string // media type without parameters
+	Header      http.Header
+	Body        []byte
+	Truncated   bool // the body was longer than MaxSize
+}
+
+// Fetch sends req with client. Unlike client.Do it only reads bodies of
+// allowed content types and sizes, and maps failures to util.Error codes:
+// codes.PermissionDenied for blocked destinations, codes.FailedPrecondition
+// for other content types, which still return the response without body,
+// and codes.Unavailable for network errors.
+func Fetch(ctx context.Context, client *http.Client, req Request) (*Response, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, util.Errorf("invalid url %s, only http and https are fetched", req.URL).WithCode(codes.InvalidArgument)
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL, nil)
+	if err != nil {
+		return nil, util.Errorf("invalid url %s", req.URL).WithCause(err).WithCode(codes.InvalidArgument)
+	}
+	if req.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", req.UserAgent)
+	}
+	if req.Accept != "" {
+		httpReq.Header.Set("Accept", req.Accept)
+	}
+
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		var blocked *BlockedError
+		switch {
+		case errors.As(err, &blocked):
+			return nil, util.Errorf("fetch %s failed: %s", req.URL, blocked.Error()).WithCode(codes.PermissionDenied)
+		case errors.Is(err, ErrTooManyRedirects):
+			return nil, util.Errorf("fetch %s failed: %s", req.URL, ErrTooManyRedirects.Error()).WithCode(codes.FailedPrecondition)
+		}
+		return nil, util.Errorf("fetch %s failed", req.URL).WithCause(err).WithCode(codes.Unavailable)
+	}
+	defer httpResp.Body.Close()
+
+	resp := &Response{
+		URL:        httpResp.Request.URL.String(),
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+	}
+	resp.ContentType, _, _ = mime.ParseMediaType(httpResp.Header.Get("Content-Type"))
+	if !matchContentType(resp.ContentType, req.ContentTypes) {
+		return resp, util.Errorf("unsupported content type %q of %s", resp.ContentType, req.URL).WithCode(codes.FailedPrecondition)
+	}
+
+	reader := io.Reader(httpResp.Body)
+	if req.MaxSize > 0 {
+		// one more byte tells a body over the limit apart
+		reader = io.LimitReader(httpResp.Body, req.MaxSize+1)
+	}
+	resp.Body, err = io.ReadAll(reader)
+	if err != nil {
+		return resp, util.Errorf("read %s failed", req.URL).WithCause(err).WithCode(codes.Unavailable)
+	}
+	if req.MaxSize > 0 && int64(len(resp.Body)) > req.MaxSize {
+		resp.Body = resp.Body[:req.MaxSize]
+		resp.Truncated = true
+	}
+	return resp, nil
+}
+
+func matchContentType(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
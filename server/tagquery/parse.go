@@ -0,0 +1,355 @@
+// Package tagquery parses boolean tag queries such as
+//
+//	(go | rust) & !deprecated & category:Languages
+//
+// into mongodb filters on the tags of web data. "&", "," and AND join terms,
+// "|" and OR pick alternatives, "!", "-" and NOT exclude, and parentheses
+// group. Tag and category names with spaces or operator characters can be
+// quoted, as in category:"Dev Tools".
+package tagquery
+
+import (
+	"fmt"
+	"server/util"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	maxQueryLen = 1000
+	maxDepth    = 32
+)
+
+// Expr is a parsed tag query.
+type Expr interface {
+	filter(resolve CategoryResolver) (bson.M, error)
+}
+
+// CategoryResolver returns the tag names of a category.
+type CategoryResolver func(category string) ([]string, error)
+
+type tagExpr struct{ name string }
+type categoryExpr struct{ name string }
+type notExpr struct{ sub Expr }
+type andExpr struct{ subs []Expr }
+type orExpr struct{ subs []Expr }
+
+func (e tagExpr) filter(CategoryResolver) (bson.M, error) {
+	return bson.M{"tags": e.name}, nil
+}
+
+func (e categoryExpr) filter(resolve CategoryResolver) (bson.M, error) {
+	tags, err := resolve(e.name)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"tags": bson.M{"$in": tags}}, nil
+}
+
+func (e notExpr) filter(resolve CategoryResolver) (bson.M, error) {
+	sub, err := e.sub.filter(resolve)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$nor": bson.A{sub}}, nil
+}
+
+func (e andExpr) filter(resolve CategoryResolver) (bson.M, error) {
+	subs, err := filters(e.subs, resolve)
+	return bson.M{"$and": subs}, err
+}
+
+func (e orExpr) filter(resolve CategoryResolver) (bson.M, error) {
+	subs, err := filters(e.subs, resolve)
+	return bson.M{"$or": subs}, err
+}
+
+func filters(exprs []Expr, resolve CategoryResolver) (bson.A, error) {
+	subs := bson.A{}
+	for _, e := range exprs {
+		f, err := e.filter(resolve)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, f)
+	}
+	return subs, nil
+}
+
+// Filter compiles expr into a mongodb filter. Tag names only ever appear as
+// values, so queries can not inject operators.
+func Filter(expr Expr, resolve CategoryResolver) (bson.M, error) {
+	return expr.filter(resolve)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTag
+	tokCategory
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // byte offset in the query
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Parse parses query, reporting syntax errors with their position.
+func Parse(query string) (Expr, error) {
+	if len(query) > maxQueryLen {
+		return nil, util.Errorf("tag query longer than %d bytes", maxQueryLen).WithCode(codes.InvalidArgument)
+	}
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{query: query, tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s, expected an operator", t)
+	}
+	return expr, nil
+}
+
+// Operators are the characters with a meaning in queries. Tag names must
+// not contain them, then any name quoted is one tag of a query.
+const Operators = "()&|!,\""
+
+func isOperator(r rune) bool {
+	return strings.ContainsRune(Operators, r)
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case r == '&' || r == ',':
+			tokens = append(tokens, token{tokAnd, string(r), i})
+			i++
+		case r == '|':
+			tokens = append(tokens, token{tokOr, "|", i})
+			i++
+		case r == '!':
+			tokens = append(tokens, token{tokNot, "!", i})
+			i++
+		case r == '-' && expectsOperand(tokens):
+			tokens = append(tokens, token{tokNot, "-", i})
+			i++
+		case r == '"':
+			text, end, err := quoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokTag, text, i})
+			i = end
+		default:
+			start := i
+			for i < len(query) {
+				r, size := utf8.DecodeRuneInString(query[i:])
+				if unicode.IsSpace(r) || isOperator(r) {
+					break
+				}
+				i += size
+			}
+			if isCategoryPrefix(query[start:i]) && i < len(query) && query[i] == '"' {
+				text, end, err := quoted(query, i)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{tokCategory, text, start})
+				i = end
+				continue
+			}
+			tokens = append(tokens, word(query[start:i], start))
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(query)}), nil
+}
+
+// quoted returns the text of the quote at i and the offset after it.
+func quoted(query string, i int) (string, int, error) {
+	end := strings.IndexByte(query[i+1:], '"')
+	if end < 0 {
+		return "", 0, syntaxError(query, i, "unterminated quote")
+	}
+	return query[i+1 : i+1+end], i + end + 2, nil
+}
+
+var categoryPrefixes = []string{"category:", "cat:"}
+
+// isCategoryPrefix reports whether text is a category prefix alone, a
+// quoted name follows.
+func isCategoryPrefix(text string) bool {
+	for _, prefix := range categoryPrefixes {
+		if strings.EqualFold(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectsOperand reports whether a "-" after tokens is a NOT rather than
+// part of a tag name.
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokTag, tokCategory, tokRParen:
+		return false
+	}
+	return true
+}
+
+// word turns a bare word into a keyword, category or tag token.
+func word(text string, pos int) token {
+	switch strings.ToUpper(text) {
+	case "AND":
+		return token{tokAnd, text, pos}
+	case "OR":
+		return token{tokOr, text, pos}
+	case "NOT":
+		return token{tokNot, text, pos}
+	}
+	for _, prefix := range categoryPrefixes {
+		if len(text) > len(prefix) && strings.EqualFold(text[:len(prefix)], prefix) {
+			return token{tokCategory, text[len(prefix):], pos}
+		}
+	}
+	return token{tokTag, text, pos}
+}
+
+type parser struct {
+	query  string
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	subs := []Expr{left}
+	for p.peek().kind == tokOr {
+		p.take()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, right)
+	}
+	if len(subs) == 1 {
+		return left, nil
+	}
+	return orExpr{subs}, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	subs := []Expr{left}
+	for p.peek().kind == tokAnd {
+		p.take()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, right)
+	}
+	if len(subs) == 1 {
+		return left, nil
+	}
+	return andExpr{subs}, nil
+}
+
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	if depth > maxDepth {
+		return nil, p.errorf(p.peek(), "query nested deeper than %d", maxDepth)
+	}
+	t := p.take()
+	switch t.kind {
+	case tokNot:
+		sub, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{sub}, nil
+	case tokLParen:
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "unexpected %s, expected \")\" to close \"(\" at column %d", closing, utf8.RuneCountInString(p.query[:t.pos])+1)
+		}
+		return expr, nil
+	case tokTag:
+		if t.text == "" {
+			return nil, p.errorf(t, "empty tag name")
+		}
+		return tagExpr{t.text}, nil
+	case tokCategory:
+		if t.text == "" {
+			return nil, p.errorf(t, "empty category name")
+		}
+		return categoryExpr{t.text}, nil
+	}
+	return nil, p.errorf(t, "unexpected %s, expected a tag, category: or \"(\"", t)
+}
+
+func (p *parser) errorf(t token, format string, a ...interface{}) error {
+	return syntaxError(p.query, t.pos, fmt.Sprintf(format, a...))
+}
+
+// syntaxError reports msg at the 1-based column of pos, for example
+//
+//	tag query syntax error at column 6: unexpected end of query, expected a tag, category: or "("
+func syntaxError(query string, pos int, msg string) error {
+	column := utf8.RuneCountInString(query[:pos]) + 1
+	return util.Errorf("tag query syntax error at column %d: %s", column, msg).WithCode(codes.InvalidArgument)
+}
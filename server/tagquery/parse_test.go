@@ -0,0 +1,123 @@
+package tagquery
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// show prints e as an s-expression.
+func show(e Expr) string {
+	list := func(op string, subs []Expr) string {
+		parts := []string{op}
+		for _, sub := range subs {
+			parts = append(parts, show(sub))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	}
+	switch e := e.(type) {
+	case tagExpr:
+		return fmt.Sprintf("%q", e.name)
+	case categoryExpr:
+		return fmt.Sprintf("cat:%q", e.name)
+	case notExpr:
+		return "(not " + show(e.sub) + ")"
+	case andExpr:
+		return list("and", e.subs)
+	case orExpr:
+		return list("or", e.subs)
+	}
+	return fmt.Sprintf("%#v", e)
+}
+
+func TestParse(t *testing.T) {
+	for query, want := range map[string]string{
+		`go`:                      `"go"`,
+		`go & rust | web`:         `(or (and "go" "rust") "web")`,
+		`go | rust & web`:         `(or "go" (and "rust" "web"))`,
+		`go, rust`:                `(and "go" "rust")`,
+		`go and rust OR web`:      `(or (and "go" "rust") "web")`,
+		`(go | rust) & web`:       `(and (or "go" "rust") "web")`,
+		`!go & -rust & NOT web`:   `(and (not "go") (not "rust") (not "web"))`,
+		`!!go`:                    `(not (not "go"))`,
+		`-(go | rust)`:            `(not (or "go" "rust"))`,
+		`go-lang & c-`:            `(and "go-lang" "c-")`,
+		`"c++" | "dev tools"`:     `(or "c++" "dev tools")`,
+		`"and"`:                   `"and"`,
+		`category:Languages`:      `cat:"Languages"`,
+		`CAT:Languages | go`:      `(or cat:"Languages" "go")`,
+		`category:"Dev Tools"`:    `cat:"Dev Tools"`,
+		`!cat:"a|b" & go`:         `(and (not cat:"a|b") "go")`,
+		`category:`:               `"category:"`,
+		`  go   &   rust  `:       `(and "go" "rust")`,
+		`标签 | "中文 标签"`:            `(or "标签" "中文 标签")`,
+		`(((go)))`:                `"go"`,
+		`category:"Dev Tools"&go`: `(and cat:"Dev Tools" "go")`,
+	} {
+		expr, err := Parse(query)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", query, err)
+			continue
+		}
+		if got := show(expr); got != want {
+			t.Errorf("Parse(%s) = %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for query, want := range map[string]string{
+		``:                   `column 1: unexpected end of query`,
+		`go &`:               `column 5: unexpected end of query`,
+		`go rust`:            `column 4: unexpected "rust", expected an operator`,
+		`(go | rust`:         `column 11: unexpected end of query, expected ")" to close "(" at column 1`,
+		`go)`:                `column 3: unexpected ")", expected an operator`,
+		`"go`:                `column 1: unterminated quote`,
+		`go | category:"Dev`: `column 15: unterminated quote`,
+		`category:""`:        `column 1: empty category name`,
+		`""`:                 `column 1: empty tag name`,
+		`标签 & | go`:          `column 6: unexpected "|"`,
+		`go -rust`:           `column 4: unexpected "-rust", expected an operator`,
+		strings.Repeat("(", maxDepth+2) + "go" + strings.Repeat(")", maxDepth+2): `nested deeper than 32`,
+		strings.Repeat("!", maxDepth+2) + "go":                                   `nested deeper than 32`,
+		strings.Repeat("a", maxQueryLen+1):                                       `longer than 1000 bytes`,
+	} {
+		_, err := Parse(query)
+		if err == nil {
+			t.Errorf("Parse(%.40s) accepted", query)
+			continue
+		}
+		if msg := util.Message(err); !strings.Contains(msg, want) {
+			t.Errorf("Parse(%.40s) = %s, want %s", query, msg, want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	expr, err := Parse(`go & !category:"Dev Tools"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(category string) ([]string, error) {
+		if category != "Dev Tools" {
+			t.Errorf("resolve(%s)", category)
+		}
+		return []string{"git", "vim"}, nil
+	}
+	got, err := Filter(expr, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"$and": bson.A{
+		bson.M{"tags": "go"},
+		bson.M{"$nor": bson.A{bson.M{"tags": bson.M{"$in": []string{"git", "vim"}}}}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+// Package urlnorm turns urls into a canonical form, so the same page saved
+// as http://X.com:80/a/ and https://x.com/a?utm_source=feed is detected as a
+// duplicate.
+package urlnorm
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters only used for analytics.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"ref_src": true,
+	"spm":     true,
+}
+
+func isTracking(param string) bool {
+	param = strings.ToLower(param)
+	return trackingParams[param] || strings.HasPrefix(param, "utm_")
+}
+
+// Normalize returns the canonical form of raw:
+//
+//   - http becomes https, urls without scheme are taken as https too
+//   - scheme and host are lower case, default ports and a trailing dot of
+//     the host are dropped
+//   - dot segments and a trailing slash of the path are removed
+//   - tracking parameters like utm_source are removed, the rest is sorted
+//   - the fragment is removed
+//
+// The canonical form only identifies a page, it is not meant to be opened.
+func Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	host, port := strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), u.Port()
+	if port == "80" || port == "443" {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+
+	u.Path = strings.TrimSuffix(cleanPath(u.Path), "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for param := range query {
+		if isTracking(param) {
+			delete(query, param)
+		}
+	}
+	// Encode sorts by key, sort the values of repeated keys too
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// cleanPath removes "." and ".." segments like a browser does.
+func cleanPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, s := range segments {
+		switch s {
+		case ".":
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, s)
+			continue
+		}
+		// a dot segment at the end leaves a directory
+		if i == len(segments)-1 {
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
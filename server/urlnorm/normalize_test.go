@@ -0,0 +1,35 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"https://x.com/a", "https://x.com/a"},
+		{"http://x.com/a", "https://x.com/a"},
+		{"https://x.com/a/", "https://x.com/a"},
+		{"https://x.com/a?utm_source=feed&utm_medium=rss", "https://x.com/a"},
+		{"HTTP://X.Com:80/a/#top", "https://x.com/a"},
+		{"https://x.com:443/", "https://x.com"},
+		{"https://x.com", "https://x.com"},
+		{"x.com/a", "https://x.com/a"},
+		{"https://x.com:8080/a", "https://x.com:8080/a"},
+		{"https://x.com./a/./b/../c", "https://x.com/a/c"},
+		{"https://x.com/a?b=2&a=1&fbclid=xyz", "https://x.com/a?a=1&b=2"},
+		{"https://x.com/a?q=2&q=1", "https://x.com/a?q=1&q=2"},
+		{"https://user:pw@x.com/a?", "https://x.com/a"},
+		{"https://[::1]:443/a", "https://[::1]/a"},
+		{"https://x.com/A%20b", "https://x.com/A%20b"},
+	} {
+		got, err := Normalize(c.in)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Normalize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	if _, err := Normalize("https://x.com/%zz"); err == nil {
+		t.Error("Normalize accepted an invalid escape")
+	}
+}
@@ -0,0 +1,62 @@
+package usersys
+
+import (
+	"server/mongodb"
+	"server/util"
+
+	"google.golang.org/grpc/codes"
+)
+
+// User administration of the server binary, it runs without a request so
+// there is no viewer to check.
+
+// CreateAdmin registers an admin, or makes an existing user admin with a new
+// password. created tells which one happened.
+func CreateAdmin(username string, password string) (created bool, err error) {
+	if username == "" || password == "" {
+		return false, util.Errorf("Invalid username or password").WithCode(codes.InvalidArgument)
+	}
+	dbu, err := mongodb.GetUserByName(username)
+	if util.HaveErrorCode(err, codes.NotFound) {
+		_, err = mongodb.AddUser(mongodb.UserPayload{
+			Name:     username,
+			Password: password,
+			Heros:    []int{},
+			Role:     int(util.RoleAdmin),
+		})
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	dbu.Password = password
+	dbu.Role = int(util.RoleAdmin)
+	return false, mongodb.UpdateUser(dbu)
+}
+
+// ResetPassword sets the password of an existing user.
+func ResetPassword(username string, password string) error {
+	if password == "" {
+		return util.Errorf("Invalid password").WithCode(codes.InvalidArgument)
+	}
+	dbu, err := mongodb.GetUserByName(username)
+	if err != nil {
+		return err
+	}
+	dbu.Password = password
+	return mongodb.UpdateUser(dbu)
+}
+
+// HaveAdmin tells whether any user is admin.
+func HaveAdmin() (bool, error) {
+	users, err := mongodb.GetAllUsers()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if util.RoleLevel(u.Role) == util.RoleAdmin {
+			return true, nil
+		}
+	}
+	return false, nil
+}
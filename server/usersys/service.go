@@ -1,7 +1,6 @@
 package usersys
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"server/mongodb"
@@ -24,37 +23,25 @@ func HandleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request authRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "无法解析请求体", http.StatusBadRequest)
+	if err := util.DecodeBody(w, r, &request); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	logrus.Infof("register:%v", request)
+	logrus.Infof("register: %s", request.Username)
 
 	if err := Register(request.Username, request.Password); err != nil {
-		if util.HaveErrorCode(err, codes.InvalidArgument) {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, err.Error())
-			return
-		}
-		if util.HaveErrorCode(err, codes.AlreadyExists) {
-			w.WriteHeader(http.StatusConflict)
-			fmt.Fprint(w, err.Error())
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
 	if err := util.AddSession(w, r, request.Username, util.RolePlayer); err != nil {
-		util.Errorf("save session error:%s", request.Username).WithCause(err).Log()
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, util.Errorf("save session error:%s", request.Username).WithCause(err))
 		return
 	}
 
 	user, err := getUser(request.Username)
 	if err != nil {
-		fmt.Fprint(w, util.Errorf("register got some internal error.").WithCause(err))
+		util.WriteError(w, r, util.Errorf("register got some internal error.").WithCause(err).WithCode(codes.Internal))
 		return
 	}
 
@@ -69,32 +56,20 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request authRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "无法解析请求体", http.StatusBadRequest)
+	if err := util.DecodeBody(w, r, &request); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	logrus.Infof("login:%v", request)
+	logrus.Infof("login: %s", request.Username)
 
 	user, err := Login(request.Username, request.Password)
 	if err != nil {
-		if util.HaveErrorCode(err, codes.InvalidArgument) {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, err.Error())
-			return
-		}
-		if util.HaveErrorCode(err, codes.NotFound) {
-			w.WriteHeader(http.StatusNotFound)
-			fmt.Fprint(w, err.Error())
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 
 	if err := util.AddSession(w, r, user.Name, user.Role); err != nil {
-		util.Errorf("save session error:%s.", request.Username).WithCause(err).Log()
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, util.Errorf("save session error:%s.", request.Username).WithCause(err))
 		return
 	}
 
@@ -110,9 +85,7 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := util.RemoveSession(w, r); err != nil {
-		util.Errorf("remove session error").WithCause(err).Log()
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, util.Errorf("remove session error").WithCause(err))
 		return
 	}
 
@@ -124,10 +97,8 @@ func HandleGetAuth(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	if user, role, err := util.GetUser(w, r); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		resp := map[string]any{"success": false, "msg": "not authorized"}
-		fmt.Fprint(w, util.EncodeJson(resp))
+	if user, role, err := util.GetUser(r); err != nil {
+		util.WriteError(w, r, err)
 	} else {
 		logrus.Println("get auth:", user, role)
 		role := func() string {
@@ -144,76 +115,147 @@ func HandleGetAuth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleCreateToken returns an api token of the logged in user, for
+// clients like browser extensions that send it as bearer token.
+func HandleCreateToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, role, err := util.GetUser(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(map[string]string{"token": util.NewToken(user, role)}))
+}
+
+// requireAdmin returns the viewer of r when it is admin.
+func requireAdmin(r *http.Request) (string, error) {
+	user, role, err := util.GetUser(r)
+	if err != nil {
+		return "", err
+	}
+	if role < util.RoleAdmin {
+		return "", util.Errorf("admin role required").WithCode(codes.PermissionDenied)
+	}
+	return user, nil
+}
+
+// HandleGetUsers lists the users for an admin.
 func HandleGetUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	if _, err := requireAdmin(r); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
 
 	users, err := mongodb.GetAllUsers()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+		util.WriteError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, util.EncodeJson(users))
 }
 
+// addUserRequest adds a user as a register does, the role is left to the
+// create-admin subcommand.
+type addUserRequest struct {
+	Name     string
+	Password string
+}
+
+// HandleAddUser adds a user for an admin.
 func HandleAddUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	admin, err := requireAdmin(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
 
-	userJson := r.Body
-	decoder := json.NewDecoder(userJson)
-	var userData mongodb.UserPayload
-	if err := decoder.Decode(&userData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, err.Error())
+	var request addUserRequest
+	if err := util.DecodeBody(w, r, &request); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
-	if _, err := mongodb.AddUser(userData); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err.Error())
+	if err := Register(request.Name, request.Password); err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
+	logrus.Infof("user %s added by %s", request.Name, admin)
 	w.WriteHeader(http.StatusOK)
 	resp := map[string]bool{"success": true}
 	fmt.Fprint(w, util.EncodeJson(resp))
 }
 
+// HandleRemoveUser removes a user for an admin.
 func HandleRemoveUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	id := mux.Vars(r)["id"]
-	err := mongodb.DeleteUserById(id)
+	admin, err := requireAdmin(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Print(w, err.Error())
+		util.WriteError(w, r, err)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := mongodb.DeleteUserById(id); err != nil {
+		util.WriteError(w, r, err)
+		return
 	}
+	logrus.Infof("user %s removed by %s", id, admin)
 	w.WriteHeader(http.StatusOK)
 	resp := map[string]bool{"success": true}
 	fmt.Fprint(w, util.EncodeJson(resp))
 }
 
-func GetUserFromCookie(w http.ResponseWriter, r *http.Request) *user {
-	userName, _, err := util.GetUser(w, r)
-	if err != nil {
-		logrus.Error(util.Errorf("get hero failed").WithCause(err))
-		return nil
+// HandleGetSetup tells whether the first admin is still to be created.
+func HandleGetSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
 	}
-	user, err := getUser(userName)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(map[string]bool{"required": SetupRequired()}))
+}
+
+// HandleSetup creates the first admin with the setup token and logs it in.
+func HandleSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request SetupRequest
+	if err := util.DecodeBody(w, r, &request); err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	user, err := Setup(request)
 	if err != nil {
-		logrus.Error(err)
+		util.WriteError(w, r, err)
+		return
 	}
-	if user == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, util.Errorf("User %s not found", userName).Error())
-		return nil
+
+	if err := util.AddSession(w, r, user.Name, user.Role); err != nil {
+		util.WriteError(w, r, util.Errorf("save session error:%s.", user.Name).WithCause(err))
+		return
 	}
-	return user
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, util.EncodeJson(user))
 }
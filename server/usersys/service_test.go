@@ -0,0 +1,117 @@
+package usersys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"server/config"
+	"server/util"
+
+	"github.com/gorilla/mux"
+)
+
+// useMemoryUsers keeps the users of the test in memory and signs tokens.
+func useMemoryUsers(t *testing.T, users ...*user) {
+	config.Current.User.Test = true
+	t.Cleanup(func() {
+		config.Current.User.Test = false
+		userList.Range(func(name, _ any) bool {
+			userList.Delete(name)
+			return true
+		})
+	})
+	if err := util.InitAuth([]byte(strings.Repeat("k", util.MinSecretSize)), Role); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		userList.Store(u.Name, u)
+	}
+}
+
+func TestUserAdminRoutes(t *testing.T) {
+	useMemoryUsers(t,
+		&user{Name: "admin", password: "pw", Role: util.RoleAdmin},
+		&user{Name: "manager", password: "pw", Role: util.RoleManager},
+		&user{Name: "player", password: "pw", Role: util.RolePlayer},
+	)
+	routes := []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{HandleGetUsers, http.MethodGet, ""},
+		{HandleAddUser, http.MethodPost, `{"Name":"mallory","Password":"pw"}`},
+		{HandleRemoveUser, http.MethodDelete, ""},
+	}
+	for _, viewer := range []struct {
+		name string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"player", http.StatusForbidden},
+		{"manager", http.StatusForbidden},
+	} {
+		for _, route := range routes {
+			r := httptest.NewRequest(route.method, "/", strings.NewReader(route.body))
+			r = mux.SetURLVars(r, map[string]string{"id": "000000000000000000000001"})
+			if viewer.name != "" {
+				u, _ := getUser(viewer.name)
+				r.Header.Set("Authorization", "Bearer "+util.NewToken(u.Name, u.Role))
+			}
+			w := httptest.NewRecorder()
+			route.handler(w, r)
+			if w.Code != viewer.want {
+				t.Errorf("%s %q by %q: status %d, want %d", route.method, route.body, viewer.name, w.Code, viewer.want)
+			}
+		}
+	}
+	if _, err := getUser("mallory"); err == nil {
+		t.Error("a non admin added a user")
+	}
+}
+
+func TestAddUser(t *testing.T) {
+	useMemoryUsers(t, &user{Name: "admin", password: "pw", Role: util.RoleAdmin})
+	token := util.NewToken("admin", util.RoleAdmin)
+	add := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		HandleAddUser(w, r)
+		return w
+	}
+
+	if w := add(`{"Name":"mallory","Password":"pw","Role":2}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field": "Role"`) {
+		t.Errorf("add with a role: status %d, body %s, want the role rejected", w.Code, w.Body)
+	}
+	if w := add(`{"Name":"bob","Password":"pw"}`); w.Code != http.StatusOK {
+		t.Fatalf("add bob: status %d, body %s", w.Code, w.Body)
+	}
+	if u, err := getUser("bob"); err != nil || u.Role != util.RolePlayer {
+		t.Errorf("bob = %+v, %v, want a player", u, err)
+	}
+	if w := add(`{"Name":"bob","Password":"pw"}`); w.Code != http.StatusConflict {
+		t.Errorf("add bob twice: status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestAuthBody(t *testing.T) {
+	useMemoryUsers(t)
+	for _, handler := range []http.HandlerFunc{HandleRegister, HandleLogin} {
+		for _, body := range []string{
+			`{"Username":"mallory","Password":"pw","Role":2}`,
+			`{"Username":"mallory","Password":"` + strings.Repeat("x", int(config.Current.HTTP.MaxBodySize)) + `"}`,
+		} {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("body %.60s: status %d, want %d", body, w.Code, http.StatusBadRequest)
+			}
+		}
+	}
+	if _, err := getUser("mallory"); err == nil {
+		t.Error("registered with a rejected body")
+	}
+}
@@ -0,0 +1,120 @@
+package usersys
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+
+	"server/config"
+	"server/util"
+)
+
+// First run setup: while no admin exists the server accepts a one-time
+// setup token, the first caller holding it creates the admin. Then setup
+// is locked for good.
+
+var setupTokenFlag = &config.Current.User.SetupToken
+
+var setup struct {
+	sync.Mutex
+	token string // empty when setup is locked
+}
+
+// SetupRequest creates the first admin.
+type SetupRequest struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// initSetup opens setup when no admin exists.
+func initSetup() error {
+	ok, err := HaveAdmin()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	token := *setupTokenFlag
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return util.Errorf("generate setup token failed").WithCause(err)
+		}
+		token = hex.EncodeToString(b)
+		logrus.Warnf("no admin user, finish the setup with token %s", token)
+	} else {
+		logrus.Warn("no admin user, finish the setup with the configured token")
+	}
+	setup.Lock()
+	setup.token = token
+	setup.Unlock()
+	return nil
+}
+
+// SetupRequired tells whether the first admin is still to be created.
+func SetupRequired() bool {
+	setup.Lock()
+	defer setup.Unlock()
+	return setup.token != ""
+}
+
+// Setup creates the first admin and locks setup.
+func Setup(req SetupRequest) (*user, error) {
+	setup.Lock()
+	defer setup.Unlock()
+	if setup.token == "" {
+		return nil, util.Errorf("setup is already done").WithCode(codes.FailedPrecondition)
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(setup.token)) != 1 {
+		return nil, util.Errorf("invalid setup token").WithCode(codes.PermissionDenied)
+	}
+	// create-admin may have run meanwhile
+	ok, err := HaveAdmin()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		setup.token = ""
+		return nil, util.Errorf("setup is already done").WithCode(codes.FailedPrecondition)
+	}
+	// setup only creates a new user, the token must not take over one
+	if req.Username == "" || req.Password == "" {
+		return nil, util.Errorf("Invalid username or password").WithCode(codes.InvalidArgument)
+	}
+	u := &user{Name: req.Username, password: req.Password, Heros: []int{}, Role: util.RoleAdmin}
+	if err := newUser(u); err != nil {
+		if util.HaveErrorCode(err, codes.AlreadyExists) {
+			return nil, util.Errorf("user %s already exists, setup only creates a new admin", req.Username).WithCause(err).WithCode(codes.AlreadyExists)
+		}
+		return nil, err
+	}
+	setup.token = ""
+	logrus.Infof("setup done, admin %s created", req.Username)
+	return u, nil
+}
+
+// legacyUser and legacyPassword are the bootstrap user of old versions,
+// the owner of the web data saved before ownership existed.
+const (
+	legacyUser     = "user1"
+	legacyPassword = "aassdd"
+)
+
+// legacyPasswordInUse tells whether the bootstrap user of old versions
+// still has its well known password.
+func legacyPasswordInUse() (bool, error) {
+	u, err := getUser(legacyUser)
+	if util.HaveErrorCode(err, codes.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return u.password == legacyPassword, nil
+}
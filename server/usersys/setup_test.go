@@ -0,0 +1,96 @@
+package usersys
+
+import (
+	"testing"
+
+	"server/mongodb"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc/codes"
+)
+
+// openSetup points the users at mt and opens setup with token.
+func openSetup(t *testing.T, mt *mtest.T, token string) {
+	oldColl := mongodb.Userdb
+	t.Cleanup(func() {
+		mongodb.Userdb = oldColl
+		setup.token = ""
+	})
+	mongodb.Userdb = mt.Coll
+	setup.token = token
+}
+
+func TestSetup(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("wrong token", func(mt *mtest.T) {
+		openSetup(t, mt, "secret")
+		_, err := Setup(SetupRequest{Token: "guess", Username: "admin", Password: "pw"})
+		if !util.HaveErrorCode(err, codes.PermissionDenied) {
+			t.Errorf("Setup with a wrong token: %v, want PermissionDenied", err)
+		}
+		if len(mt.GetAllStartedEvents()) != 0 || !SetupRequired() {
+			t.Error("a wrong token reached the database or locked setup")
+		}
+	})
+	mt.Run("token reuse", func(mt *mtest.T) {
+		openSetup(t, mt, "secret")
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch), mtest.CreateSuccessResponse())
+		u, err := Setup(SetupRequest{Token: "secret", Username: "admin", Password: "pw"})
+		if err != nil || u.Name != "admin" || u.Role != util.RoleAdmin {
+			t.Fatalf("Setup = %+v, %v", u, err)
+		}
+		if SetupRequired() {
+			t.Error("setup is still open")
+		}
+		_, err = Setup(SetupRequest{Token: "secret", Username: "admin2", Password: "pw"})
+		if !util.HaveErrorCode(err, codes.FailedPrecondition) {
+			t.Errorf("Setup again with the token: %v, want FailedPrecondition", err)
+		}
+	})
+	mt.Run("existing name", func(mt *mtest.T) {
+		openSetup(t, mt, "secret")
+		ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		user1 := bson.D{{"name", "user1"}, {"password", "aassdd"}, {"role", int(util.RolePlayer)}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, user1),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+		)
+		_, err := Setup(SetupRequest{Token: "secret", Username: "user1", Password: "mine"})
+		if !util.HaveErrorCode(err, codes.AlreadyExists) {
+			t.Errorf("Setup of an existing user: %v, want AlreadyExists", err)
+		}
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "update" {
+				t.Errorf("Setup updated the existing user: %s", e.Command)
+			}
+		}
+		if !SetupRequired() {
+			t.Error("a failed setup locked setup")
+		}
+	})
+}
+
+func TestLegacyPasswordInUse(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for name, tt := range map[string]struct {
+		docs []bson.D
+		want bool
+	}{
+		"old password": {[]bson.D{{{"name", "user1"}, {"password", "aassdd"}}}, true},
+		"changed":      {[]bson.D{{{"name", "user1"}, {"password", "changed"}}}, false},
+		"no user1":     {nil, false},
+	} {
+		mt.Run(name, func(mt *mtest.T) {
+			openSetup(t, mt, "")
+			ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, tt.docs...))
+			got, err := legacyPasswordInUse()
+			if err != nil || got != tt.want {
+				t.Errorf("legacyPasswordInUse() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
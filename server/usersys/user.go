@@ -1,12 +1,12 @@
 package usersys
 
 import (
-	"flag"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 
+	"server/config"
 	"server/mongodb"
 	"server/util"
 )
@@ -19,31 +19,26 @@ type user struct {
 	Role     util.RoleLevel `json:"role"`
 }
 
-var testFlag = flag.Bool("user.test", false, "test without database")
-var managerFlag = flag.Bool("user.auto-manager", false, "new user as manager")
+var testFlag = &config.Current.User.Test
+var managerFlag = &config.Current.User.AutoManager
 
 var userList sync.Map // map<name string,*user>
 
 const teamNameSubString = "的团队"
 
+// Init opens the first run setup when no user can administer the server,
+// and warns while the bootstrap user of old versions keeps its password.
 func Init() {
-	u, err := getUser("user1")
-	if err == nil {
-		if u != nil {
-			return
-		}
-		logrus.Info("load empty testuser")
-	} else {
-		logrus.Warnf("load user1 error with: %v. Will try to init it.", err)
+	if *testFlag {
+		return
 	}
-
-	err = Register("user1", "aassdd")
-	if err != nil {
-		panic(err)
+	if err := initSetup(); err != nil {
+		logrus.Errorf("init setup err: %v", err)
 	}
-	u, err = getUser("user1")
-	if err != nil {
-		panic(err)
+	if legacy, err := legacyPasswordInUse(); err != nil {
+		logrus.Errorf("look up user %s err: %v", legacyUser, err)
+	} else if legacy {
+		logrus.Warnf("user %s still has the password of old versions, anyone can log in as it; change it with: server reset-password %s", legacyUser, legacyUser)
 	}
 }
 
@@ -87,11 +82,20 @@ func Login(username string, password string) (*user, error) {
 	return user, nil
 }
 
+// Role loads the current role of a user, util checks it on every call.
+func Role(name string) (util.RoleLevel, error) {
+	u, err := getUser(name)
+	if err != nil {
+		return 0, err
+	}
+	return u.Role, nil
+}
+
 func getUser(name string) (*user, error) {
 	if *testFlag {
 		u, ok := userList.Load(name)
 		if !ok {
-			return nil, util.Errorf("user %s notfound", name)
+			return nil, util.Errorf("user %s notfound", name).WithCode(codes.NotFound)
 		}
 		return u.(*user), nil
 	}
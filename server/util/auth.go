@@ -2,15 +2,26 @@ package util
 
 import (
 	"encoding/gob"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/sessions"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
 )
 
 var sessionName = "session"
-var key = []byte("super-secret-key")
-var sessionStore = sessions.NewCookieStore(key)
+
+// key signs tokens and session cookies, set by InitAuth.
+var key []byte
+var sessionStore *sessions.CookieStore
+
+// userRole loads the current role of a user, so that deleted or demoted
+// users lose their access at once instead of when the token expires.
+var userRole func(user string) (RoleLevel, error)
+
+// MinSecretSize is the min bytes of the secret of InitAuth.
+const MinSecretSize = 32
 
 // session values
 const (
@@ -27,17 +38,56 @@ const (
 	RoleAdmin
 )
 
+func (r RoleLevel) String() string {
+	switch r {
+	case RolePlayer:
+		return "player"
+	case RoleManager:
+		return "manager"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("role%d", int(r))
+}
+
 func init() {
+	gob.Register(RolePlayer)
+}
+
+// InitAuth sets the secret signing tokens and session cookies, and role,
+// which loads the role of an authenticated user on every call. It must run
+// before the server takes requests.
+func InitAuth(secret []byte, role func(user string) (RoleLevel, error)) error {
+	if len(secret) < MinSecretSize {
+		return Errorf("the token secret must have at least %d bytes", MinSecretSize).WithCode(codes.InvalidArgument)
+	}
+	key = secret
+	sessionStore = sessions.NewCookieStore(key)
 	// sessionStore.Options.SameSite = http.SameSiteStrictMode
 	sessionStore.Options.SameSite = http.SameSiteLaxMode
-	gob.Register(RolePlayer)
+	userRole = role
+	return nil
+}
+
+// currentRole checks that user still exists and returns its role now.
+func currentRole(user string) (RoleLevel, error) {
+	role, err := userRole(user)
+	if err != nil {
+		if Code(err) == codes.NotFound {
+			return 0, Errorf("登录信息已失效").WithCause(err).WithCode(codes.Unauthenticated)
+		}
+		return 0, Errorf("load the role of %s failed", user).WithCause(err).WithCode(codes.Internal)
+	}
+	return role, nil
 }
 
 func AddSession(w http.ResponseWriter, r *http.Request, user string, role RoleLevel) error {
+	if sessionStore == nil {
+		return Errorf("auth is not initialized").WithCode(codes.Internal)
+	}
 	session, err := sessionStore.Get(r, sessionName)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return err
+		return Errorf("get session failed").WithCause(err).WithCode(codes.InvalidArgument)
 	}
 	session.Values[sessionUser] = user
 	session.Values[sessionRole] = role
@@ -50,40 +100,58 @@ func AddSession(w http.ResponseWriter, r *http.Request, user string, role RoleLe
 	}
 	err = session.Save(r, w)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+		return Errorf("save session failed").WithCause(err).WithCode(codes.Internal)
 	}
 	return nil
 }
 
 func RemoveSession(w http.ResponseWriter, r *http.Request) error {
+	if sessionStore == nil {
+		return nil
+	}
 	session, err := sessionStore.Get(r, sessionName)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return err
+		return Errorf("get session failed").WithCause(err).WithCode(codes.InvalidArgument)
 	}
 	session.Options.MaxAge = -1
 	session.Save(r, w)
 	return nil
 }
 
-func GetUser(w http.ResponseWriter, r *http.Request) (user string, role RoleLevel, err error) {
+// LookupUser reads the session user for handlers that also serve
+// anonymous callers, user is empty for them. Errors other than a missing
+// login, like a database outage loading the role, are returned.
+func LookupUser(r *http.Request) (user string, role RoleLevel, err error) {
+	user, role, err = GetUser(r)
+	if Code(err) == codes.Unauthenticated {
+		return "", 0, nil
+	}
+	return user, role, err
+}
+
+// GetUser returns the bearer token or session user with its current role,
+// or an Unauthenticated error.
+func GetUser(r *http.Request) (user string, role RoleLevel, err error) {
+	if token, ok := tokenFromRequest(r); ok {
+		return ParseToken(token)
+	}
+	if sessionStore == nil {
+		return "", 0, Errorf("登录信息已失效").WithCode(codes.Unauthenticated)
+	}
 	session, err := sessionStore.Get(r, sessionName)
-	logrus.Infoln("session:")
-	logrus.Infoln("session:", session)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return "", 0, err
+		return "", 0, Errorf("登录信息已失效").WithCause(err).WithCode(codes.Unauthenticated)
 	}
 	if session.IsNew {
-		w.WriteHeader(http.StatusUnauthorized)
-		return "", 0, Errorf("登录信息已失效")
+		return "", 0, Errorf("登录信息已失效").WithCode(codes.Unauthenticated)
 	}
-	if _, ok := session.Values[sessionUser]; !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		return "", 0, Errorf("登录信息已失效")
+	user, ok := session.Values[sessionUser].(string)
+	if !ok {
+		return "", 0, Errorf("登录信息已失效").WithCode(codes.Unauthenticated)
+	}
+	role, err = currentRole(user)
+	if err != nil {
+		return "", 0, err
 	}
-	return session.Values[sessionUser].(string),
-		session.Values[sessionRole].(RoleLevel),
-		nil
+	return user, role, nil
 }
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"server/config"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+var maxBodySize = &config.Current.HTTP.MaxBodySize
+
+// DecodeBody decodes the json body of r into v. Bodies over
+// -http.maxBodySize, unknown fields and trailing data are rejected.
+func DecodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return DecodeBodyLimit(w, r, v, *maxBodySize)
+}
+
+// DecodeBodyLimit is DecodeBody with its own size limit.
+func DecodeBodyLimit(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return Errorf("request body larger than %d bytes", limit).WithCause(err).WithCode(codes.InvalidArgument)
+		}
+		e := Errorf("invalid request body").WithCause(err).WithCode(codes.InvalidArgument)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			e.WithFields(FieldError{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type)})
+		} else if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			e.WithFields(FieldError{Field: strings.Trim(field, `"`), Message: "unknown field"})
+		}
+		return e
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return Errorf("invalid request body: data after the json value").WithCode(codes.InvalidArgument)
+	}
+	return nil
+}
+
+// jsonType names the json type decoded into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	if t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64 {
+		return "an integer"
+	}
+	return "a " + t.String()
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"server/api"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse = api.ErrorResponse
+
+// HTTPStatus maps a gRPC code to the matching http status.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the outermost code in the error chain, or codes.Unknown.
+func Code(err error) codes.Code {
+	e, ok := err.(*Error)
+	for ok && e != nil {
+		if e.code != nil {
+			return *e.code
+		}
+		e = e.subErr
+	}
+	return codes.Unknown
+}
+
+// Message returns the client facing message of err, without its causes.
+func Message(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.err
+	}
+	return err.Error()
+}
+
+// WriteError writes err as a json ErrorResponse with the status of its code.
+// The full error chain is only logged; server errors hide their message too.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	code := Code(err)
+	status := HTTPStatus(code)
+	resp := ErrorResponse{
+		Code:      code.String(),
+		Message:   Message(err),
+		RequestID: GetRequestID(r.Context()),
+		Fields:    Fields(err),
+	}
+	entry := logrus.WithFields(logrus.Fields{
+		"requestId": resp.RequestID,
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"status":    status,
+	})
+	if status >= http.StatusInternalServerError {
+		entry.Error(err.Error())
+		resp.Message = http.StatusText(status)
+	} else {
+		entry.Info(err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(EncodeJson(resp)))
+}
+
+// RequestID tags every request with an id, reusing the X-Request-ID header
+// when the client or proxy already sent one.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
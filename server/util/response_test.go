@@ -0,0 +1,76 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	for code, want := range map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           499,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusBadRequest,
+		codes.Aborted:            http.StatusConflict,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DataLoss:           http.StatusInternalServerError,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+	} {
+		if got := HTTPStatus(code); got != want {
+			t.Errorf("HTTPStatus(%s) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+		fields  int
+	}{
+		{"client error", Errorf("tag go not found").WithCode(codes.NotFound), http.StatusNotFound, "NotFound", "tag go not found", 0},
+		{"outer code", Errorf("invalid tag").WithCause(Errorf("db").WithCode(codes.Internal)).WithCode(codes.InvalidArgument),
+			http.StatusBadRequest, "InvalidArgument", "invalid tag", 0},
+		{"inner code", Errorf("add tag failed").WithCause(Errorf("tag exists").WithCode(codes.AlreadyExists)),
+			http.StatusConflict, "AlreadyExists", "add tag failed", 0},
+		{"fields", Errorf("invalid body").WithCode(codes.InvalidArgument).WithFields(FieldError{Field: "Name", Message: "required"}),
+			http.StatusBadRequest, "InvalidArgument", "invalid body", 1},
+		{"server error", Errorf("connect mongodb at 10.0.0.1 failed").WithCode(codes.Internal), http.StatusInternalServerError, "Internal", "Internal Server Error", 0},
+		{"no code", Errorf("panic"), http.StatusInternalServerError, "Unknown", "Internal Server Error", 0},
+		{"plain error", errors.New("secret detail"), http.StatusInternalServerError, "Unknown", "Internal Server Error", 0},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, "req1"))
+		w := httptest.NewRecorder()
+		WriteError(w, r, tt.err)
+
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if w.Code != tt.status || resp.Code != tt.code || resp.Message != tt.message || resp.RequestID != "req1" || len(resp.Fields) != tt.fields {
+			t.Errorf("%s: status %d, body %s", tt.name, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: content type %s", tt.name, ct)
+		}
+	}
+}
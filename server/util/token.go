@@ -0,0 +1,74 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"server/config"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+var tokenExpire = &config.Current.Token.Expire
+
+type tokenClaims struct {
+	User   string    `json:"u"`
+	Role   RoleLevel `json:"r"`
+	Expire int64     `json:"e"`
+}
+
+// NewToken signs a bearer token for user, for clients without cookies. The
+// role is informative, ParseToken loads the current one.
+func NewToken(user string, role RoleLevel) string {
+	claims, _ := json.Marshal(tokenClaims{user, role, time.Now().Add(*tokenExpire).Unix()})
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + signToken(payload)
+}
+
+// ParseToken checks the signature and expiry of a bearer token, then
+// returns its user with the current role.
+func ParseToken(token string) (user string, role RoleLevel, err error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || key == nil || !hmac.Equal([]byte(sig), []byte(signToken(payload))) {
+		return "", 0, Errorf("invalid token").WithCode(codes.Unauthenticated)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", 0, Errorf("invalid token").WithCause(err).WithCode(codes.Unauthenticated)
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return "", 0, Errorf("invalid token").WithCause(err).WithCode(codes.Unauthenticated)
+	}
+	if time.Now().Unix() > claims.Expire {
+		return "", 0, Errorf("token expired").WithCode(codes.Unauthenticated)
+	}
+	role, err = currentRole(claims.User)
+	if err != nil {
+		return "", 0, err
+	}
+	return claims.User, role, nil
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" value.
+func BearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func tokenFromRequest(r *http.Request) (string, bool) {
+	return BearerToken(r.Header.Get("Authorization"))
+}
+
+func signToken(payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseToken(t *testing.T) {
+	roles := map[string]RoleLevel{"alice": RoleAdmin, "bob": RolePlayer}
+	role := func(user string) (RoleLevel, error) {
+		r, ok := roles[user]
+		if !ok {
+			return 0, Errorf("user %s notfound", user).WithCode(codes.NotFound)
+		}
+		return r, nil
+	}
+	if err := InitAuth([]byte("short"), role); err == nil {
+		t.Error("InitAuth accepted a short secret")
+	}
+	if err := InitAuth([]byte(strings.Repeat("k", MinSecretSize)), role); err != nil {
+		t.Fatal(err)
+	}
+
+	token := NewToken("alice", RoleAdmin)
+	if user, r, err := ParseToken(token); err != nil || user != "alice" || r != RoleAdmin {
+		t.Fatalf("ParseToken = %s, %s, %v", user, r, err)
+	}
+
+	// the role is the current one, not the one signed
+	roles["alice"] = RolePlayer
+	if _, r, err := ParseToken(token); err != nil || r != RolePlayer {
+		t.Errorf("demoted user: role %s, err %v", r, err)
+	}
+	delete(roles, "alice")
+	if _, _, err := ParseToken(token); Code(err) != codes.Unauthenticated {
+		t.Errorf("deleted user: err %v, want Unauthenticated", err)
+	}
+
+	// a token of another key
+	bob := NewToken("bob", RoleAdmin)
+	if err := InitAuth([]byte(strings.Repeat("x", MinSecretSize)), role); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseToken(bob); Code(err) != codes.Unauthenticated {
+		t.Errorf("token of another key: err %v, want Unauthenticated", err)
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	role := func(user string) (RoleLevel, error) {
+		switch user {
+		case "alice":
+			return RoleManager, nil
+		case "carol":
+			return 0, Errorf("server selection timeout")
+		}
+		return 0, Errorf("user %s notfound", user).WithCode(codes.NotFound)
+	}
+	if err := InitAuth([]byte(strings.Repeat("k", MinSecretSize)), role); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(token string) (string, RoleLevel, error) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return LookupUser(r)
+	}
+
+	if user, r, err := lookup(NewToken("alice", RolePlayer)); err != nil || user != "alice" || r != RoleManager {
+		t.Errorf("alice: %s, %s, %v", user, r, err)
+	}
+	for name, token := range map[string]string{"anonymous": "", "bad token": "x.y", "deleted user": NewToken("bob", RolePlayer)} {
+		if user, _, err := lookup(token); err != nil || user != "" {
+			t.Errorf("%s: %q, %v, want an anonymous viewer", name, user, err)
+		}
+	}
+	// the database failing is no anonymous viewer
+	if _, _, err := lookup(NewToken("carol", RolePlayer)); Code(err) != codes.Internal {
+		t.Errorf("role lookup failing: %v, want Internal", err)
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"server/api"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
@@ -11,9 +12,13 @@ import (
 type Error struct {
 	err    string
 	code   *codes.Code
+	fields []FieldError
 	subErr *Error
 }
 
+// FieldError is the error of one field of a request payload.
+type FieldError = api.FieldError
+
 func (e *Error) Error() string {
 	err := e.err
 	if e.code != nil {
@@ -42,6 +47,12 @@ func (e *Error) WithCode(code codes.Code) *Error {
 	return e
 }
 
+// WithFields attaches the field errors of an invalid payload.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	e.fields = append(e.fields, fields...)
+	return e
+}
+
 func (e *Error) Log() *Error {
 	logrus.Error(e.Error())
 	return e
@@ -67,6 +78,18 @@ func HaveErrorCode(e error, code codes.Code) bool {
 		return HaveErrorCode(err.subErr, code)
 	}
 }
+
+// Fields returns the field errors in the error chain.
+func Fields(e error) []FieldError {
+	err, ok := e.(*Error)
+	var fields []FieldError
+	for ok && err != nil {
+		fields = append(fields, err.fields...)
+		err = err.subErr
+	}
+	return fields
+}
+
 func DecodeJson(j string, item interface{}) {
 	if err := json.Unmarshal([]byte(j), item); err != nil {
 		panic(Errorf("decode json %s failed", j).WithCause(err))
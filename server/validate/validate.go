@@ -0,0 +1,235 @@
+// Package validate checks request payloads against declarative rules and
+// reports every invalid field at once:
+//
+//	var tagRules = validate.Rules[mongodb.Tag]{
+//		{Name: "Name", Value: func(t mongodb.Tag) any { return t.Name }, Checks: []validate.Check{validate.Required(), validate.MaxLen(64)}},
+//	}
+//
+// Checks other than Required accept empty values, so optional fields only
+// need the checks of their content.
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"server/util"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Check returns why value is invalid, or "" if it is valid.
+type Check func(value any) string
+
+// Field declares the checks of one field of payload T. Name is the field as
+// the client sends it.
+type Field[T any] struct {
+	Name   string
+	Value  func(T) any
+	Checks []Check
+}
+
+// Rules are the fields checked of payload T.
+type Rules[T any] []Field[T]
+
+// Validate returns an InvalidArgument error listing the first failed check
+// of each field, or nil if payload is valid.
+func (rules Rules[T]) Validate(payload T) error {
+	var fields []util.FieldError
+	for _, field := range rules {
+		value := field.Value(payload)
+		for _, check := range field.Checks {
+			if msg := check(value); msg != "" {
+				fields = append(fields, util.FieldError{Field: field.Name, Message: msg})
+				break
+			}
+		}
+	}
+	return Error(fields...)
+}
+
+// Error returns an InvalidArgument error of fields, nil if there are none.
+func Error(fields ...util.FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return util.Errorf("invalid %s", strings.Join(msgs, "; ")).WithCode(codes.InvalidArgument).WithFields(fields...)
+}
+
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// Required rejects empty and blank values.
+func Required() Check {
+	return func(value any) string {
+		if isEmpty(value) {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// MaxLen limits strings to n characters.
+func MaxLen(n int) Check {
+	return func(value any) string {
+		if s, ok := value.(string); ok && utf8.RuneCountInString(s) > n {
+			return fmt.Sprintf("is longer than %d characters", n)
+		}
+		return ""
+	}
+}
+
+// MaxItems limits slices to n items.
+func MaxItems(n int) Check {
+	return func(value any) string {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Slice && v.Len() > n {
+			return fmt.Sprintf("has more than %d items", n)
+		}
+		return ""
+	}
+}
+
+// Min rejects integers, durations included, below n.
+func Min(n int64) Check {
+	return func(value any) string {
+		if i, ok := integer(value); ok && i < n {
+			return fmt.Sprintf("must be at least %d", n)
+		}
+		return ""
+	}
+}
+
+// Positive rejects integers, durations included, that are not above 0.
+func Positive() Check {
+	return func(value any) string {
+		if i, ok := integer(value); ok && i <= 0 {
+			return "must be greater than 0"
+		}
+		return ""
+	}
+}
+
+func integer(value any) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	}
+	return 0, false
+}
+
+// Printable rejects strings with control characters or invalid utf-8.
+func Printable() Check {
+	return func(value any) string {
+		s, ok := value.(string)
+		if !ok {
+			return ""
+		}
+		if !utf8.ValidString(s) {
+			return "is not valid utf-8"
+		}
+		for _, r := range s {
+			if unicode.IsControl(r) {
+				return "contains control characters"
+			}
+		}
+		return ""
+	}
+}
+
+// OneOf limits strings to values.
+func OneOf(values ...string) Check {
+	return func(value any) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		for _, v := range values {
+			if s == v {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	}
+}
+
+// URL accepts absolute urls with one of schemes and, except for mailto, a
+// host.
+func URL(schemes ...string) Check {
+	return func(value any) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return "is not a valid url"
+		}
+		scheme := strings.ToLower(u.Scheme)
+		allowed := false
+		for _, want := range schemes {
+			allowed = allowed || scheme == want
+		}
+		if !allowed {
+			return "must be a url with scheme " + strings.Join(schemes, ", ")
+		}
+		if scheme != "mailto" && u.Host == "" {
+			return "must be a url with a host"
+		}
+		return ""
+	}
+}
+
+// Each runs checks on every item of a []string, reporting the first
+// invalid one.
+func Each(checks ...Check) Check {
+	return func(value any) string {
+		items, ok := value.([]string)
+		if !ok {
+			return ""
+		}
+		for i, item := range items {
+			for _, check := range checks {
+				if msg := check(item); msg != "" {
+					return fmt.Sprintf("item %d %s", i+1, msg)
+				}
+			}
+		}
+		return ""
+	}
+}
+
+// Unique rejects a []string with repeated items.
+func Unique() Check {
+	return func(value any) string {
+		items, _ := value.([]string)
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if seen[item] {
+				return fmt.Sprintf("repeats %q", item)
+			}
+			seen[item] = true
+		}
+		return ""
+	}
+}
@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"server/util"
+
+	"google.golang.org/grpc/codes"
+)
+
+type bookmark struct {
+	Name string
+	Url  string
+	Tags []string
+}
+
+var rules = Rules[bookmark]{
+	{Name: "Name", Value: func(b bookmark) any { return b.Name }, Checks: []Check{Required(), MaxLen(5), Printable()}},
+	{Name: "Url", Value: func(b bookmark) any { return b.Url }, Checks: []Check{Required(), URL("http", "https")}},
+	{Name: "Tags", Value: func(b bookmark) any { return b.Tags }, Checks: []Check{MaxItems(2), Unique(), Each(Required(), MaxLen(3))}},
+}
+
+func TestValidate(t *testing.T) {
+	if err := rules.Validate(bookmark{Name: "go", Url: "https://go.dev", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("valid bookmark: %v", err)
+	}
+
+	for _, c := range []struct {
+		in     bookmark
+		fields map[string]string
+	}{
+		{bookmark{}, map[string]string{"Name": "is required", "Url": "is required"}},
+		{bookmark{Name: "  ", Url: "javascript:alert(1)"}, map[string]string{"Name": "is required", "Url": "must be a url with scheme http, https"}},
+		{bookmark{Name: "toolong", Url: "https:///path"}, map[string]string{"Name": "is longer than 5 characters", "Url": "must be a url with a host"}},
+		{bookmark{Name: "a\x00b", Url: "http://x"}, map[string]string{"Name": "contains control characters"}},
+		{bookmark{Name: "ok", Url: "http://x", Tags: []string{"a", "b", "c"}}, map[string]string{"Tags": "has more than 2 items"}},
+		{bookmark{Name: "ok", Url: "http://x", Tags: []string{"a", "a"}}, map[string]string{"Tags": `repeats "a"`}},
+		{bookmark{Name: "ok", Url: "http://x", Tags: []string{"a", ""}}, map[string]string{"Tags": "item 2 is required"}},
+	} {
+		err := rules.Validate(c.in)
+		if !util.HaveErrorCode(err, codes.InvalidArgument) {
+			t.Errorf("Validate(%+v) = %v, want InvalidArgument", c.in, err)
+			continue
+		}
+		fields := util.Fields(err)
+		if len(fields) != len(c.fields) {
+			t.Errorf("Validate(%+v) fields = %v, want %v", c.in, fields, c.fields)
+		}
+		for _, f := range fields {
+			if c.fields[f.Field] != f.Message {
+				t.Errorf("Validate(%+v) %s %q, want %q", c.in, f.Field, f.Message, c.fields[f.Field])
+			}
+			if !strings.Contains(util.Message(err), f.Field+" "+f.Message) {
+				t.Errorf("message %q lacks field %s", util.Message(err), f.Field)
+			}
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	type limits struct {
+		Workers int
+		Timeout time.Duration
+	}
+	rules := Rules[limits]{
+		{Name: "Workers", Value: func(l limits) any { return l.Workers }, Checks: []Check{Min(0)}},
+		{Name: "Timeout", Value: func(l limits) any { return l.Timeout }, Checks: []Check{Positive()}},
+	}
+	if err := rules.Validate(limits{Workers: 0, Timeout: time.Second}); err != nil {
+		t.Fatalf("valid limits: %v", err)
+	}
+	err := rules.Validate(limits{Workers: -1})
+	fields := util.Fields(err)
+	if len(fields) != 2 || fields[0].Message != "must be at least 0" || fields[1].Message != "must be greater than 0" {
+		t.Errorf("Validate fields = %v", fields)
+	}
+}
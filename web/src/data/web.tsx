@@ -100,15 +100,24 @@ export class WebData {
     }
 }
 
+// 每个标签加引号，空格、开头的 - 和 AND 之类的词都算标签名
+export function TagQuery ( tags: string[] ): string {
+    return tags.map( tag => `"${ tag }"` ).join( "," )
+}
+
 export async function Search ( tags: string[] ): Promise<WebData[]> {
     try {
-        const response = await api.get( `/web/` + tags.join( "," ) )
-        console.log( response.data )
-
-        // 创建 WebData 数组
-        const webDataArray: WebData[] = response.data.map( ( data: WebData ) => {
-            return new WebData( data.Url, data.Tags, data.Name, data.Description, data.ID )
-        } )
+        // 按页读取全部结果
+        const webDataArray: WebData[] = []
+        let cursor = ""
+        do {
+            const response = await api.get( `/web`, { params: { q: TagQuery( tags ), limit: 200, cursor: cursor || undefined } } )
+            console.log( response.data )
+            response.data.items.forEach( ( data: WebData ) => {
+                webDataArray.push( new WebData( data.Url, data.Tags, data.Name, data.Description, data.ID ) )
+            } )
+            cursor = response.data.nextCursor
+        } while ( cursor )
 
         return webDataArray
     } catch ( error ) {
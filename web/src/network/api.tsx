@@ -33,7 +33,8 @@ api.interceptors.response.use(
             window.location.href = '/noauth';
         }
         if (error.response.status === 500) {
-            alert("程序出错了！快联系开发者看看！\n" + error.response.data);
+            const { message, requestId } = error.response.data || {};
+            alert("程序出错了！快联系开发者看看！\n" + message + " (" + requestId + ")");
         }
         if (error.response.status === 502) {
             if (error.response.data === "") {